@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/ledis"
+	"github.com/siddontang/ledisdb/rpl"
+)
+
+var host = flag.String("host", "127.0.0.1", "ledis server host")
+var port = flag.Int("port", 6380, "ledis server port")
+var sock = flag.String("sock", "", "ledis unix socket domain")
+var startLogID = flag.Uint64("start", 0, "log id to start from, 0 means only the new logs")
+var useBase64 = flag.Bool("base64", false, "encode all the keys, fields and values in base64, not only the ones not valid UTF-8")
+
+// ledis-cdc tails the replication logs of the ledis server from the start log id,
+// and writes the change events to stdout as newline delimited JSON.
+func main() {
+	flag.Parse()
+
+	var addr string
+	if len(*sock) != 0 {
+		addr = *sock
+	} else {
+		addr = fmt.Sprintf("%s:%d", *host, *port)
+	}
+
+	c, err := goredis.ConnectWithSize(addr, 16*1024, 4096)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+
+	defer c.Close()
+
+	logID := *startLogID
+	if logID == 0 {
+		if logID, err = lastLogID(c); err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		logID++
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+
+	for {
+		// sync waits the new logs at most 1 second if no logs
+		buf, err := goredis.Bytes(c.Do("sync", logID))
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		} else if len(buf) < 8 {
+			println(fmt.Sprintf("invalid sync size %d", len(buf)))
+			os.Exit(1)
+		}
+
+		r := bytes.NewReader(buf[8:])
+		for r.Len() > 0 {
+			rl := new(rpl.Log)
+			if err = rl.Decode(r); err != nil {
+				println(err.Error())
+				os.Exit(1)
+			}
+
+			events, err := ledis.DecodeLog(rl)
+			if err != nil {
+				println(err.Error())
+				os.Exit(1)
+			}
+
+			for _, e := range events {
+				var b []byte
+				if *useBase64 {
+					b, err = e.MarshalJSONBase64()
+				} else {
+					b, err = json.Marshal(e)
+				}
+				if err != nil {
+					println(err.Error())
+					os.Exit(1)
+				}
+
+				w.Write(b)
+				w.WriteByte('\n')
+			}
+
+			logID = rl.ID + 1
+		}
+
+		if err = w.Flush(); err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+func lastLogID(c *goredis.Conn) (uint64, error) {
+	info, err := goredis.String(c.Do("info", "replication"))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "last_log_id:") {
+			return strconv.ParseUint(line[len("last_log_id:"):], 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("replication is not enabled")
+}
@@ -2,21 +2,46 @@
 package main
 
 var helpCommands = [][]string{
+	{"ACL CAT", "-", "ACL"},
+	{"ACL DELUSER", "username [username ...]", "ACL"},
+	{"ACL GETUSER", "username", "ACL"},
+	{"ACL LIST", "-", "ACL"},
+	{"ACL LOAD", "-", "ACL"},
+	{"ACL SAVE", "-", "ACL"},
+	{"ACL SETUSER", "username [rule [rule ...]]", "ACL"},
+	{"ACL USERS", "-", "ACL"},
+	{"ACL WHOAMI", "-", "ACL"},
 	{"APPEND", "key value", "KV"},
+	{"AUTH", "[username] password", "ACL"},
 	{"BITCOUNT", "key [start] [end]", "KV"},
 	{"BITOP", "operation destkey key [key ...]", "KV"},
 	{"BITPOS", "key bit [start] [end]", "KV"},
 	{"BLPOP", "key [key ...] timeout", "List"},
 	{"BRPOP", "key [key ...] timeout", "List"},
-	{"CONFIG GET", "parameter", "Server"},
+	{"CLIENT GETNAME", "-", "Server"},
+	{"CLIENT ID", "-", "Server"},
+	{"CLIENT KILL", "addr | [ID id] [ADDR addr] [SKIPME yes/no]", "Server"},
+	{"CLIENT LIST", "-", "Server"},
+	{"CLIENT PAUSE", "timeout [WRITE|ALL]", "Server"},
+	{"CLIENT SETNAME", "name", "Server"},
+	{"CLIENT UNPAUSE", "-", "Server"},
+	{"COMMAND", "-", "Server"},
+	{"COMMAND COUNT", "-", "Server"},
+	{"COMMAND GETKEYS", "command [arg ...]", "Server"},
+	{"COMMAND INFO", "command-name [command-name ...]", "Server"},
+	{"CONFIG GET", "pattern", "Server"},
+	{"CONFIG RESETSTAT", "-", "Server"},
 	{"CONFIG REWRITE", "-", "Server"},
+	{"CONFIG SET", "parameter value", "Server"},
 	{"DECR", "key", "KV"},
 	{"DECRBY", "key decrement", "KV"},
 	{"DEL", "key [key ...]", "KV"},
+	{"DISCARD", "-", "Transaction"},
 	{"DUMP", "key", "KV"},
 	{"ECHO", "message", "Server"},
 	{"EVAL", "script numkeys key [key ...] arg [arg ...]", "Script"},
 	{"EVALSHA", "sha1 numkeys key [key ...] arg [arg ...]", "Script"},
+	{"EXEC", "-", "Transaction"},
 	{"EXISTS", "key", "KV"},
 	{"EXPIRE", "key seconds", "KV"},
 	{"EXPIREAT", "key timestamp", "KV"},
@@ -30,6 +55,7 @@ var helpCommands = [][]string{
 	{"HCLEAR", "key", "Hash"},
 	{"HDEL", "key field [field ...]", "Hash"},
 	{"HDUMP", "key", "Hash"},
+	{"HELLO", "[protover [AUTH username password] [SETNAME clientname]]", "Server"},
 	{"HEXISTS", "key field", "Hash"},
 	{"HEXPIRE", "key seconds", "Hash"},
 	{"HEXPIREAT", "key timestamp", "Hash"},
@@ -43,6 +69,9 @@ var helpCommands = [][]string{
 	{"HMGET", "key field [field ...]", "Hash"},
 	{"HMSET", "key field value [field value ...]", "Hash"},
 	{"HPERSIST", "key", "Hash"},
+	{"HPEXPIRE", "key milliseconds", "Hash"},
+	{"HPEXPIREAT", "key milliseconds-timestamp", "Hash"},
+	{"HPTTL", "key", "Hash"},
 	{"HSCAN", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Hash"},
 	{"HSET", "key field value", "Hash"},
 	{"HTTL", "key", "Hash"},
@@ -59,14 +88,27 @@ var helpCommands = [][]string{
 	{"LLEN", "key", "List"},
 	{"LMCLEAR", "key [key ...]", "List"},
 	{"LPERSIST", "key", "List"},
+	{"LPEXPIRE", "key milliseconds", "List"},
+	{"LPEXPIREAT", "key milliseconds-timestamp", "List"},
 	{"LPOP", "key", "List"},
+	{"LPTTL", "key", "List"},
 	{"LPUSH", "key value [value ...]", "List"},
 	{"LRANGE", "key start stop", "List"},
 	{"LTTL", "key", "List"},
 	{"MGET", "key [key ...]", "KV"},
+	{"MONITOR", "-", "Server"},
 	{"MSET", "key value [key value ...]", "KV"},
+	{"MULTI", "-", "Transaction"},
 	{"PERSIST", "key", "KV"},
+	{"PEXPIRE", "key milliseconds", "KV"},
+	{"PEXPIREAT", "key milliseconds-timestamp", "KV"},
 	{"PING", "-", "Server"},
+	{"PSETEX", "key milliseconds value", "KV"},
+	{"PSUBSCRIBE", "pattern [pattern ...]", "PubSub"},
+	{"PTTL", "key", "KV"},
+	{"PUBLISH", "channel message", "PubSub"},
+	{"PUBSUB", "subcommand [argument [argument ...]]", "PubSub"},
+	{"PUNSUBSCRIBE", "[pattern [pattern ...]]", "PubSub"},
 	{"RESTORE", "key ttl value", "Server"},
 	{"ROLE", "-", "Server"},
 	{"RPOP", "key", "List"},
@@ -81,7 +123,7 @@ var helpCommands = [][]string{
 	{"SDIFFSTORE", "destination key [key ...]", "Set"},
 	{"SDUMP", "key", "Set"},
 	{"SELECT", "index", "Server"},
-	{"SET", "key value", "KV"},
+	{"SET", "key value [EX seconds|PX milliseconds]", "KV"},
 	{"SETBIT", "key offset value", "KV"},
 	{"SETEX", "key seconds value", "KV"},
 	{"SETNX", "key value", "KV"},
@@ -93,18 +135,29 @@ var helpCommands = [][]string{
 	{"SISMEMBER", "key member", "Set"},
 	{"SKEYEXISTS", "key", "Set"},
 	{"SLAVEOF", "host port [RESTART] [READONLY]", "Replication"},
+	{"SLOWLOG GET", "[count]", "Server"},
+	{"SLOWLOG LEN", "-", "Server"},
+	{"SLOWLOG RESET", "-", "Server"},
 	{"SMCLEAR", "key [key ...]", "Set"},
 	{"SMEMBERS", "key", "Set"},
 	{"SPERSIST", "key", "Set"},
+	{"SPEXPIRE", "key milliseconds", "Set"},
+	{"SPEXPIREAT", "key milliseconds-timestamp", "Set"},
+	{"SPTTL", "key", "Set"},
 	{"SREM", "key member [member ...]", "Set"},
 	{"SSCAN", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Set"},
 	{"STRLEN", "key", "KV"},
 	{"STTL", "key", "Set"},
+	{"SUBSCRIBE", "channel [channel ...]", "PubSub"},
 	{"SUNION", "key [key ...]", "Set"},
 	{"SUNIONSTORE", "destination key [key ...]", "Set"},
 	{"SYNC", "logid", "Replication"},
 	{"TIME", "-", "Server"},
 	{"TTL", "key", "KV"},
+	{"TYPE", "key", "KV"},
+	{"UNSUBSCRIBE", "[channel [channel ...]]", "PubSub"},
+	{"UNWATCH", "-", "Transaction"},
+	{"WATCH", "key [key ...]", "Transaction"},
 	{"XHSCAN", "key cursor [MATCH match] [COUNT count] [ASC|DESC]", "Hash"},
 	{"XLSORT", "key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC|DESC] [ALPHA] [STORE destination]", "List"},
 	{"XSCAN", "type cursor [MATCH match] [COUNT count] [ASC|DESC]", "Server"},
@@ -125,6 +178,9 @@ var helpCommands = [][]string{
 	{"ZLEXCOUNT", "key min max", "ZSet"},
 	{"ZMCLEAR", "key [key ...]", "ZSet"},
 	{"ZPERSIST", "key", "ZSet"},
+	{"ZPEXPIRE", "key milliseconds", "ZSet"},
+	{"ZPEXPIREAT", "key milliseconds-timestamp", "ZSet"},
+	{"ZPTTL", "key", "ZSet"},
 	{"ZRANGE", "key start stop [WITHSCORES]", "ZSet"},
 	{"ZRANGEBYLEX", "key min max [LIMIT offset count]", "ZSet"},
 	{"ZRANGEBYSCORE", "key min max [WITHSCORES] [LIMIT offset count]", "ZSet"},
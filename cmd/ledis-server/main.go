@@ -112,7 +112,16 @@ func main() {
 
 	go app.Run()
 
-	<-sc
+	// reload the config file by SIGHUP
+	for s := range sc {
+		if s != syscall.SIGHUP {
+			break
+		}
+
+		if err = app.ReloadConfig(); err != nil {
+			println("reload config error", err.Error())
+		}
+	}
 
 	println("ledis-server is closing")
 	app.Close()
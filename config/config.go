@@ -97,6 +97,23 @@ type TLS struct {
 	Key         string `toml:"key"`
 }
 
+// OutputBufferLimit limits the replies not sent to a client yet, the client
+// is closed if they are more than HardLimit bytes, or more than SoftLimit
+// bytes for SoftSeconds, 0 to disable the limit.
+type OutputBufferLimit struct {
+	HardLimit   int64 `toml:"hard_limit"`
+	SoftLimit   int64 `toml:"soft_limit"`
+	SoftSeconds int   `toml:"soft_seconds"`
+}
+
+// OutputBufferLimitConfig is the output buffer limits of the normal clients,
+// the slaves and the clients subscribing channels or patterns.
+type OutputBufferLimitConfig struct {
+	Normal OutputBufferLimit `toml:"normal"`
+	Slave  OutputBufferLimit `toml:"slave"`
+	PubSub OutputBufferLimit `toml:"pubsub"`
+}
+
 type AuthMethod func(c *Config, password string) bool
 
 type Config struct {
@@ -104,6 +121,10 @@ type Config struct {
 
 	AuthPassword string `toml:"auth_password"`
 
+	// ACLFile is the file of the ACL users, loaded when starting and by
+	// ACL LOAD, saved by ACL SAVE.
+	ACLFile string `toml:"acl_file"`
+
 	//AuthMethod custom authentication method
 	AuthMethod AuthMethod `toml:"-"`
 
@@ -116,6 +137,17 @@ type Config struct {
 
 	HttpAddr string `toml:"http_addr"`
 
+	// HttpMaxBodySize is the max bytes of the commands in the body of the
+	// http /pipeline and /multi requests.
+	HttpMaxBodySize int `toml:"http_max_body_size"`
+
+	MetricsAddr string `toml:"metrics_addr"`
+
+	// MemcacheAddr is the address of the memcached text protocol for the
+	// KV data of MemcacheDB, empty to disable.
+	MemcacheAddr string `toml:"memcache_addr"`
+	MemcacheDB   int    `toml:"memcache_db"`
+
 	SlaveOf string `toml:"slaveof"`
 
 	Readonly bool `toml:"readonly"`
@@ -135,6 +167,12 @@ type Config struct {
 
 	AccessLog string `toml:"access_log"`
 
+	// SlowlogLogSlowerThan is the threshold in microseconds to log the slow
+	// commands, 0 to log all the commands and negative to disable.
+	SlowlogLogSlowerThan int64 `toml:"slowlog_log_slower_than"`
+	// SlowlogMaxLen is the max number of the slow commands kept in memory.
+	SlowlogMaxLen int `toml:"slowlog_max_len"`
+
 	UseReplication bool              `toml:"use_replication"`
 	Replication    ReplicationConfig `toml:"replication"`
 
@@ -144,8 +182,25 @@ type Config struct {
 	ConnWriteBufferSize   int `toml:"conn_write_buffer_size"`
 	ConnKeepaliveInterval int `toml:"conn_keepalive_interval"`
 
+	// MaxClients is the max number of the RESP clients, 0 for no limit.
+	MaxClients int `toml:"maxclients"`
+	// Timeout closes the RESP clients idle for n seconds, 0 to disable,
+	// the slaves and the clients in the subscribed or monitor mode are
+	// never closed.
+	Timeout int `toml:"timeout"`
+
+	ClientOutputBufferLimit OutputBufferLimitConfig `toml:"client_output_buffer_limit"`
+
 	TTLCheckInterval int `toml:"ttl_check_interval"`
 
+	// UnifiedKeyspace makes del, exists, expire, expireat, ttl and persist
+	// act on all data types like Redis does, not only on KV.
+	UnifiedKeyspace bool `toml:"unified_keyspace"`
+
+	// NotifyKeyspaceEvents selects the keyspace notifications published
+	// with pub/sub, same as Redis notify-keyspace-events, empty to disable.
+	NotifyKeyspaceEvents string `toml:"notify_keyspace_events"`
+
 	//tls config
 	TLS TLS `toml:"tls"`
 }
@@ -199,6 +254,12 @@ func NewConfigDefault() *Config {
 	// disable access log
 	cfg.AccessLog = ""
 
+	cfg.SlowlogLogSlowerThan = 10000
+	cfg.SlowlogMaxLen = 128
+
+	cfg.ClientOutputBufferLimit.Slave = OutputBufferLimit{int64(256 * MB), int64(64 * MB), 60}
+	cfg.ClientOutputBufferLimit.PubSub = OutputBufferLimit{int64(32 * MB), int64(8 * MB), 60}
+
 	cfg.LMDB.MapSize = 20 * MB
 	cfg.LMDB.NoSync = true
 
@@ -239,6 +300,8 @@ func (cfg *Config) adjust() {
 	cfg.ConnWriteBufferSize = getDefault(4*KB, cfg.ConnWriteBufferSize)
 	cfg.TTLCheckInterval = getDefault(1, cfg.TTLCheckInterval)
 	cfg.Databases = getDefault(16, cfg.Databases)
+	cfg.SlowlogMaxLen = getDefault(128, cfg.SlowlogMaxLen)
+	cfg.HttpMaxBodySize = getDefault(64*MB, cfg.HttpMaxBodySize)
 }
 
 func (cfg *LevelDBConfig) adjust() {
@@ -299,6 +362,9 @@ func (cfg *Config) Rewrite() error {
 		return ErrNoConfigFile
 	}
 
+	cfg.m.RLock()
+	defer cfg.m.RUnlock()
+
 	return cfg.DumpFile(cfg.FileName)
 }
 
@@ -314,3 +380,66 @@ func (cfg *Config) SetReadonly(b bool) {
 	cfg.Readonly = b
 	cfg.m.Unlock()
 }
+
+func (cfg *Config) GetSlowlog() (slowerThan int64, maxLen int) {
+	cfg.m.RLock()
+	slowerThan, maxLen = cfg.SlowlogLogSlowerThan, cfg.SlowlogMaxLen
+	cfg.m.RUnlock()
+	return
+}
+
+func (cfg *Config) SetSlowlogLogSlowerThan(n int64) {
+	cfg.m.Lock()
+	cfg.SlowlogLogSlowerThan = n
+	cfg.m.Unlock()
+}
+
+func (cfg *Config) SetSlowlogMaxLen(n int) {
+	cfg.m.Lock()
+	cfg.SlowlogMaxLen = getDefault(128, n)
+	cfg.m.Unlock()
+}
+
+func (cfg *Config) GetAuthPassword() string {
+	cfg.m.RLock()
+	p := cfg.AuthPassword
+	cfg.m.RUnlock()
+	return p
+}
+
+func (cfg *Config) GetAccessLog() string {
+	cfg.m.RLock()
+	p := cfg.AccessLog
+	cfg.m.RUnlock()
+	return p
+}
+
+func (cfg *Config) GetTTLCheckInterval() int {
+	cfg.m.RLock()
+	n := cfg.TTLCheckInterval
+	cfg.m.RUnlock()
+	return n
+}
+
+// GetClientLimits returns the max number of the clients and the idle timeout.
+func (cfg *Config) GetClientLimits() (maxClients int, timeout int) {
+	cfg.m.RLock()
+	maxClients, timeout = cfg.MaxClients, cfg.Timeout
+	cfg.m.RUnlock()
+	return
+}
+
+func (cfg *Config) GetClientOutputBufferLimit() OutputBufferLimitConfig {
+	cfg.m.RLock()
+	l := cfg.ClientOutputBufferLimit
+	cfg.m.RUnlock()
+	return l
+}
+
+// GetReplicationSync returns the settings to wait for the slaves.
+func (cfg *Config) GetReplicationSync() (sync bool, waitSyncTime int, waitMaxSlaveAcks int) {
+	cfg.m.RLock()
+	sync, waitSyncTime, waitMaxSlaveAcks = cfg.Replication.Sync, cfg.Replication.WaitSyncTime, cfg.Replication.WaitMaxSlaveAcks
+	cfg.m.RUnlock()
+	return
+}
@@ -40,3 +40,76 @@ func TestConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigParams(t *testing.T) {
+	cfg := NewConfigDefault()
+
+	if params := cfg.Get("databases"); !reflect.DeepEqual(params, []Param{{"databases", "16"}}) {
+		t.Fatal(params)
+	}
+
+	if params := cfg.Get("replication.wait-*"); !reflect.DeepEqual(params, []Param{
+		{"replication.wait-sync-time", "500"},
+		{"replication.wait-max-slave-acks", "2"},
+	}) {
+		t.Fatal(params)
+	}
+
+	if params := cfg.Get("*"); len(params) < 50 {
+		t.Fatal(len(params))
+	}
+
+	if params := cfg.Get("no-such-param"); len(params) != 0 {
+		t.Fatal(params)
+	}
+
+	for _, name := range SettableParams {
+		if len(cfg.Get(name)) != 1 {
+			t.Fatal(name)
+		}
+	}
+
+	if err := cfg.Set("replication.sync", "yes"); err != nil {
+		t.Fatal(err)
+	} else if !cfg.Replication.Sync {
+		t.Fatal("must sync")
+	}
+
+	if err := cfg.Set("TTL-check-interval", "10"); err != nil {
+		t.Fatal(err)
+	} else if cfg.GetTTLCheckInterval() != 10 {
+		t.Fatal(cfg.TTLCheckInterval)
+	}
+
+	if err := cfg.Set("ttl-check-interval", "0"); err != ErrConfigValue {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Set("readonly", "maybe"); err != ErrConfigValue {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Set("databases", "1"); err != ErrConfigParam {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Set("maxclients", "100"); err != nil {
+		t.Fatal(err)
+	} else if n, _ := cfg.GetClientLimits(); n != 100 {
+		t.Fatal(n)
+	}
+
+	if err := cfg.Set("client-output-buffer-limit.pubsub.hard-limit", "1024"); err != nil {
+		t.Fatal(err)
+	} else if l := cfg.GetClientOutputBufferLimit(); l.PubSub.HardLimit != 1024 || l.PubSub.SoftSeconds != 60 {
+		t.Fatal(l.PubSub)
+	}
+
+	if err := cfg.Set("client-output-buffer-limit.master.hard-limit", "1024"); err != ErrConfigParam {
+		t.Fatal(err)
+	} else if err = cfg.Set("client-output-buffer-limit.slave.soft-seconds", "-1"); err != ErrConfigValue {
+		t.Fatal(err)
+	} else if err = cfg.Set("client-output-buffer-limit.normal.bogus", "-1"); err != ErrConfigParam {
+		t.Fatal(err)
+	}
+}
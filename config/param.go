@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrConfigParam = errors.New("Unsupported CONFIG parameter")
+	ErrConfigValue = errors.New("Invalid CONFIG value")
+)
+
+// Param is a config parameter, the name is the toml key with "-" instead
+// of "_", and the key in a section is prefixed with the section name and
+// ".", e.g. slowlog-max-len and replication.wait-sync-time.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// SettableParams are the parameters which can be changed at runtime by Set.
+var SettableParams = []string{
+	"auth-password",
+	"readonly",
+	"access-log",
+	"slowlog-log-slower-than",
+	"slowlog-max-len",
+	"ttl-check-interval",
+	"maxclients",
+	"timeout",
+	"client-output-buffer-limit.normal.hard-limit",
+	"client-output-buffer-limit.normal.soft-limit",
+	"client-output-buffer-limit.normal.soft-seconds",
+	"client-output-buffer-limit.slave.hard-limit",
+	"client-output-buffer-limit.slave.soft-limit",
+	"client-output-buffer-limit.slave.soft-seconds",
+	"client-output-buffer-limit.pubsub.hard-limit",
+	"client-output-buffer-limit.pubsub.soft-limit",
+	"client-output-buffer-limit.pubsub.soft-seconds",
+	"replication.sync",
+	"replication.wait-sync-time",
+	"replication.wait-max-slave-acks",
+}
+
+func paramName(tag string) string {
+	return strings.Replace(tag, "_", "-", -1)
+}
+
+func walkParams(prefix string, v reflect.Value, fn func(name string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("toml")
+		if len(tag) == 0 || tag == "-" {
+			continue
+		}
+
+		name := prefix + paramName(tag)
+		if f := v.Field(i); f.Kind() == reflect.Struct {
+			walkParams(name+".", f, fn)
+		} else {
+			fn(name, f)
+		}
+	}
+}
+
+// Get returns the parameters matching the glob pattern, ordered as the fields.
+func (cfg *Config) Get(pattern string) []Param {
+	pattern = strings.ToLower(pattern)
+
+	cfg.m.RLock()
+	defer cfg.m.RUnlock()
+
+	var params []Param
+	walkParams("", reflect.ValueOf(cfg).Elem(), func(name string, v reflect.Value) {
+		if ok, _ := path.Match(pattern, name); ok {
+			params = append(params, Param{name, fmt.Sprint(v.Interface())})
+		}
+	})
+	return params
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true":
+		return true, nil
+	case "no", "false":
+		return false, nil
+	default:
+		return false, ErrConfigValue
+	}
+}
+
+func parseInt(value string, min int64) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < min {
+		return 0, ErrConfigValue
+	}
+	return n, nil
+}
+
+// Set changes the parameter in SettableParams at runtime, the change can be
+// saved by Rewrite.
+func (cfg *Config) Set(name string, value string) error {
+	var err error
+	var b bool
+	var n int64
+
+	cfg.m.Lock()
+	defer cfg.m.Unlock()
+
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "client-output-buffer-limit.") {
+		return cfg.setOutputBufferLimit(name, value)
+	}
+
+	switch name {
+	case "auth-password":
+		cfg.AuthPassword = value
+	case "readonly":
+		if b, err = parseBool(value); err == nil {
+			cfg.Readonly = b
+		}
+	case "access-log":
+		cfg.AccessLog = value
+	case "slowlog-log-slower-than":
+		if n, err = parseInt(value, -1<<63); err == nil {
+			cfg.SlowlogLogSlowerThan = n
+		}
+	case "slowlog-max-len":
+		if n, err = parseInt(value, 1); err == nil {
+			cfg.SlowlogMaxLen = int(n)
+		}
+	case "ttl-check-interval":
+		if n, err = parseInt(value, 1); err == nil {
+			cfg.TTLCheckInterval = int(n)
+		}
+	case "maxclients":
+		if n, err = parseInt(value, 0); err == nil {
+			cfg.MaxClients = int(n)
+		}
+	case "timeout":
+		if n, err = parseInt(value, 0); err == nil {
+			cfg.Timeout = int(n)
+		}
+	case "replication.sync":
+		if b, err = parseBool(value); err == nil {
+			cfg.Replication.Sync = b
+		}
+	case "replication.wait-sync-time":
+		if n, err = parseInt(value, 0); err == nil {
+			cfg.Replication.WaitSyncTime = int(n)
+		}
+	case "replication.wait-max-slave-acks":
+		if n, err = parseInt(value, 0); err == nil {
+			cfg.Replication.WaitMaxSlaveAcks = int(n)
+		}
+	default:
+		err = ErrConfigParam
+	}
+
+	return err
+}
+
+// setOutputBufferLimit sets client-output-buffer-limit.class.param, the lock
+// must be held.
+func (cfg *Config) setOutputBufferLimit(name string, value string) error {
+	var l *OutputBufferLimit
+
+	parts := strings.Split(name, ".")
+	if len(parts) != 3 {
+		return ErrConfigParam
+	}
+
+	switch parts[1] {
+	case "normal":
+		l = &cfg.ClientOutputBufferLimit.Normal
+	case "slave":
+		l = &cfg.ClientOutputBufferLimit.Slave
+	case "pubsub":
+		l = &cfg.ClientOutputBufferLimit.PubSub
+	default:
+		return ErrConfigParam
+	}
+
+	switch parts[2] {
+	case "hard-limit", "soft-limit", "soft-seconds":
+	default:
+		return ErrConfigParam
+	}
+
+	n, err := parseInt(value, 0)
+	if err != nil {
+		return err
+	}
+
+	switch parts[2] {
+	case "hard-limit":
+		l.HardLimit = n
+	case "soft-limit":
+		l.SoftLimit = n
+	case "soft-seconds":
+		l.SoftSeconds = int(n)
+	}
+	return nil
+}
@@ -15,7 +15,24 @@ type batch struct {
 
 	sync.Locker
 
-	//	tx *Tx
+	tx *Tx
+
+	// the key events fired after committed
+	events []KeyEvent
+
+	// the key events committed, fired after unlocked
+	committed []KeyEvent
+
+	// the changes of the key numbers applied after committed
+	deltas []keyDelta
+
+	// view hides the data deleted by delExpired before committed, so the
+	// following writes see the key deleted, nil in transaction
+	view *store.DeleteView
+
+	// hiding is set when the deletions are hidden in view
+	hiding bool
+	hidden [][]byte
 }
 
 func (b *batch) Commit() error {
@@ -23,18 +40,33 @@ func (b *batch) Commit() error {
 		return ErrWriteInROnly
 	}
 
-	return b.l.handleCommit(b.WriteBatch, b.WriteBatch)
-
-	// if b.tx == nil {
-	// 	return b.l.handleCommit(b.WriteBatch, b.WriteBatch)
-	// } else {
-	// 	if b.l.r != nil {
-	// 		if err := b.tx.data.Append(b.WriteBatch.BatchData()); err != nil {
-	// 			return err
-	// 		}
-	// 	}
-	// 	return b.WriteBatch.Commit()
-	// }
+	events := b.events
+	b.events = nil
+
+	deltas := b.deltas
+	b.deltas = nil
+
+	if b.tx == nil {
+		b.l.touchWatched(b.WriteBatch)
+		deltas = b.l.ks.stage(b.WriteBatch, deltas)
+		if err := b.l.handleCommit(b.WriteBatch, b.WriteBatch); err != nil {
+			return err
+		}
+
+		b.unhide()
+		b.l.ks.apply(deltas)
+		b.committed = append(b.committed, events...)
+		return nil
+	}
+
+	// in transaction, the data is applied to the transaction and
+	// will be committed with the transaction together.
+	if err := b.tx.view.Apply(b.WriteBatch.BatchData()); err != nil {
+		return err
+	}
+	b.tx.events = append(b.tx.events, events...)
+	b.tx.deltas = append(b.tx.deltas, deltas...)
+	return b.WriteBatch.Rollback()
 }
 
 func (b *batch) Lock() {
@@ -42,8 +74,18 @@ func (b *batch) Lock() {
 }
 
 func (b *batch) Unlock() {
+	b.events = nil
+	b.deltas = nil
 	b.WriteBatch.Rollback()
+	b.unhide()
+
+	events := b.committed
+	b.committed = nil
+
 	b.Locker.Unlock()
+
+	// the handlers may block, never call them with the locks held
+	b.l.fireKeyEvents(events)
 }
 
 func (b *batch) Put(key []byte, value []byte) {
@@ -52,6 +94,21 @@ func (b *batch) Put(key []byte, value []byte) {
 
 func (b *batch) Delete(key []byte) {
 	b.WriteBatch.Delete(key)
+
+	if b.hiding {
+		key = append([]byte{}, key...)
+		b.view.Hide(key)
+		b.hidden = append(b.hidden, key)
+	}
+}
+
+// unhide shows the hidden keys again after the deletions are committed or
+// rolled back.
+func (b *batch) unhide() {
+	if len(b.hidden) > 0 {
+		b.view.Unhide(b.hidden)
+		b.hidden = nil
+	}
 }
 
 type dbBatchLocker struct {
@@ -69,11 +126,12 @@ func (l *dbBatchLocker) Unlock() {
 	l.wrLock.RUnlock()
 }
 
-// type txBatchLocker struct {
-// }
+// txBatchLocker does nothing, the transaction holds the write lock.
+type txBatchLocker struct {
+}
 
-// func (l *txBatchLocker) Lock()   {}
-// func (l *txBatchLocker) Unlock() {}
+func (l *txBatchLocker) Lock()   {}
+func (l *txBatchLocker) Unlock() {}
 
 // type multiBatchLocker struct {
 // }
@@ -81,13 +139,15 @@ func (l *dbBatchLocker) Unlock() {
 // func (l *multiBatchLocker) Lock()   {}
 // func (l *multiBatchLocker) Unlock() {}
 
-func (l *Ledis) newBatch(wb *store.WriteBatch, locker sync.Locker) *batch {
+func (l *Ledis) newBatch(wb *store.WriteBatch, locker sync.Locker, tx *Tx) *batch {
 	b := new(batch)
 	b.l = l
 	b.WriteBatch = wb
 
 	b.Locker = locker
 
+	b.tx = tx
+
 	return b
 }
 
@@ -0,0 +1,330 @@
+package ledis
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/siddontang/go/snappy"
+	"github.com/siddontang/ledisdb/rpl"
+	"github.com/siddontang/ledisdb/store"
+)
+
+var errChangeKey = errors.New("invalid change key")
+
+// For the operation of the change event.
+const (
+	// ChangePut puts the value of the key, hash field, list element or set member.
+	ChangePut = "put"
+	// ChangeDelete deletes the key, hash field, list element or set member.
+	ChangeDelete = "delete"
+	// ChangeExpire sets the expiration time of the key.
+	ChangeExpire = "expire"
+	// ChangePersist removes the expiration time of the key.
+	ChangePersist = "persist"
+)
+
+// ChangeEvent is a typed change decoded from the replication log.
+//
+// One write operation may have many change events, e.g. HMSET puts many fields,
+// and the internal meta data like the size of the hash is not included.
+type ChangeEvent struct {
+	LogID uint64
+
+	// CreateTime is the unix time in seconds when the log was created.
+	CreateTime uint32
+
+	// Index is the index of the DB.
+	Index int
+
+	DataType DataType
+
+	// Op is ChangePut, ChangeDelete, ChangeExpire or ChangePersist.
+	Op string
+
+	Key []byte
+
+	// Field is the field of the hash, or the member of the set and zset.
+	Field []byte
+
+	// Seq is the sequence of the list element, a larger sequence is
+	// closer to the tail of the list.
+	Seq int32
+
+	// Value is the new value of the kv, hash field and list element.
+	Value []byte
+
+	// Score is the new score of the zset member.
+	Score float64
+
+	// ExpireAt is the expiration time in milliseconds for ChangeExpire.
+	ExpireAt int64
+}
+
+// MarshalJSON encodes the change event as a JSON object. JSON strings can not
+// keep the bytes not valid UTF-8, so if any of the key, field and value is not
+// valid UTF-8, all of them are encoded in standard base64 and the object has
+// the "encoding":"base64" field.
+func (e ChangeEvent) MarshalJSON() ([]byte, error) {
+	b64 := !utf8.Valid(e.Key) || !utf8.Valid(e.Field) || !utf8.Valid(e.Value)
+	return e.marshalJSON(b64)
+}
+
+// MarshalJSONBase64 is like MarshalJSON, but always encodes the key, field and
+// value in base64, so the readers need not check the encoding field.
+func (e ChangeEvent) MarshalJSONBase64() ([]byte, error) {
+	return e.marshalJSON(true)
+}
+
+func (e ChangeEvent) marshalJSON(b64 bool) ([]byte, error) {
+	str := func(b []byte) string {
+		if b64 {
+			return base64.StdEncoding.EncodeToString(b)
+		}
+		return string(b)
+	}
+
+	v := struct {
+		LogID      uint64  `json:"log_id"`
+		CreateTime uint32  `json:"create_time"`
+		Index      int     `json:"db"`
+		DataType   string  `json:"type"`
+		Op         string  `json:"op"`
+		Encoding   string  `json:"encoding,omitempty"`
+		Key        string  `json:"key"`
+		Field      *string `json:"field,omitempty"`
+		Seq        int32   `json:"seq,omitempty"`
+		Value      *string `json:"value,omitempty"`
+		Score      *string `json:"score,omitempty"`
+		ExpireAt   int64   `json:"expire_at,omitempty"`
+	}{
+		LogID:      e.LogID,
+		CreateTime: e.CreateTime,
+		Index:      e.Index,
+		DataType:   e.DataType.String(),
+		Op:         e.Op,
+		Key:        str(e.Key),
+		Seq:        e.Seq,
+		ExpireAt:   e.ExpireAt,
+	}
+
+	if b64 {
+		v.Encoding = "base64"
+	}
+
+	isMember := e.Op == ChangePut || e.Op == ChangeDelete
+	if isMember && (e.DataType == HASH || e.DataType == SET || e.DataType == ZSET) {
+		field := str(e.Field)
+		v.Field = &field
+	}
+
+	if e.Value != nil {
+		value := str(e.Value)
+		v.Value = &value
+	}
+
+	if e.Op == ChangePut && e.DataType == ZSET {
+		// JSON has no infinities, the score is a string like ZSCORE
+		score := string(AppendScore(nil, e.Score))
+		v.Score = &score
+	}
+
+	return json.Marshal(v)
+}
+
+// DecodeLog decodes the replication log to the change events.
+func DecodeLog(rl *rpl.Log) ([]ChangeEvent, error) {
+	var data []byte
+	if rl.Compression == 1 {
+		var err error
+		if data, err = snappy.Decode(nil, rl.Data); err != nil {
+			return nil, err
+		}
+	} else {
+		// the events refer to the data, and the log may be reused
+		data = append([]byte{}, rl.Data...)
+	}
+
+	bd, err := store.NewBatchData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := bd.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]ChangeEvent, 0, len(items))
+	for _, item := range items {
+		e := ChangeEvent{LogID: rl.ID, CreateTime: rl.CreateTime}
+		if ok, err := decodeChange(item.Key, item.Value, &e); err != nil {
+			return nil, err
+		} else if ok {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+// DecodeLogs decodes all the encoded replication logs in data, like the logs
+// read by ReadLogsTo, to the change events.
+func DecodeLogs(data []byte) ([]ChangeEvent, error) {
+	var events []ChangeEvent
+
+	r := bytes.NewReader(data)
+	for {
+		rl := new(rpl.Log)
+		if err := rl.Decode(r); err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		es, err := DecodeLog(rl)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, es...)
+	}
+}
+
+// ReadChanges reads the change events of the logs from startLogID, if no logs,
+// it waits the new logs until timeout seconds. The returned nextLogID can be
+// used as the startLogID for the next reading.
+func (l *Ledis) ReadChanges(startLogID uint64, timeout int, quitCh chan struct{}) (events []ChangeEvent, nextLogID uint64, err error) {
+	if !l.ReplicationUsed() {
+		return nil, 0, ErrRplNotSupport
+	}
+
+	var buf bytes.Buffer
+	if _, nextLogID, err = l.ReadLogsToTimeout(startLogID, &buf, timeout, quitCh); err != nil {
+		return
+	}
+
+	events, err = DecodeLogs(buf.Bytes())
+	return
+}
+
+// TailChanges calls fn with the change events of the logs from startLogID in order,
+// and waits for the new logs, until quitCh is closed or fn returns an error.
+func (l *Ledis) TailChanges(startLogID uint64, quitCh chan struct{}, fn func(e *ChangeEvent) error) error {
+	for {
+		select {
+		case <-quitCh:
+			return nil
+		default:
+		}
+
+		events, nextLogID, err := l.ReadChanges(startLogID, 1, quitCh)
+		if err != nil {
+			return err
+		}
+
+		for i := range events {
+			if err = fn(&events[i]); err != nil {
+				return err
+			}
+		}
+
+		startLogID = nextLogID
+	}
+}
+
+// decodeChange decodes the store key and value to the change event, it returns
+// false if the key is the internal meta data.
+func decodeChange(ek []byte, value []byte, e *ChangeEvent) (bool, error) {
+	index, pos, err := decodeDBIndex(ek)
+	if err != nil {
+		return false, err
+	} else if pos >= len(ek) {
+		return false, errChangeKey
+	}
+
+	e.Index = index
+
+	dataType := ek[pos]
+	pos++
+
+	if value == nil {
+		e.Op = ChangeDelete
+	} else {
+		e.Op = ChangePut
+	}
+
+	switch dataType {
+	case KVType:
+		e.DataType = KV
+		e.Key = ek[pos:]
+		e.Value = value
+		return true, nil
+	case HashType, ListType, ZSetType, SetType:
+	case ExpMetaType:
+		if pos+1 > len(ek) {
+			return false, errChangeKey
+		}
+
+		e.DataType = getDataType(ek[pos])
+		e.Key = ek[pos+1:]
+
+		if value == nil {
+			e.Op = ChangePersist
+		} else {
+			e.Op = ChangeExpire
+			if e.ExpireAt, err = Int64(value, nil); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		// size, meta, score index and expiration time index
+		return false, nil
+	}
+
+	if pos+2 > len(ek) {
+		return false, errChangeKey
+	}
+	keyLen := int(binary.BigEndian.Uint16(ek[pos:]))
+	pos += 2
+	if pos+keyLen > len(ek) {
+		return false, errChangeKey
+	}
+	e.Key = ek[pos : pos+keyLen]
+	pos += keyLen
+
+	switch dataType {
+	case ListType:
+		if pos+4 != len(ek) {
+			return false, errChangeKey
+		}
+		e.DataType = LIST
+		e.Seq = int32(binary.BigEndian.Uint32(ek[pos:]))
+		e.Value = value
+		return true, nil
+	case HashType:
+		e.DataType = HASH
+		e.Value = value
+	case SetType:
+		e.DataType = SET
+	case ZSetType:
+		e.DataType = ZSET
+		if value != nil {
+			if e.Score, err = Float64(value, nil); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	// the separator before the field or member
+	if pos+1 > len(ek) {
+		return false, errChangeKey
+	}
+	e.Field = ek[pos+1:]
+
+	return true, nil
+}
@@ -0,0 +1,193 @@
+package ledis
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestChangeEvents(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_cdc"
+	cfg.UseReplication = true
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(1)
+
+	lastID, _ := l.r.LastLogID()
+
+	key := []byte("cdc_key")
+	db.Set(key, []byte("v"))
+	db.HSet(key, []byte("f"), []byte("hv"))
+	db.RPush(key, []byte("lv"))
+	db.SAdd(key, []byte("m"))
+	db.ZAdd(key, ScorePair{0, []byte("zm")})
+	db.Expire(key, 100)
+	db.Del(key)
+
+	events, nextLogID, err := l.ReadChanges(lastID+1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := l.r.LastLogID(); nextLogID != id+1 {
+		t.Fatal(nextLogID, id)
+	}
+
+	expected := []struct {
+		dataType DataType
+		op       string
+		field    string
+		value    string
+	}{
+		{KV, ChangePut, "", "v"},
+		{HASH, ChangePut, "f", "hv"},
+		{LIST, ChangePut, "", "lv"},
+		{SET, ChangePut, "m", ""},
+		{ZSET, ChangePut, "zm", ""},
+		{KV, ChangeExpire, "", ""},
+		{KV, ChangeDelete, "", ""},
+		{KV, ChangePersist, "", ""},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatal(len(events), events)
+	}
+
+	for i, e := range events {
+		ex := expected[i]
+		if e.Index != 1 || string(e.Key) != "cdc_key" || e.DataType != ex.dataType || e.Op != ex.op ||
+			string(e.Field) != ex.field || string(e.Value) != ex.value {
+			t.Fatalf("%d: %v", i, e)
+		}
+
+		if e.LogID <= lastID || e.LogID >= nextLogID {
+			t.Fatalf("%d: invalid log id %d", i, e.LogID)
+		}
+	}
+
+	if events[2].Seq == 0 {
+		t.Fatal("list element must have the sequence")
+	}
+
+	if events[5].ExpireAt == 0 {
+		t.Fatal("expire must have the expiration time")
+	}
+
+	b, err := json.Marshal(events[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["type"] != "ZSET" || m["op"] != "put" || m["key"] != "cdc_key" || m["field"] != "zm" || m["score"] != "0" || m["db"] != float64(1) {
+		t.Fatal(string(b))
+	} else if _, ok := m["encoding"]; ok {
+		t.Fatal(string(b))
+	}
+
+	// the binary data is in base64, so it can be replayed
+	e := ChangeEvent{DataType: HASH, Op: ChangePut, Key: []byte("k"), Field: []byte{0, 0xff}, Value: []byte("v")}
+	for _, marshal := range []func() ([]byte, error){e.MarshalJSON, e.MarshalJSONBase64} {
+		if b, err = marshal(); err != nil {
+			t.Fatal(err)
+		}
+
+		var v struct {
+			Encoding string
+			Key      string
+			Field    string
+			Value    string
+		}
+		if err = json.Unmarshal(b, &v); err != nil {
+			t.Fatal(err)
+		} else if v.Encoding != "base64" {
+			t.Fatal(string(b))
+		}
+
+		key, _ := base64.StdEncoding.DecodeString(v.Key)
+		field, _ := base64.StdEncoding.DecodeString(v.Field)
+		value, _ := base64.StdEncoding.DecodeString(v.Value)
+		if !bytes.Equal(key, e.Key) || !bytes.Equal(field, e.Field) || !bytes.Equal(value, e.Value) {
+			t.Fatal(string(b))
+		}
+	}
+
+	// tail from the beginning until all the events are read
+	n := 0
+	quit := make(chan struct{})
+	err = l.TailChanges(lastID+1, quit, func(e *ChangeEvent) error {
+		n++
+		if n == len(expected) {
+			close(quit)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if n != len(expected) {
+		t.Fatal(n)
+	}
+}
+
+func TestChangeEventInfScore(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_cdc_inf"
+	cfg.UseReplication = true
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	lastID, _ := l.r.LastLogID()
+
+	// ZADD k +inf m, JSON has no infinities
+	if _, err = db.ZAdd([]byte("k"), ScorePair{math.Inf(1), []byte("m")}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _, err := l.ReadChanges(lastID+1, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(events) != 1 {
+		t.Fatal(events)
+	}
+
+	b, err := json.Marshal(events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Key   string
+		Field string
+		Score string
+	}
+	if err = json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	} else if v.Key != "k" || v.Field != "m" || v.Score != "inf" {
+		t.Fatal(string(b))
+	}
+}
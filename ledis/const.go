@@ -47,20 +47,27 @@ const (
 
 // for backend store
 const (
-	NoneType   byte = 0
-	KVType     byte = 1
-	HashType   byte = 2
-	HSizeType  byte = 3
-	ListType   byte = 4
-	LMetaType  byte = 5
-	ZSetType   byte = 6
-	ZSizeType  byte = 7
-	ZScoreType byte = 8
+	NoneType  byte = 0
+	KVType    byte = 1
+	HashType  byte = 2
+	HSizeType byte = 3
+	ListType  byte = 4
+	LMetaType byte = 5
+	ZSetType  byte = 6
+	ZSizeType byte = 7
 	// BitType     byte = 9
 	// BitMetaType byte = 10
 	SetType   byte = 11
 	SSizeType byte = 12
 
+	/*
+		The zset score was an int64, now it is a float64 with an order-preserving encoding
+		and a new type (change 8 to 13).
+		You must run the ledis-upgrade-zset-score to upgrade db.
+	*/
+	ObsoleteZScoreType byte = 8
+	ZScoreType         byte = 13
+
 	maxDataType byte = 100
 
 	/*
@@ -68,8 +75,19 @@ const (
 		You must run the ledis-upgrade-ttl to upgrade db.
 	*/
 	ObsoleteExpTimeType byte = 101
-	ExpMetaType         byte = 102
-	ExpTimeType         byte = 103
+
+	/*
+		The TTL time was saved in seconds, now it is saved in milliseconds with new types (change 102 to 104, 103 to 105).
+		You must run the ledis-upgrade-ttl-ms to upgrade db.
+	*/
+	ObsoleteSecExpMetaType byte = 102
+	ObsoleteSecExpTimeType byte = 103
+
+	ExpMetaType byte = 104
+	ExpTimeType byte = 105
+
+	// KeyNumType saves the number of keys of a data type in a DB
+	KeyNumType byte = 106
 
 	MetaType byte = 201
 )
@@ -90,6 +108,7 @@ var TypeName = map[byte]string{
 	SSizeType:   "ssize",
 	ExpTimeType: "exptime",
 	ExpMetaType: "expmeta",
+	KeyNumType:  "keynum",
 }
 
 const (
@@ -210,6 +210,10 @@ func (l *Ledis) LoadDump(r io.Reader) (*DumpHead, error) {
 	deKeyBuf = nil
 	deValueBuf = nil
 
+	if err = l.loadKeyNums(); err != nil {
+		return nil, err
+	}
+
 	if l.r != nil {
 		if err := l.r.UpdateCommitID(h.CommitID); err != nil {
 			return nil, err
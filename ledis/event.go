@@ -91,7 +91,7 @@ func formatEventKey(buf []byte, k []byte) ([]byte, error) {
 		buf = append(buf, ' ')
 		buf = strconv.AppendQuote(buf, hack.String(m))
 		buf = append(buf, ' ')
-		buf = strconv.AppendInt(buf, score, 10)
+		buf = strconv.AppendFloat(buf, score, 'g', -1, 64)
 	case SetType:
 		key, member, err := db.sDecodeSetKey(k)
 		if err != nil {
@@ -0,0 +1,275 @@
+package ledis
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/store"
+)
+
+// DBKeyNum is the number of keys in a DB.
+type DBKeyNum struct {
+	Index int
+
+	// the number of keys of every data type, indexed by DataType
+	Num [ZSET + 1]int64
+}
+
+// Total returns the number of keys of all the data types.
+func (n *DBKeyNum) Total() int64 {
+	var total int64
+	for _, v := range n.Num {
+		total += v
+	}
+	return total
+}
+
+type keyDelta struct {
+	index    int
+	dataType DataType
+	delta    int64
+}
+
+// keyspace keeps the number of keys of every DB. The numbers are changed by
+// the writes when a key is created or deleted, i.e. its size changes from
+// 0 to n or from n to 0, and persisted with the writes in the same batch.
+type keyspace struct {
+	sync.Mutex
+
+	nums map[int]*DBKeyNum
+}
+
+func newKeyspace() *keyspace {
+	ks := new(keyspace)
+	ks.nums = make(map[int]*DBKeyNum)
+	return ks
+}
+
+func (ks *keyspace) num(index int) *DBKeyNum {
+	n, ok := ks.nums[index]
+	if !ok {
+		n = &DBKeyNum{Index: index}
+		ks.nums[index] = n
+	}
+	return n
+}
+
+// stage merges the deltas and writes the changed numbers to the write batch,
+// the merged deltas are applied after committed. The writes of a data type
+// in a DB hold its batch lock, so the numbers are not changed by others
+// before applied.
+func (ks *keyspace) stage(wb *store.WriteBatch, deltas []keyDelta) []keyDelta {
+	var merged []keyDelta
+	for _, d := range deltas {
+		found := false
+		for i := range merged {
+			if merged[i].index == d.index && merged[i].dataType == d.dataType {
+				merged[i].delta += d.delta
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, d)
+		}
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+
+	for _, d := range merged {
+		if d.delta != 0 {
+			n := ks.num(d.index).Num[d.dataType] + d.delta
+			wb.Put(encodeKeyNumKey(d.index, d.dataType), PutInt64(n))
+		}
+	}
+	return merged
+}
+
+func (ks *keyspace) apply(deltas []keyDelta) {
+	if len(deltas) == 0 {
+		return
+	}
+
+	ks.Lock()
+	defer ks.Unlock()
+
+	for _, d := range deltas {
+		ks.num(d.index).Num[d.dataType] += d.delta
+	}
+}
+
+func (ks *keyspace) reset() {
+	ks.Lock()
+	ks.nums = make(map[int]*DBKeyNum)
+	ks.Unlock()
+}
+
+// incrKeyNum changes the number of keys when the write batch is committed,
+// it is called when a key is created or deleted.
+func (db *DB) incrKeyNum(t *batch, dataType DataType, delta int64) {
+	t.deltas = append(t.deltas, keyDelta{db.index, dataType, delta})
+}
+
+func encodeKeyNumKey(index int, dataType DataType) []byte {
+	buf := make([]byte, binary.MaxVarintLen64+2)
+	n := binary.PutUvarint(buf, uint64(index))
+	buf[n] = KeyNumType
+	buf[n+1] = byte(dataType)
+	return buf[0 : n+2]
+}
+
+func decodeKeyNumKey(ek []byte) (int, DataType, bool) {
+	index, pos, err := decodeDBIndex(ek)
+	if err != nil || pos+2 != len(ek) || ek[pos] != KeyNumType || DataType(ek[pos+1]) > ZSET {
+		return 0, 0, false
+	}
+	return index, DataType(ek[pos+1]), true
+}
+
+// keyNumReplayer updates the key numbers written in a replicated batch.
+type keyNumReplayer struct {
+	ks *keyspace
+}
+
+func (r *keyNumReplayer) Put(key, value []byte) {
+	index, dataType, ok := decodeKeyNumKey(key)
+	if !ok {
+		return
+	}
+
+	n, err := Int64(value, nil)
+	if err != nil {
+		log.Errorf("invalid key number of db %d %s: %s", index, dataType, err.Error())
+		return
+	}
+
+	r.ks.Lock()
+	r.ks.num(index).Num[dataType] = n
+	r.ks.Unlock()
+}
+
+func (r *keyNumReplayer) Delete(key []byte) {
+	if index, dataType, ok := decodeKeyNumKey(key); ok {
+		r.ks.Lock()
+		r.ks.num(index).Num[dataType] = 0
+		r.ks.Unlock()
+	}
+}
+
+// getMetaDataType returns the data type of the store meta key which
+// exists if and only if the key exists.
+func getMetaDataType(storeDataType byte) (DataType, bool) {
+	switch storeDataType {
+	case KVType:
+		return KV, true
+	case LMetaType:
+		return LIST, true
+	case HSizeType:
+		return HASH, true
+	case SSizeType:
+		return SET, true
+	case ZSizeType:
+		return ZSET, true
+	default:
+		return 0, false
+	}
+}
+
+// loadKeyNums loads the persisted key numbers, the writes must be blocked.
+// The data written by the older versions has no key numbers, its keys are
+// counted by a scan once and the numbers are persisted.
+func (l *Ledis) loadKeyNums() error {
+	nums := make(map[int]*DBKeyNum)
+	found := false
+
+	for index := 0; index < l.cfg.Databases; index++ {
+		n := &DBKeyNum{Index: index}
+		for dataType := KV; dataType <= ZSET; dataType++ {
+			v, err := l.ldb.Get(encodeKeyNumKey(index, dataType))
+			if err != nil {
+				return err
+			} else if v == nil {
+				continue
+			}
+
+			found = true
+			if n.Num[dataType], err = Int64(v, nil); err != nil {
+				return err
+			}
+		}
+		nums[index] = n
+	}
+
+	if !found {
+		it := l.ldb.NewIterator()
+		it.SeekToFirst()
+		empty := !it.Valid()
+		it.Close()
+
+		if !empty {
+			log.Infof("count the keys of the data without the key numbers")
+			var err error
+			if nums, err = l.countKeys(); err != nil {
+				return err
+			}
+		}
+	}
+
+	l.ks.Lock()
+	l.ks.nums = nums
+	l.ks.Unlock()
+	return nil
+}
+
+// countKeys counts all the keys by scanning the meta keys and persists the
+// numbers, the writes must be blocked.
+func (l *Ledis) countKeys() (map[int]*DBKeyNum, error) {
+	nums := make(map[int]*DBKeyNum)
+
+	wb := l.ldb.NewWriteBatch()
+	defer wb.Rollback()
+
+	for index := 0; index < l.cfg.Databases; index++ {
+		db := &DB{}
+		db.setIndex(index)
+
+		n := &DBKeyNum{Index: index}
+		for _, metaType := range []byte{KVType, LMetaType, HSizeType, SSizeType, ZSizeType} {
+			min := append(append([]byte{}, db.indexVarBuf...), metaType)
+			max := append(append([]byte{}, db.indexVarBuf...), metaType+1)
+
+			dataType, _ := getMetaDataType(metaType)
+
+			it := l.ldb.RangeLimitIterator(min, max, store.RangeROpen, 0, -1)
+			for ; it.Valid(); it.Next() {
+				n.Num[dataType]++
+			}
+			it.Close()
+
+			wb.Put(encodeKeyNumKey(index, dataType), PutInt64(n.Num[dataType]))
+		}
+
+		nums[index] = n
+	}
+
+	return nums, wb.Commit()
+}
+
+// KeyNums returns the number of keys of the DBs which have keys, ordered
+// by the DB index.
+func (l *Ledis) KeyNums() []DBKeyNum {
+	l.ks.Lock()
+	nums := make([]DBKeyNum, 0, len(l.ks.nums))
+	for _, n := range l.ks.nums {
+		if n.Total() > 0 {
+			nums = append(nums, *n)
+		}
+	}
+	l.ks.Unlock()
+
+	sort.Slice(nums, func(i, j int) bool { return nums[i].Index < nums[j].Index })
+	return nums
+}
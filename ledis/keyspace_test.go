@@ -0,0 +1,128 @@
+package ledis
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestKeyNums(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_keyspace"
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := l.Select(0)
+	db2, _ := l.Select(2)
+
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("a"), []byte("2"))
+	db.MSet(KVPair{[]byte("b"), []byte("1")}, KVPair{[]byte("c"), []byte("1")}, KVPair{[]byte("c"), []byte("2")})
+	db.Del([]byte("c"), []byte("no_such_key"), []byte("c"))
+	db.Incr([]byte("d"))
+	db.PExpire([]byte("d"), 1)
+
+	db.HSet([]byte("a"), []byte("f1"), []byte("1"))
+	db.HSet([]byte("a"), []byte("f2"), []byte("1"))
+	db.RPush([]byte("l"), []byte("1"))
+	db.LPop([]byte("l"))
+	db.SAdd([]byte("s"), []byte("1"), []byte("2"))
+	db.ZAdd([]byte("z"), ScorePair{1, []byte("1")})
+
+	db2.Set([]byte("a"), []byte("1"))
+
+	// the expired key is deleted and counted with the following write
+	time.Sleep(5 * time.Millisecond)
+	db.Set([]byte("d"), []byte("1"))
+	db.Del([]byte("d"))
+
+	// the transaction is counted after committed
+	tx, err := db2.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Set([]byte("b"), []byte("1"))
+	tx.Del([]byte("a"))
+	tx.HSet([]byte("h"), []byte("f"), []byte("1"))
+	tx.Rollback()
+
+	tx, _ = db2.Begin()
+	tx.Set([]byte("b"), []byte("1"))
+	tx.Del([]byte("b"))
+	tx.HSet([]byte("h"), []byte("f"), []byte("1"))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []DBKeyNum{
+		{Index: 0, Num: [ZSET + 1]int64{KV: 2, HASH: 1, SET: 1, ZSET: 1}},
+		{Index: 2, Num: [ZSET + 1]int64{KV: 1, HASH: 1}},
+	}
+
+	if nums := l.KeyNums(); !reflect.DeepEqual(nums, expected) {
+		t.Fatal(nums)
+	} else if nums[0].Total() != 5 {
+		t.Fatal(nums[0].Total())
+	}
+
+	l.Close()
+
+	// loaded from the persisted numbers when opening
+	if l, err = Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if nums := l.KeyNums(); !reflect.DeepEqual(nums, expected) {
+		t.Fatal(nums)
+	}
+
+	// the data of the older versions has no key numbers, counted by scan
+	wb := l.ldb.NewWriteBatch()
+	for index := 0; index < cfg.Databases; index++ {
+		for dataType := KV; dataType <= ZSET; dataType++ {
+			wb.Delete(encodeKeyNumKey(index, dataType))
+		}
+	}
+	if err := wb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	if l, err = Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if nums := l.KeyNums(); !reflect.DeepEqual(nums, expected) {
+		t.Fatal(nums)
+	} else if v, err := l.ldb.Get(encodeKeyNumKey(2, HASH)); err != nil {
+		t.Fatal(err)
+	} else if n, _ := Int64(v, nil); n != 1 {
+		t.Fatal(n)
+	}
+
+	db2, _ = l.Select(2)
+	if _, err := db2.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if nums := l.KeyNums(); !reflect.DeepEqual(nums, expected[0:1]) {
+		t.Fatal(nums)
+	}
+
+	if err := l.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if nums := l.KeyNums(); len(nums) != 0 {
+		t.Fatal(nums)
+	}
+}
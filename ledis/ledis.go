@@ -34,6 +34,9 @@ type Ledis struct {
 	rDoneCh chan struct{}
 	rhs     []NewLogEventHandler
 
+	// key event handlers
+	khs []KeyEventHandler
+
 	wLock      sync.RWMutex //allow one write at same time
 	commitLock sync.Mutex   //allow one write commit at same time
 
@@ -41,6 +44,11 @@ type Ledis struct {
 
 	ttlCheckers  []*ttlChecker
 	ttlCheckerCh chan *ttlChecker
+
+	watchers *watchers
+
+	// the number of keys of every DB
+	ks *keyspace
 }
 
 // Open opens the Ledis with a config.
@@ -68,6 +76,10 @@ func Open(cfg *config.Config) (*Ledis, error) {
 
 	l.quit = make(chan struct{})
 
+	l.watchers = newWatchers()
+
+	l.ks = newKeyspace()
+
 	if l.ldb, err = store.Open(cfg); err != nil {
 		return nil, err
 	}
@@ -93,6 +105,13 @@ func Open(cfg *config.Config) (*Ledis, error) {
 
 	l.dbs = make(map[int]*DB, 16)
 
+	l.wLock.Lock()
+	err = l.loadKeyNums()
+	l.wLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
 	l.checkTTL()
 
 	return l, nil
@@ -149,6 +168,8 @@ func (l *Ledis) FlushAll() error {
 }
 
 func (l *Ledis) flushAll() error {
+	l.touchAllWatched()
+
 	it := l.ldb.NewIterator()
 	defer it.Close()
 
@@ -175,6 +196,8 @@ func (l *Ledis) flushAll() error {
 		return err
 	}
 
+	l.ks.reset()
+
 	if l.r != nil {
 		if err := l.r.Clear(); err != nil {
 			log.Fatalf("flush all replication clear error: %s", err.Error())
@@ -209,12 +232,19 @@ func (l *Ledis) checkTTL() {
 	go func() {
 		defer l.wg.Done()
 
-		tick := time.NewTicker(time.Duration(l.cfg.TTLCheckInterval) * time.Second)
+		interval := l.cfg.GetTTLCheckInterval()
+		tick := time.NewTicker(time.Duration(interval) * time.Second)
 		defer tick.Stop()
 
 		for {
 			select {
 			case <-tick.C:
+				// the interval can be changed at runtime
+				if n := l.cfg.GetTTLCheckInterval(); n != interval && n > 0 {
+					interval = n
+					tick.Reset(time.Duration(interval) * time.Second)
+				}
+
 				if l.IsReadOnly() {
 					break
 				}
@@ -239,6 +269,18 @@ func (l *Ledis) StoreStat() *store.Stat {
 	return l.ldb.Stat()
 }
 
+// PauseWrites blocks all the writes, including the transactions and the
+// expiration, until the returned function is called. It waits for the
+// running writes to finish.
+func (l *Ledis) PauseWrites() (resume func()) {
+	l.wLock.Lock()
+
+	var once sync.Once
+	return func() {
+		once.Do(l.wLock.Unlock)
+	}
+}
+
 // CompactStore compacts the backend storage.
 func (l *Ledis) CompactStore() error {
 	l.wLock.Lock()
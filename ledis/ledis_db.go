@@ -32,6 +32,9 @@ type DB struct {
 
 	sdb *store.DB
 
+	// view hides the expired data deleted by the uncommitted writes
+	view *store.DeleteView
+
 	bucket ibucket
 
 	index int
@@ -51,6 +54,9 @@ type DB struct {
 	ttlChecker *ttlChecker
 
 	lbkeys *lBlockKeys
+
+	// tx is not nil if the DB is used in a transaction
+	tx *Tx
 }
 
 func (l *Ledis) newDB(index int) *DB {
@@ -60,7 +66,8 @@ func (l *Ledis) newDB(index int) *DB {
 
 	d.sdb = l.ldb
 
-	d.bucket = d.sdb
+	d.view = d.sdb.NewDeleteView()
+	d.bucket = d.view
 
 	//	d.status = DBAutoCommit
 	d.setIndex(index)
@@ -128,7 +135,9 @@ func (db *DB) newTTLChecker() *ttlChecker {
 }
 
 func (db *DB) newBatch() *batch {
-	return db.l.newBatch(db.bucket.NewWriteBatch(), &dbBatchLocker{l: &sync.Mutex{}, wrLock: &db.l.wLock})
+	b := db.l.newBatch(db.bucket.NewWriteBatch(), &dbBatchLocker{l: &sync.Mutex{}, wrLock: &db.l.wLock}, nil)
+	b.view = db.view
+	return b
 }
 
 // Index gets the index of database.
@@ -189,7 +198,7 @@ func (db *DB) flushType(t *batch, dataType byte) (drop int64, err error) {
 	}
 
 	var keys [][]byte
-	keys, err = db.scanGeneric(metaDataType, nil, 1024, false, "", false)
+	keys, err = db.scanGeneric(metaDataType, nil, 1024, false, "", false, false)
 	for len(keys) != 0 || err != nil {
 		for _, key := range keys {
 			deleteFunc(t, key)
@@ -202,7 +211,7 @@ func (db *DB) flushType(t *batch, dataType byte) (drop int64, err error) {
 		}
 
 		drop += int64(len(keys))
-		keys, err = db.scanGeneric(metaDataType, nil, 1024, false, "", false)
+		keys, err = db.scanGeneric(metaDataType, nil, 1024, false, "", false, false)
 	}
 	return
 }
@@ -125,12 +125,12 @@ func TestFlush(t *testing.T) {
 	db1, _ := testLedis.Select(1)
 
 	db0.Set([]byte("a"), []byte("1"))
-	db0.ZAdd([]byte("zset_0"), ScorePair{int64(1), []byte("ma")})
-	db0.ZAdd([]byte("zset_0"), ScorePair{int64(2), []byte("mb")})
+	db0.ZAdd([]byte("zset_0"), ScorePair{1, []byte("ma")})
+	db0.ZAdd([]byte("zset_0"), ScorePair{2, []byte("mb")})
 
 	db1.Set([]byte("b"), []byte("2"))
 	db1.LPush([]byte("lst"), []byte("a1"), []byte("b2"))
-	db1.ZAdd([]byte("zset_0"), ScorePair{int64(3), []byte("mc")})
+	db1.ZAdd([]byte("zset_0"), ScorePair{3, []byte("mc")})
 
 	db1.FlushAll()
 
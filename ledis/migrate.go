@@ -80,7 +80,7 @@ func (db *DB) ZDump(key []byte) ([]byte, error) {
 	o := make(rdb.ZSet, len(v))
 	for i := 0; i < len(v); i++ {
 		o[i].Member = v[i].Member
-		o[i].Score = float64(v[i].Score)
+		o[i].Score = v[i].Score
 	}
 
 	return rdb.Dump(o)
@@ -93,15 +93,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		return err
 	}
 
-	//ttl is milliseconds, but we only support seconds
-	//later may support milliseconds
-	if ttl > 0 {
-		ttl = ttl / 1e3
-		if ttl == 0 {
-			ttl = 1
-		}
-	}
-
+	//ttl is milliseconds
 	switch value := d.(type) {
 	case rdb.String:
 		if _, err = db.Del(key); err != nil {
@@ -113,7 +105,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.Expire(key, ttl); err != nil {
+			if _, err = db.PExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -133,7 +125,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.HExpire(key, ttl); err != nil {
+			if _, err = db.HPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -148,7 +140,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.LExpire(key, ttl); err != nil {
+			if _, err = db.LPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -160,7 +152,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 
 		sp := make([]ScorePair, len(value))
 		for i := 0; i < len(value); i++ {
-			sp[i] = ScorePair{int64(value[i].Score), value[i].Member}
+			sp[i] = ScorePair{value[i].Score, value[i].Member}
 		}
 
 		if _, err = db.ZAdd(key, sp...); err != nil {
@@ -168,7 +160,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.ZExpire(key, ttl); err != nil {
+			if _, err = db.ZPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
@@ -183,7 +175,7 @@ func (db *DB) Restore(key []byte, ttl int64, data []byte) error {
 		}
 
 		if ttl > 0 {
-			if _, err = db.SExpire(key, ttl); err != nil {
+			if _, err = db.SPExpire(key, ttl); err != nil {
 				return err
 			}
 		}
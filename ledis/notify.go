@@ -0,0 +1,60 @@
+package ledis
+
+// KeyEvent is the event of a key modified by a write operation or expired.
+type KeyEvent struct {
+	// Index is the index of the DB.
+	Index int
+
+	DataType DataType
+
+	// Event is the name of the operation like Redis keyspace notifications,
+	// e.g. set, del, hset, lpush, expire and expired.
+	Event string
+
+	Key []byte
+}
+
+// KeyEventHandler handles the key event. It is called after the write is
+// committed and its locks are released, and before the write returns, so
+// a slow handler only delays the write firing the event.
+type KeyEventHandler func(e *KeyEvent)
+
+// AddKeyEventHandler adds the handler for the key events, it must be called
+// before using the Ledis.
+func (l *Ledis) AddKeyEventHandler(h KeyEventHandler) {
+	l.khs = append(l.khs, h)
+}
+
+func (l *Ledis) fireKeyEvents(events []KeyEvent) {
+	for i := range events {
+		for _, h := range l.khs {
+			h(&events[i])
+		}
+	}
+}
+
+// notify adds the event to the batch, the event is fired after the batch
+// is committed, or after the transaction is committed.
+func (db *DB) notify(t *batch, dataType DataType, event string, key []byte) {
+	if len(db.l.khs) == 0 {
+		return
+	}
+
+	t.events = append(t.events, KeyEvent{db.index, dataType, event, append([]byte{}, key...)})
+}
+
+// getDataType returns the data type of the store data type used by the expiration.
+func getDataType(expDataType byte) DataType {
+	switch expDataType {
+	case ListType:
+		return LIST
+	case HashType:
+		return HASH
+	case SetType:
+		return SET
+	case ZSetType:
+		return ZSET
+	default:
+		return KV
+	}
+}
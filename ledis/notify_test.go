@@ -0,0 +1,105 @@
+package ledis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestKeyEvent(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_key_event"
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var events []string
+	l.AddKeyEventHandler(func(e *KeyEvent) {
+		events = append(events, e.DataType.String()+" "+e.Event+" "+string(e.Key))
+	})
+
+	check := func(expected ...string) {
+		if len(events) != len(expected) {
+			t.Fatalf("%v != %v", events, expected)
+		}
+		for i := range events {
+			if events[i] != expected[i] {
+				t.Fatalf("%v != %v", events, expected)
+			}
+		}
+		events = nil
+	}
+
+	db, _ := l.Select(0)
+
+	key := []byte("a")
+
+	db.Set(key, []byte("1"))
+	db.Incr(key)
+	db.Del(key)
+	check("KV set a", "KV incrby a", "KV del a")
+
+	db.HSet(key, []byte("f"), []byte("v"))
+	db.HDel(key, []byte("f"))
+	db.HDel(key, []byte("f"))
+	check("HASH hset a", "HASH hdel a", "HASH del a")
+
+	db.RPush(key, []byte("1"))
+	db.LPop(key)
+	check("LIST rpush a", "LIST lpop a", "LIST del a")
+
+	db.SAdd(key, []byte("1"))
+	db.SAdd(key, []byte("1"))
+	check("SET sadd a")
+
+	db.ZAdd(key, ScorePair{1, []byte("1")})
+	db.ZPExpire(key, 1)
+	check("ZSET zadd a", "ZSET expire a")
+
+	time.Sleep(5 * time.Millisecond)
+	db.ZAdd(key, ScorePair{1, []byte("1")})
+	check("ZSET expired a", "ZSET zadd a")
+
+	// fired after the transaction committed
+	tx, _ := db.Begin()
+	tx.Set(key, []byte("1"))
+	tx.SRem(key, []byte("1"))
+	check()
+	tx.Commit()
+	check("KV set a", "SET srem a", "SET del a")
+
+	tx, _ = db.Begin()
+	tx.Set(key, []byte("1"))
+	tx.Rollback()
+	check()
+
+	// the handlers are called without the locks, so they can write
+	l.AddKeyEventHandler(func(e *KeyEvent) {
+		if string(e.Key) == "a" {
+			db.Set([]byte("b"), e.Key)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		db.Set(key, []byte("2"))
+		tx, _ := db.Begin()
+		tx.Set(key, []byte("3"))
+		tx.Commit()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler is called with the locks held")
+	}
+	check("KV set a", "KV set b", "KV set a", "KV set b")
+}
@@ -55,18 +55,24 @@ func (l *Ledis) handleReplication() error {
 			}
 		}
 
-		if bd, err := store.NewBatchData(rl.Data); err != nil {
+		var bd *store.BatchData
+		if bd, err = store.NewBatchData(rl.Data); err != nil {
 			log.Errorf("decode batch log error %s", err.Error())
 			return err
-		} else if err = bd.Replay(l.rbatch); err != nil {
+		} else if err := bd.Replay(l.rbatch); err != nil {
 			log.Errorf("replay batch log error %s", err.Error())
 		}
 
+		l.touchWatched(l.rbatch)
+
 		l.commitLock.Lock()
 		if err = l.rbatch.Commit(); err != nil {
 			log.Errorf("commit log error %s", err.Error())
 		} else if err = l.r.UpdateCommitID(rl.ID); err != nil {
 			log.Errorf("update commit id error %s", err.Error())
+		} else {
+			// the key numbers are written in the batch by the master
+			bd.Replay(&keyNumReplayer{l.ks})
 		}
 
 		l.commitLock.Unlock()
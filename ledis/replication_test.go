@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/siddontang/ledisdb/config"
@@ -105,4 +106,9 @@ func TestReplication(t *testing.T) {
 	if err = checkLedisEqual(master, slave); err != nil {
 		t.Fatal(err)
 	}
+
+	// the key numbers are replicated with the writes
+	if m, s := master.KeyNums(), slave.KeyNums(); !reflect.DeepEqual(m, s) || m[0].Total() != 12 {
+		t.Fatal(m, s)
+	}
 }
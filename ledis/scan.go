@@ -17,7 +17,7 @@ func (db *DB) Scan(dataType DataType, cursor []byte, count int, inclusive bool,
 		return nil, err
 	}
 
-	return db.scanGeneric(storeDataType, cursor, count, inclusive, match, false)
+	return db.scanGeneric(storeDataType, cursor, count, inclusive, match, false, true)
 }
 
 // RevScan scans the data reversed. if inclusive is true, revscan range (-inf, cursor] else (inf, cursor)
@@ -27,7 +27,7 @@ func (db *DB) RevScan(dataType DataType, cursor []byte, count int, inclusive boo
 		return nil, err
 	}
 
-	return db.scanGeneric(storeDataType, cursor, count, inclusive, match, true)
+	return db.scanGeneric(storeDataType, cursor, count, inclusive, match, true, true)
 }
 
 func getDataStoreType(dataType DataType) (byte, error) {
@@ -49,6 +49,22 @@ func getDataStoreType(dataType DataType) (byte, error) {
 	return storeDataType, nil
 }
 
+// getExpDataType returns the data type used by the expiration of the store meta data type.
+func getExpDataType(storeDataType byte) byte {
+	switch storeDataType {
+	case LMetaType:
+		return ListType
+	case HSizeType:
+		return HashType
+	case SSizeType:
+		return SetType
+	case ZSizeType:
+		return ZSetType
+	default:
+		return storeDataType
+	}
+}
+
 func buildMatchRegexp(match string) (*regexp.Regexp, error) {
 	var err error
 	var r *regexp.Regexp
@@ -113,7 +129,7 @@ func checkScanCount(count int) int {
 }
 
 func (db *DB) scanGeneric(storeDataType byte, key []byte, count int,
-	inclusive bool, match string, reverse bool) ([][]byte, error) {
+	inclusive bool, match string, reverse bool, skipExpired bool) ([][]byte, error) {
 
 	r, err := buildMatchRegexp(match)
 	if err != nil {
@@ -137,6 +153,15 @@ func (db *DB) scanGeneric(storeDataType byte, key []byte, count int,
 		} else if r != nil && !r.Match(k) {
 			continue
 		} else {
+			if skipExpired {
+				if exp, err := db.expired(getExpDataType(storeDataType), k); err != nil {
+					it.Close()
+					return nil, err
+				} else if exp {
+					continue
+				}
+			}
+
 			v = append(v, k)
 			i++
 		}
@@ -277,6 +302,10 @@ func (db *DB) hScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 
 	v := make([]FVPair, 0, count)
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return v, err
+	}
+
 	it, err := db.buildDataScanIterator(HashType, key, cursor, count, inclusive, reverse)
 	if err != nil {
 		return nil, err
@@ -320,6 +349,10 @@ func (db *DB) sScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 
 	v := make([][]byte, 0, count)
 
+	if exp, err := db.expired(SetType, key); err != nil || exp {
+		return v, err
+	}
+
 	it, err := db.buildDataScanIterator(SetType, key, cursor, count, inclusive, reverse)
 	if err != nil {
 		return nil, err
@@ -363,6 +396,10 @@ func (db *DB) zScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 
 	v := make([]ScorePair, 0, count)
 
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return v, err
+	}
+
 	it, err := db.buildDataScanIterator(ZSetType, key, cursor, count, inclusive, reverse)
 	if err != nil {
 		return nil, err
@@ -378,7 +415,7 @@ func (db *DB) zScanGeneric(key []byte, cursor []byte, count int, inclusive bool,
 			continue
 		}
 
-		score, err := Int64(it.Value(), nil)
+		score, err := Float64(it.Value(), nil)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,145 @@
+package ledis
+
+import (
+	"github.com/siddontang/ledisdb/store"
+)
+
+// Snapshot is a read-only view of the DB at a single point in time,
+// the writes after the snapshot is created are not seen.
+//
+// The expiration of keys is still checked with the current time.
+// Snapshot must be closed after use.
+type Snapshot struct {
+	db *DB
+
+	s *store.Snapshot
+}
+
+// Snapshot creates a read-only snapshot of the DB.
+func (db *DB) Snapshot() (*Snapshot, error) {
+	if db.IsTransaction() {
+		return nil, ErrSnapshotInTx
+	}
+
+	// hold the write lock so that the snapshot is not taken in
+	// the middle of a write
+	db.l.wLock.Lock()
+	s, err := db.l.ldb.NewSnapshot()
+	db.l.wLock.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	snap := new(Snapshot)
+	snap.s = s
+
+	snap.db = new(DB)
+	snap.db.l = db.l
+	snap.db.sdb = db.sdb
+	snap.db.bucket = snapshotBucket{s}
+	snap.db.setIndex(db.index)
+
+	// only used to check the expired keys earlier
+	snap.db.ttlChecker = db.ttlChecker
+
+	return snap, nil
+}
+
+// Close releases the snapshot.
+func (s *Snapshot) Close() {
+	s.s.Close()
+}
+
+// Index gets the index of the DB of the snapshot.
+func (s *Snapshot) Index() int {
+	return s.db.Index()
+}
+
+// Get gets the value of the key.
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	return s.db.Get(key)
+}
+
+// HGetAll gets all the fields and values of the hash.
+func (s *Snapshot) HGetAll(key []byte) ([]FVPair, error) {
+	return s.db.HGetAll(key)
+}
+
+// LRange gets the values of the list in the range.
+func (s *Snapshot) LRange(key []byte, start int32, stop int32) ([][]byte, error) {
+	return s.db.LRange(key, start, stop)
+}
+
+// SMembers gets all the members of the set.
+func (s *Snapshot) SMembers(key []byte) ([][]byte, error) {
+	return s.db.SMembers(key)
+}
+
+// ZRangeByScore gets the members of the zset in the score range.
+func (s *Snapshot) ZRangeByScore(key []byte, min float64, max float64, offset int, count int) ([]ScorePair, error) {
+	return s.db.ZRangeByScore(key, min, max, offset, count)
+}
+
+// ZRevRangeByScore gets the members of the zset in the score range in reverse order.
+func (s *Snapshot) ZRevRangeByScore(key []byte, min float64, max float64, offset int, count int) ([]ScorePair, error) {
+	return s.db.ZRevRangeByScore(key, min, max, offset, count)
+}
+
+// Scan scans the keys of the data type.
+func (s *Snapshot) Scan(dataType DataType, cursor []byte, count int, inclusive bool, match string) ([][]byte, error) {
+	return s.db.Scan(dataType, cursor, count, inclusive, match)
+}
+
+// RevScan scans the keys of the data type reversely.
+func (s *Snapshot) RevScan(dataType DataType, cursor []byte, count int, inclusive bool, match string) ([][]byte, error) {
+	return s.db.RevScan(dataType, cursor, count, inclusive, match)
+}
+
+// HScan scans the fields of the hash.
+func (s *Snapshot) HScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([]FVPair, error) {
+	return s.db.HScan(key, cursor, count, inclusive, match)
+}
+
+// HRevScan scans the fields of the hash reversely.
+func (s *Snapshot) HRevScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([]FVPair, error) {
+	return s.db.HRevScan(key, cursor, count, inclusive, match)
+}
+
+// SScan scans the members of the set.
+func (s *Snapshot) SScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([][]byte, error) {
+	return s.db.SScan(key, cursor, count, inclusive, match)
+}
+
+// SRevScan scans the members of the set reversely.
+func (s *Snapshot) SRevScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([][]byte, error) {
+	return s.db.SRevScan(key, cursor, count, inclusive, match)
+}
+
+// ZScan scans the members of the zset.
+func (s *Snapshot) ZScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([]ScorePair, error) {
+	return s.db.ZScan(key, cursor, count, inclusive, match)
+}
+
+// ZRevScan scans the members of the zset reversely.
+func (s *Snapshot) ZRevScan(key []byte, cursor []byte, count int, inclusive bool, match string) ([]ScorePair, error) {
+	return s.db.ZRevScan(key, cursor, count, inclusive, match)
+}
+
+// snapshotBucket reads data from the store snapshot, the DB of the snapshot
+// never writes.
+type snapshotBucket struct {
+	*store.Snapshot
+}
+
+func (b snapshotBucket) Put(key []byte, value []byte) error {
+	return ErrWriteInROnly
+}
+
+func (b snapshotBucket) Delete(key []byte) error {
+	return ErrWriteInROnly
+}
+
+func (b snapshotBucket) NewWriteBatch() *store.WriteBatch {
+	panic("snapshot is read-only")
+}
@@ -0,0 +1,106 @@
+package ledis
+
+import (
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("snapshot_key")
+
+	db.Set(key, []byte("1"))
+	db.HClear(key)
+	db.HSet(key, []byte("f"), []byte("1"))
+	db.LClear(key)
+	db.RPush(key, []byte("1"))
+	db.SClear(key)
+	db.SAdd(key, []byte("1"))
+	db.ZClear(key)
+	db.ZAdd(key, ScorePair{1, []byte("1")})
+
+	s, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	db.Set(key, []byte("2"))
+	db.HSet(key, []byte("f"), []byte("2"))
+	db.HSet(key, []byte("f2"), []byte("2"))
+	db.RPush(key, []byte("2"))
+	db.SAdd(key, []byte("2"))
+	db.ZAdd(key, ScorePair{2, []byte("2")})
+	db.Set([]byte("snapshot_key_new"), []byte("1"))
+	defer db.Del([]byte("snapshot_key_new"))
+
+	if v, err := s.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "1" {
+		t.Fatal(string(v))
+	}
+
+	if v, err := s.HGetAll(key); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || string(v[0].Value) != "1" {
+		t.Fatal(v)
+	}
+
+	if v, err := s.LRange(key, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(len(v))
+	}
+
+	if v, err := s.SMembers(key); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(len(v))
+	}
+
+	if v, err := s.ZRangeByScore(key, MinScore, MaxScore, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0].Score != 1 {
+		t.Fatal(v)
+	}
+
+	if v, err := s.Scan(KV, []byte("snapshot_key"), 10, true, "snapshot_key.*"); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(len(v))
+	}
+
+	if v, err := s.HScan(key, nil, 10, true, ""); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(len(v))
+	}
+
+	if v, err := s.ZScan(key, nil, 10, true, ""); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(len(v))
+	}
+
+	// the db sees the new data
+	if v, _ := db.Get(key); string(v) != "2" {
+		t.Fatal(string(v))
+	}
+}
+
+func TestSnapshotInTx(t *testing.T) {
+	db := getTestDB()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// the transaction holds the write lock, the snapshot must not wait for it
+	if _, err = tx.Snapshot(); err != ErrSnapshotInTx {
+		t.Fatal(err)
+	} else if _, err = tx.DB.Snapshot(); err != ErrSnapshotInTx {
+		t.Fatal(err)
+	}
+}
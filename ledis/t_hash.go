@@ -163,6 +163,10 @@ func (db *DB) hDelete(t *batch, key []byte) int64 {
 	}
 	it.Close()
 
+	if num > 0 {
+		db.incrKeyNum(t, HASH, -1)
+	}
+
 	t.Delete(sk)
 	return num
 }
@@ -177,6 +181,7 @@ func (db *DB) hExpireAt(key []byte, when int64) (int64, error) {
 	}
 
 	db.expireAt(t, HashType, key, when)
+	db.notify(t, HASH, "expire", key)
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -190,6 +195,10 @@ func (db *DB) HLen(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return 0, err
+	}
+
 	return Int64(db.bucket.Get(db.hEncodeSizeKey(key)))
 }
 
@@ -205,11 +214,17 @@ func (db *DB) HSet(key []byte, field []byte, value []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, HashType, key); err != nil {
+		return 0, err
+	}
+
 	n, err := db.hSetItem(key, field, value)
 	if err != nil {
 		return 0, err
 	}
 
+	db.notify(t, HASH, "hset", key)
+
 	err = t.Commit()
 	return n, err
 }
@@ -220,6 +235,10 @@ func (db *DB) HGet(key []byte, field []byte) ([]byte, error) {
 		return nil, err
 	}
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return nil, err
+	}
+
 	return db.bucket.Get(db.hEncodeHashKey(key, field))
 }
 
@@ -229,6 +248,10 @@ func (db *DB) HMset(key []byte, args ...FVPair) error {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, HashType, key); err != nil {
+		return err
+	}
+
 	var err error
 	var ek []byte
 	var num int64
@@ -254,6 +277,8 @@ func (db *DB) HMset(key []byte, args ...FVPair) error {
 		return err
 	}
 
+	db.notify(t, HASH, "hset", key)
+
 	//todo add binglog
 	err = t.Commit()
 	return err
@@ -266,10 +291,17 @@ func (db *DB) HMget(key []byte, args ...[]byte) ([][]byte, error) {
 	it := db.bucket.NewIterator()
 	defer it.Close()
 
+	exp, err := db.expired(HashType, key)
+	if err != nil {
+		return nil, err
+	}
+
 	r := make([][]byte, len(args))
 	for i := 0; i < len(args); i++ {
 		if err := checkHashKFSize(key, args[i]); err != nil {
 			return nil, err
+		} else if exp {
+			continue
 		}
 
 		ek = db.hEncodeHashKey(key, args[i])
@@ -291,6 +323,10 @@ func (db *DB) HDel(key []byte, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, HashType, key); err != nil {
+		return 0, err
+	}
+
 	it := db.bucket.NewIterator()
 	defer it.Close()
 
@@ -311,10 +347,18 @@ func (db *DB) HDel(key []byte, args ...[]byte) (int64, error) {
 		}
 	}
 
-	if _, err = db.hIncrSize(key, -num); err != nil {
+	var size int64
+	if size, err = db.hIncrSize(key, -num); err != nil {
 		return 0, err
 	}
 
+	if num > 0 {
+		db.notify(t, HASH, "hdel", key)
+		if size == 0 {
+			db.notify(t, HASH, "del", key)
+		}
+	}
+
 	err = t.Commit()
 
 	return num, err
@@ -330,12 +374,18 @@ func (db *DB) hIncrSize(key []byte, delta int64) (int64, error) {
 		return 0, err
 	}
 
-	size += delta
-	if size <= 0 {
+	if size+delta <= 0 {
+		if size > 0 {
+			db.incrKeyNum(t, HASH, -1)
+		}
 		size = 0
 		t.Delete(sk)
 		db.rmExpire(t, HashType, key)
 	} else {
+		if size <= 0 {
+			db.incrKeyNum(t, HASH, 1)
+		}
+		size += delta
 		t.Put(sk, PutInt64(size))
 	}
 
@@ -355,6 +405,10 @@ func (db *DB) HIncrBy(key []byte, field []byte, delta int64) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, HashType, key); err != nil {
+		return 0, err
+	}
+
 	ek = db.hEncodeHashKey(key, field)
 
 	var n int64
@@ -369,6 +423,8 @@ func (db *DB) HIncrBy(key []byte, field []byte, delta int64) (int64, error) {
 		return 0, err
 	}
 
+	db.notify(t, HASH, "hincrby", key)
+
 	err = t.Commit()
 
 	return n, err
@@ -385,6 +441,10 @@ func (db *DB) HGetAll(key []byte) ([]FVPair, error) {
 
 	v := make([]FVPair, 0, 16)
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return v, err
+	}
+
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
 
@@ -411,6 +471,10 @@ func (db *DB) HKeys(key []byte) ([][]byte, error) {
 
 	v := make([][]byte, 0, 16)
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return v, err
+	}
+
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
 
@@ -436,6 +500,10 @@ func (db *DB) HValues(key []byte) ([][]byte, error) {
 
 	v := make([][]byte, 0, 16)
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return v, err
+	}
+
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
 
@@ -464,6 +532,10 @@ func (db *DB) HClear(key []byte) (int64, error) {
 	num := db.hDelete(t, key)
 	db.rmExpire(t, HashType, key)
 
+	if num > 0 {
+		db.notify(t, HASH, "del", key)
+	}
+
 	err := t.Commit()
 	return num, err
 }
@@ -479,7 +551,9 @@ func (db *DB) HMclear(keys ...[]byte) (int64, error) {
 			return 0, err
 		}
 
-		db.hDelete(t, key)
+		if db.hDelete(t, key) > 0 {
+			db.notify(t, HASH, "del", key)
+		}
 		db.rmExpire(t, HashType, key)
 	}
 
@@ -502,7 +576,7 @@ func (db *DB) HExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.hExpireAt(key, time.Now().Unix()+duration)
+	return db.hExpireAt(key, nowMilli()+duration*1000)
 }
 
 // HExpireAt expires the data at time when.
@@ -511,7 +585,7 @@ func (db *DB) HExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.hExpireAt(key, when)
+	return db.hExpireAt(key, when*1000)
 }
 
 // HTTL gets the TTL of data.
@@ -523,6 +597,33 @@ func (db *DB) HTTL(key []byte) (int64, error) {
 	return db.ttl(HashType, key)
 }
 
+// HPExpire expires the hash with duration in milliseconds.
+func (db *DB) HPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.hExpireAt(key, nowMilli()+duration)
+}
+
+// HPExpireAt expires the hash at when, the unix time in milliseconds.
+func (db *DB) HPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMilli() {
+		return 0, errExpireValue
+	}
+
+	return db.hExpireAt(key, when)
+}
+
+// HPTTL gets the TTL of hash in milliseconds.
+func (db *DB) HPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(HashType, key)
+}
+
 // HPersist removes the TTL of data.
 func (db *DB) HPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -533,11 +634,19 @@ func (db *DB) HPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, HashType, key)
 	if err != nil {
 		return 0, err
 	}
 
+	if n > 0 {
+		db.notify(t, HASH, "persist", key)
+	}
+
 	err = t.Commit()
 	return n, err
 }
@@ -547,6 +656,11 @@ func (db *DB) HKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+
+	if exp, err := db.expired(HashType, key); err != nil || exp {
+		return 0, err
+	}
+
 	sk := db.hEncodeSizeKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
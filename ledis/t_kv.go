@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/num"
 	"github.com/siddontang/ledisdb/store"
 )
@@ -68,28 +69,55 @@ func (db *DB) encodeKVMaxKey() []byte {
 	return ek
 }
 
+// getKV gets the value of key, the expired value is treated as missing.
+func (db *DB) getKV(key []byte) ([]byte, error) {
+	if exp, err := db.expired(KVType, key); err != nil || exp {
+		return nil, err
+	}
+
+	return db.bucket.Get(db.encodeKVKey(key))
+}
+
 func (db *DB) incr(key []byte, delta int64) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
 
 	var err error
-	key = db.encodeKVKey(key)
 
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
-	var n int64
-	n, err = StrInt64(db.bucket.Get(key))
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	event := "incrby"
+	if delta < 0 {
+		event = "decrby"
+	}
+	db.notify(t, KV, event, key)
+
+	key = db.encodeKVKey(key)
+
+	v, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
 	}
 
+	var n int64
+	if n, err = StrInt64(v, nil); err != nil {
+		return 0, err
+	}
+
 	n += delta
 
 	t.Put(key, num.FormatInt64ToSlice(n))
+	if v == nil {
+		db.incrKeyNum(t, KV, 1)
+	}
 
 	err = t.Commit()
 	return n, err
@@ -99,10 +127,26 @@ func (db *DB) incr(key []byte, delta int64) (int64, error) {
 //		 any other likes expire is ignore.
 func (db *DB) delete(t *batch, key []byte) int64 {
 	key = db.encodeKVKey(key)
+	if v, err := db.bucket.Get(key); err != nil {
+		log.Errorf("get kv key %q error %s, the key is not counted", key, err.Error())
+	} else if v != nil {
+		db.incrKeyNum(t, KV, -1)
+	}
+
 	t.Delete(key)
 	return 1
 }
 
+// kvCreate counts the key if it does not exist before the write, the KV type
+// has no size to know whether the key is created.
+func (db *DB) kvCreate(t *batch, ek []byte) error {
+	v, err := db.bucket.Get(ek)
+	if err == nil && v == nil {
+		db.incrKeyNum(t, KV, 1)
+	}
+	return err
+}
+
 func (db *DB) setExpireAt(key []byte, when int64) (int64, error) {
 	t := db.kvBatch
 	t.Lock()
@@ -113,6 +157,7 @@ func (db *DB) setExpireAt(key []byte, when int64) (int64, error) {
 	}
 
 	db.expireAt(t, KVType, key, when)
+	db.notify(t, KV, "expire", key)
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -145,9 +190,23 @@ func (db *DB) Del(keys ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	// the deletion is not seen before committed, count a key only once
+	deleted := make(map[string]struct{}, len(keys))
+	for i, k := range keys {
+		if _, ok := deleted[string(k)]; ok {
+			continue
+		} else if v, err := db.bucket.Get(codedKeys[i]); err != nil {
+			return 0, err
+		} else if v != nil {
+			db.incrKeyNum(t, KV, -1)
+			deleted[string(k)] = struct{}{}
+		}
+	}
+
 	for i, k := range keys {
 		t.Delete(codedKeys[i])
 		db.rmExpire(t, KVType, k)
+		db.notify(t, KV, "del", k)
 	}
 
 	err := t.Commit()
@@ -160,11 +219,7 @@ func (db *DB) Exists(key []byte) (int64, error) {
 		return 0, err
 	}
 
-	var err error
-	key = db.encodeKVKey(key)
-
-	var v []byte
-	v, err = db.bucket.Get(key)
+	v, err := db.getKV(key)
 	if v != nil && err == nil {
 		return 1, nil
 	}
@@ -178,9 +233,7 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	key = db.encodeKVKey(key)
-
-	return db.bucket.Get(key)
+	return db.getKV(key)
 }
 
 // GetSlice gets the slice of the data.
@@ -189,6 +242,10 @@ func (db *DB) GetSlice(key []byte) (store.Slice, error) {
 		return nil, err
 	}
 
+	if exp, err := db.expired(KVType, key); err != nil || exp {
+		return nil, err
+	}
+
 	key = db.encodeKVKey(key)
 
 	return db.bucket.GetSlice(key)
@@ -202,19 +259,28 @@ func (db *DB) GetSet(key []byte, value []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return nil, err
+	}
+
+	db.notify(t, KV, "set", key)
+
+	key = db.encodeKVKey(key)
+
 	oldValue, err := db.bucket.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
 	t.Put(key, value)
+	if oldValue == nil {
+		db.incrKeyNum(t, KV, 1)
+	}
 
 	err = t.Commit()
 
@@ -243,6 +309,12 @@ func (db *DB) MGet(keys ...[]byte) ([][]byte, error) {
 			return nil, err
 		}
 
+		if exp, err := db.expired(KVType, keys[i]); err != nil {
+			return nil, err
+		} else if exp {
+			continue
+		}
+
 		values[i] = it.Find(db.encodeKVKey(keys[i]))
 	}
 
@@ -264,6 +336,8 @@ func (db *DB) MSet(args ...KVPair) error {
 	t.Lock()
 	defer t.Unlock()
 
+	// the write is not seen before committed, count a key only once
+	created := make(map[string]struct{}, len(args))
 	for i := 0; i < len(args); i++ {
 		if err := checkKeySize(args[i].Key); err != nil {
 			return err
@@ -271,12 +345,27 @@ func (db *DB) MSet(args ...KVPair) error {
 			return err
 		}
 
+		// the expiration of an expired key must not remove the new value
+		if exp, err := db.expired(KVType, args[i].Key); err != nil {
+			return err
+		} else if exp {
+			db.rmExpire(t, KVType, args[i].Key)
+		}
+
 		key = db.encodeKVKey(args[i].Key)
 
+		if _, ok := created[string(key)]; !ok {
+			if err := db.kvCreate(t, key); err != nil {
+				return err
+			}
+			created[string(key)] = struct{}{}
+		}
+
 		value = args[i].Value
 
 		t.Put(key, value)
 
+		db.notify(t, KV, "set", args[i].Key)
 	}
 
 	err = t.Commit()
@@ -292,13 +381,24 @@ func (db *DB) Set(key []byte, value []byte) error {
 	}
 
 	var err error
-	key = db.encodeKVKey(key)
 
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return err
+	}
+
+	db.notify(t, KV, "set", key)
+
+	key = db.encodeKVKey(key)
+
+	if err := db.kvCreate(t, key); err != nil {
+		return err
+	}
+
 	t.Put(key, value)
 
 	err = t.Commit()
@@ -315,7 +415,6 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 	}
 
 	var err error
-	key = db.encodeKVKey(key)
 
 	var n int64 = 1
 
@@ -324,12 +423,21 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	db.notify(t, KV, "set", key)
+
+	key = db.encodeKVKey(key)
+
 	if v, err := db.bucket.Get(key); err != nil {
 		return 0, err
 	} else if v != nil {
 		n = 0
 	} else {
 		t.Put(key, value)
+		db.incrKeyNum(t, KV, 1)
 
 		err = t.Commit()
 	}
@@ -339,6 +447,15 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 
 // SetEX sets the data with a TTL.
 func (db *DB) SetEX(key []byte, duration int64, value []byte) error {
+	if duration <= 0 {
+		return errExpireValue
+	}
+
+	return db.PSetEX(key, duration*1000, value)
+}
+
+// PSetEX sets the data with a TTL in milliseconds.
+func (db *DB) PSetEX(key []byte, duration int64, value []byte) error {
 	if err := checkKeySize(key); err != nil {
 		return err
 	} else if err := checkValueSize(value); err != nil {
@@ -354,8 +471,15 @@ func (db *DB) SetEX(key []byte, duration int64, value []byte) error {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.kvCreate(t, ek); err != nil {
+		return err
+	}
+
 	t.Put(ek, value)
-	db.expireAt(t, KVType, key, time.Now().Unix()+duration)
+	db.expire(t, KVType, key, duration)
+
+	db.notify(t, KV, "set", key)
+	db.notify(t, KV, "expire", key)
 
 	return t.Commit()
 }
@@ -367,13 +491,18 @@ func (db *DB) flush() (drop int64, err error) {
 	return db.flushType(t, KVType)
 }
 
+// FlushKV deletes all the KV data of the DB, the other data types are kept.
+func (db *DB) FlushKV() (int64, error) {
+	return db.flush()
+}
+
 // Expire expires the data.
 func (db *DB) Expire(key []byte, duration int64) (int64, error) {
 	if duration <= 0 {
 		return 0, errExpireValue
 	}
 
-	return db.setExpireAt(key, time.Now().Unix()+duration)
+	return db.setExpireAt(key, nowMilli()+duration*1000)
 }
 
 // ExpireAt expires the data at when.
@@ -382,7 +511,7 @@ func (db *DB) ExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.setExpireAt(key, when)
+	return db.setExpireAt(key, when*1000)
 }
 
 // TTL returns the TTL of the data.
@@ -394,6 +523,33 @@ func (db *DB) TTL(key []byte) (int64, error) {
 	return db.ttl(KVType, key)
 }
 
+// PExpire expires the data with duration in milliseconds.
+func (db *DB) PExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.setExpireAt(key, nowMilli()+duration)
+}
+
+// PExpireAt expires the data at when, the unix time in milliseconds.
+func (db *DB) PExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMilli() {
+		return 0, errExpireValue
+	}
+
+	return db.setExpireAt(key, when)
+}
+
+// PTTL gets the TTL of data in milliseconds.
+func (db *DB) PTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(KVType, key)
+}
+
 // Persist removes the TTL of the data.
 func (db *DB) Persist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -403,11 +559,20 @@ func (db *DB) Persist(key []byte) (int64, error) {
 	t := db.kvBatch
 	t.Lock()
 	defer t.Unlock()
+
+	if exp, err := db.expired(KVType, key); err != nil || exp {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, KVType, key)
 	if err != nil {
 		return 0, err
 	}
 
+	if n > 0 {
+		db.notify(t, KV, "persist", key)
+	}
+
 	err = t.Commit()
 	return n, err
 }
@@ -424,13 +589,19 @@ func (db *DB) SetRange(key []byte, offset int, value []byte) (int64, error) {
 		return 0, errValueSize
 	}
 
-	key = db.encodeKVKey(key)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	db.notify(t, KV, "setrange", key)
+
+	key = db.encodeKVKey(key)
+
 	oldValue, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
@@ -441,6 +612,10 @@ func (db *DB) SetRange(key []byte, offset int, value []byte) (int64, error) {
 		oldValue = append(oldValue, make([]byte, extra)...)
 	}
 
+	if oldValue == nil {
+		db.incrKeyNum(t, KV, 1)
+	}
+
 	copy(oldValue[offset:], value)
 
 	t.Put(key, oldValue)
@@ -480,9 +655,7 @@ func (db *DB) GetRange(key []byte, start int, end int) ([]byte, error) {
 	if err := checkKeySize(key); err != nil {
 		return nil, err
 	}
-	key = db.encodeKVKey(key)
-
-	value, err := db.bucket.Get(key)
+	value, err := db.getKV(key)
 	if err != nil {
 		return nil, err
 	}
@@ -501,7 +674,7 @@ func (db *DB) GetRange(key []byte, start int, end int) ([]byte, error) {
 // StrLen returns the length of the data.
 func (db *DB) StrLen(key []byte) (int64, error) {
 	s, err := db.GetSlice(key)
-	if err != nil {
+	if err != nil || s == nil {
 		return 0, err
 	}
 
@@ -519,13 +692,20 @@ func (db *DB) Append(key []byte, value []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
-	key = db.encodeKVKey(key)
 
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	db.notify(t, KV, "append", key)
+
+	key = db.encodeKVKey(key)
+
 	oldValue, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
@@ -535,6 +715,10 @@ func (db *DB) Append(key []byte, value []byte) (int64, error) {
 		return 0, errValueSize
 	}
 
+	if oldValue == nil {
+		db.incrKeyNum(t, KV, 1)
+	}
+
 	oldValue = append(oldValue, value...)
 
 	t.Put(key, oldValue)
@@ -561,9 +745,7 @@ func (db *DB) BitOP(op string, destKey []byte, srcKeys ...[]byte) (int64, error)
 		return 0, nil
 	}
 
-	key := db.encodeKVKey(srcKeys[0])
-
-	value, err := db.bucket.Get(key)
+	value, err := db.getKV(srcKeys[0])
 	if err != nil {
 		return 0, err
 	}
@@ -578,8 +760,7 @@ func (db *DB) BitOP(op string, destKey []byte, srcKeys ...[]byte) (int64, error)
 				return 0, err
 			}
 
-			key = db.encodeKVKey(srcKeys[j])
-			ovalue, err := db.bucket.Get(key)
+			ovalue, err := db.getKV(srcKeys[j])
 			if err != nil {
 				return 0, err
 			}
@@ -614,15 +795,24 @@ func (db *DB) BitOP(op string, destKey []byte, srcKeys ...[]byte) (int64, error)
 		}
 	}
 
-	key = db.encodeKVKey(destKey)
-
 	t := db.kvBatch
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, destKey); err != nil {
+		return 0, err
+	}
+
+	key := db.encodeKVKey(destKey)
+	if err := db.kvCreate(t, key); err != nil {
+		return 0, err
+	}
+
 	t.Put(key, value)
 
+	db.notify(t, KV, "set", destKey)
+
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -655,8 +845,7 @@ func (db *DB) BitCount(key []byte, start int, end int) (int64, error) {
 		return 0, err
 	}
 
-	key = db.encodeKVKey(key)
-	value, err := db.bucket.Get(key)
+	value, err := db.getKV(key)
 	if err != nil {
 		return 0, err
 	}
@@ -693,8 +882,7 @@ func (db *DB) BitPos(key []byte, on int, start int, end int) (int64, error) {
 		skipValue = 0xFF
 	}
 
-	key = db.encodeKVKey(key)
-	value, err := db.bucket.Get(key)
+	value, err := db.getKV(key)
 	if err != nil {
 		return 0, err
 	}
@@ -732,10 +920,18 @@ func (db *DB) SetBit(key []byte, offset int, on int) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, KVType, key); err != nil {
+		return 0, err
+	}
+
+	db.notify(t, KV, "setbit", key)
+
 	key = db.encodeKVKey(key)
 	value, err := db.bucket.Get(key)
 	if err != nil {
 		return 0, err
+	} else if value == nil {
+		db.incrKeyNum(t, KV, 1)
 	}
 
 	byteOffset := int(uint32(offset) >> 3)
@@ -771,9 +967,7 @@ func (db *DB) GetBit(key []byte, offset int) (int64, error) {
 		return 0, err
 	}
 
-	key = db.encodeKVKey(key)
-
-	value, err := db.bucket.Get(key)
+	value, err := db.getKV(key)
 	if err != nil {
 		return 0, err
 	}
@@ -115,6 +115,10 @@ func (db *DB) lpush(key []byte, whereSeq int32, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ListType, key); err != nil {
+		return 0, err
+	}
+
 	metaKey := db.lEncodeMetaKey(key)
 	headSeq, tailSeq, size, err = db.lGetMeta(nil, metaKey)
 	if err != nil {
@@ -156,6 +160,15 @@ func (db *DB) lpush(key []byte, whereSeq int32, args ...[]byte) (int64, error) {
 	}
 
 	db.lSetMeta(metaKey, headSeq, tailSeq)
+	if size == 0 {
+		db.incrKeyNum(t, LIST, 1)
+	}
+
+	if whereSeq == listHeadSeq {
+		db.notify(t, LIST, "lpush", key)
+	} else {
+		db.notify(t, LIST, "rpush", key)
+	}
 
 	err = t.Commit()
 
@@ -180,6 +193,10 @@ func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
 	var size int32
 	var err error
 
+	if err := db.delExpired(t, ListType, key); err != nil {
+		return nil, err
+	}
+
 	metaKey := db.lEncodeMetaKey(key)
 	headSeq, tailSeq, size, err = db.lGetMeta(nil, metaKey)
 	if err != nil {
@@ -209,8 +226,17 @@ func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
 
 	t.Delete(itemKey)
 	size = db.lSetMeta(metaKey, headSeq, tailSeq)
+
+	if whereSeq == listHeadSeq {
+		db.notify(t, LIST, "lpop", key)
+	} else {
+		db.notify(t, LIST, "rpop", key)
+	}
+
 	if size == 0 {
 		db.rmExpire(t, ListType, key)
+		db.incrKeyNum(t, LIST, -1)
+		db.notify(t, LIST, "del", key)
 	}
 
 	err = t.Commit()
@@ -226,6 +252,10 @@ func (db *DB) ltrim2(key []byte, startP, stopP int64) (err error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ListType, key); err != nil {
+		return err
+	}
+
 	var headSeq int32
 	var llen int32
 	start := int32(startP)
@@ -243,7 +273,10 @@ func (db *DB) ltrim2(key []byte, startP, stopP int64) (err error) {
 		stop = llen + stop
 	}
 	if start >= llen || start > stop {
-		db.lDelete(t, key)
+		if db.lDelete(t, key) > 0 {
+			db.notify(t, LIST, "ltrim", key)
+			db.notify(t, LIST, "del", key)
+		}
 		db.rmExpire(t, ListType, key)
 		return t.Commit()
 	}
@@ -268,6 +301,8 @@ func (db *DB) ltrim2(key []byte, startP, stopP int64) (err error) {
 
 	db.lSetMeta(ek, headSeq+start, headSeq+stop)
 
+	db.notify(t, LIST, "ltrim", key)
+
 	return t.Commit()
 }
 
@@ -284,6 +319,10 @@ func (db *DB) ltrim(key []byte, trimSize, whereSeq int32) (int32, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ListType, key); err != nil {
+		return 0, err
+	}
+
 	var headSeq int32
 	var tailSeq int32
 	var size int32
@@ -318,8 +357,13 @@ func (db *DB) ltrim(key []byte, trimSize, whereSeq int32) (int32, error) {
 	}
 
 	size = db.lSetMeta(metaKey, headSeq, tailSeq)
+
+	db.notify(t, LIST, "ltrim", key)
+
 	if size == 0 {
 		db.rmExpire(t, ListType, key)
+		db.incrKeyNum(t, LIST, -1)
+		db.notify(t, LIST, "del", key)
 	}
 
 	err = t.Commit()
@@ -338,9 +382,13 @@ func (db *DB) lDelete(t *batch, key []byte) int64 {
 	it := db.bucket.NewIterator()
 	defer it.Close()
 
-	headSeq, tailSeq, _, err = db.lGetMeta(it, mk)
+	var size int32
+	headSeq, tailSeq, size, err = db.lGetMeta(it, mk)
 	if err != nil {
+		log.Errorf("get list meta %q error %s", key, err.Error())
 		return 0
+	} else if size > 0 {
+		db.incrKeyNum(t, LIST, -1)
 	}
 
 	var num int64
@@ -414,6 +462,7 @@ func (db *DB) lExpireAt(key []byte, when int64) (int64, error) {
 	}
 
 	db.expireAt(t, ListType, key, when)
+	db.notify(t, LIST, "expire", key)
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -432,6 +481,10 @@ func (db *DB) LIndex(key []byte, index int32) ([]byte, error) {
 	var tailSeq int32
 	var err error
 
+	if exp, err := db.expired(ListType, key); err != nil || exp {
+		return nil, err
+	}
+
 	metaKey := db.lEncodeMetaKey(key)
 
 	it := db.bucket.NewIterator()
@@ -460,6 +513,10 @@ func (db *DB) LLen(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if exp, err := db.expired(ListType, key); err != nil || exp {
+		return 0, err
+	}
+
 	ek := db.lEncodeMetaKey(key)
 	_, _, size, err := db.lGetMeta(nil, ek)
 	return int64(size), err
@@ -504,6 +561,11 @@ func (db *DB) LSet(key []byte, index int32, value []byte) error {
 	t := db.listBatch
 	t.Lock()
 	defer t.Unlock()
+
+	if err := db.delExpired(t, ListType, key); err != nil {
+		return err
+	}
+
 	metaKey := db.lEncodeMetaKey(key)
 
 	headSeq, tailSeq, _, err = db.lGetMeta(nil, metaKey)
@@ -521,6 +583,7 @@ func (db *DB) LSet(key []byte, index int32, value []byte) error {
 	}
 	sk := db.lEncodeListKey(key, seq)
 	t.Put(sk, value)
+	db.notify(t, LIST, "lset", key)
 	err = t.Commit()
 	return err
 }
@@ -535,6 +598,10 @@ func (db *DB) LRange(key []byte, start int32, stop int32) ([][]byte, error) {
 	var llen int32
 	var err error
 
+	if exp, err := db.expired(ListType, key); err != nil || exp {
+		return [][]byte{}, err
+	}
+
 	metaKey := db.lEncodeMetaKey(key)
 
 	it := db.bucket.NewIterator()
@@ -607,6 +674,10 @@ func (db *DB) LClear(key []byte) (int64, error) {
 	num := db.lDelete(t, key)
 	db.rmExpire(t, ListType, key)
 
+	if num > 0 {
+		db.notify(t, LIST, "del", key)
+	}
+
 	err := t.Commit()
 	return num, err
 }
@@ -622,7 +693,9 @@ func (db *DB) LMclear(keys ...[]byte) (int64, error) {
 			return 0, err
 		}
 
-		db.lDelete(t, key)
+		if db.lDelete(t, key) > 0 {
+			db.notify(t, LIST, "del", key)
+		}
 		db.rmExpire(t, ListType, key)
 
 	}
@@ -644,7 +717,7 @@ func (db *DB) LExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.lExpireAt(key, time.Now().Unix()+duration)
+	return db.lExpireAt(key, nowMilli()+duration*1000)
 }
 
 // LExpireAt expires the list at when.
@@ -653,7 +726,7 @@ func (db *DB) LExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.lExpireAt(key, when)
+	return db.lExpireAt(key, when*1000)
 }
 
 // LTTL gets the TTL of list.
@@ -665,6 +738,33 @@ func (db *DB) LTTL(key []byte) (int64, error) {
 	return db.ttl(ListType, key)
 }
 
+// LPExpire expires the list with duration in milliseconds.
+func (db *DB) LPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.lExpireAt(key, nowMilli()+duration)
+}
+
+// LPExpireAt expires the list at when, the unix time in milliseconds.
+func (db *DB) LPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMilli() {
+		return 0, errExpireValue
+	}
+
+	return db.lExpireAt(key, when)
+}
+
+// LPTTL gets the TTL of list in milliseconds.
+func (db *DB) LPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(ListType, key)
+}
+
 // LPersist removes the TTL of list.
 func (db *DB) LPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -675,11 +775,19 @@ func (db *DB) LPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if exp, err := db.expired(ListType, key); err != nil || exp {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, ListType, key)
 	if err != nil {
 		return 0, err
 	}
 
+	if n > 0 {
+		db.notify(t, LIST, "persist", key)
+	}
+
 	err = t.Commit()
 	return n, err
 }
@@ -709,6 +817,11 @@ func (db *DB) LKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+
+	if exp, err := db.expired(ListType, key); err != nil || exp {
+		return 0, err
+	}
+
 	sk := db.lEncodeMetaKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
@@ -718,6 +831,16 @@ func (db *DB) LKeyExists(key []byte) (int64, error) {
 }
 
 func (db *DB) lblockPop(keys [][]byte, whereSeq int32, timeout time.Duration) ([]interface{}, error) {
+	if db.IsTransaction() {
+		// same as Redis, never block in a transaction
+		for _, key := range keys {
+			if v, err := db.lpop(key, whereSeq); err != nil || v != nil {
+				return []interface{}{key, v}, err
+			}
+		}
+		return nil, nil
+	}
+
 	for {
 		var ctx context.Context
 		var cancel context.CancelFunc
@@ -147,6 +147,10 @@ func (db *DB) sDelete(t *batch, key []byte) int64 {
 
 	it.Close()
 
+	if num > 0 {
+		db.incrKeyNum(t, SET, -1)
+	}
+
 	t.Delete(sk)
 	return num
 }
@@ -161,12 +165,18 @@ func (db *DB) sIncrSize(key []byte, delta int64) (int64, error) {
 		return 0, err
 	}
 
-	size += delta
-	if size <= 0 {
+	if size+delta <= 0 {
+		if size > 0 {
+			db.incrKeyNum(t, SET, -1)
+		}
 		size = 0
 		t.Delete(sk)
 		db.rmExpire(t, SetType, key)
 	} else {
+		if size <= 0 {
+			db.incrKeyNum(t, SET, 1)
+		}
+		size += delta
 		t.Put(sk, PutInt64(size))
 	}
 
@@ -182,6 +192,7 @@ func (db *DB) sExpireAt(key []byte, when int64) (int64, error) {
 		return 0, err
 	}
 	db.expireAt(t, SetType, key, when)
+	db.notify(t, SET, "expire", key)
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -212,6 +223,10 @@ func (db *DB) SAdd(key []byte, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, SetType, key); err != nil {
+		return 0, err
+	}
+
 	var err error
 	var ek []byte
 	var num int64
@@ -235,6 +250,10 @@ func (db *DB) SAdd(key []byte, args ...[]byte) (int64, error) {
 		return 0, err
 	}
 
+	if num > 0 {
+		db.notify(t, SET, "sadd", key)
+	}
+
 	err = t.Commit()
 	return num, err
 
@@ -246,6 +265,10 @@ func (db *DB) SCard(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if exp, err := db.expired(SetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	sk := db.sEncodeSizeKey(key)
 
 	return Int64(db.bucket.Get(sk))
@@ -312,6 +335,11 @@ func (db *DB) SKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+
+	if exp, err := db.expired(SetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	sk := db.sEncodeSizeKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
@@ -389,6 +417,10 @@ func (db *DB) SInterStore(dstKey []byte, keys ...[]byte) (int64, error) {
 
 // SIsMember checks member in set.
 func (db *DB) SIsMember(key []byte, member []byte) (int64, error) {
+	if exp, err := db.expired(SetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	ek := db.sEncodeSetKey(key, member)
 
 	var n int64 = 1
@@ -411,6 +443,10 @@ func (db *DB) SMembers(key []byte) ([][]byte, error) {
 
 	v := make([][]byte, 0, 16)
 
+	if exp, err := db.expired(SetType, key); err != nil || exp {
+		return v, err
+	}
+
 	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	defer it.Close()
 
@@ -432,6 +468,10 @@ func (db *DB) SRem(key []byte, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, SetType, key); err != nil {
+		return 0, err
+	}
+
 	var ek []byte
 	var v []byte
 	var err error
@@ -456,10 +496,18 @@ func (db *DB) SRem(key []byte, args ...[]byte) (int64, error) {
 		}
 	}
 
-	if _, err = db.sIncrSize(key, -num); err != nil {
+	var size int64
+	if size, err = db.sIncrSize(key, -num); err != nil {
 		return 0, err
 	}
 
+	if num > 0 {
+		db.notify(t, SET, "srem", key)
+		if size == 0 {
+			db.notify(t, SET, "del", key)
+		}
+	}
+
 	err = t.Commit()
 	return num, err
 
@@ -517,6 +565,10 @@ func (db *DB) sStoreGeneric(dstKey []byte, optType byte, keys ...[]byte) (int64,
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, SetType, dstKey); err != nil {
+		return 0, err
+	}
+
 	db.sDelete(t, dstKey)
 
 	var err error
@@ -553,6 +605,18 @@ func (db *DB) sStoreGeneric(dstKey []byte, optType byte, keys ...[]byte) (int64,
 	var n = int64(len(v))
 	sk := db.sEncodeSizeKey(dstKey)
 	t.Put(sk, PutInt64(n))
+	if n > 0 {
+		db.incrKeyNum(t, SET, 1)
+	}
+
+	switch optType {
+	case UnionType:
+		db.notify(t, SET, "sunionstore", dstKey)
+	case DiffType:
+		db.notify(t, SET, "sdiffstore", dstKey)
+	case InterType:
+		db.notify(t, SET, "sinterstore", dstKey)
+	}
 
 	if err = t.Commit(); err != nil {
 		return 0, err
@@ -573,6 +637,10 @@ func (db *DB) SClear(key []byte) (int64, error) {
 	num := db.sDelete(t, key)
 	db.rmExpire(t, SetType, key)
 
+	if num > 0 {
+		db.notify(t, SET, "del", key)
+	}
+
 	err := t.Commit()
 	return num, err
 }
@@ -588,7 +656,9 @@ func (db *DB) SMclear(keys ...[]byte) (int64, error) {
 			return 0, err
 		}
 
-		db.sDelete(t, key)
+		if db.sDelete(t, key) > 0 {
+			db.notify(t, SET, "del", key)
+		}
 		db.rmExpire(t, SetType, key)
 	}
 
@@ -602,7 +672,7 @@ func (db *DB) SExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.sExpireAt(key, time.Now().Unix()+duration)
+	return db.sExpireAt(key, nowMilli()+duration*1000)
 
 }
 
@@ -612,7 +682,7 @@ func (db *DB) SExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.sExpireAt(key, when)
+	return db.sExpireAt(key, when*1000)
 
 }
 
@@ -625,6 +695,33 @@ func (db *DB) STTL(key []byte) (int64, error) {
 	return db.ttl(SetType, key)
 }
 
+// SPExpire expires the set with duration in milliseconds.
+func (db *DB) SPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.sExpireAt(key, nowMilli()+duration)
+}
+
+// SPExpireAt expires the set at when, the unix time in milliseconds.
+func (db *DB) SPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMilli() {
+		return 0, errExpireValue
+	}
+
+	return db.sExpireAt(key, when)
+}
+
+// SPTTL gets the TTL of set in milliseconds.
+func (db *DB) SPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(SetType, key)
+}
+
 // SPersist removes the TTL of set.
 func (db *DB) SPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -635,10 +732,17 @@ func (db *DB) SPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if exp, err := db.expired(SetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, SetType, key)
 	if err != nil {
 		return 0, err
 	}
+	if n > 0 {
+		db.notify(t, SET, "persist", key)
+	}
 	err = t.Commit()
 	return n, err
 }
@@ -28,6 +28,12 @@ type ttlChecker struct {
 
 var errExpType = errors.New("invalid expire type")
 
+// nowMilli returns the current unix time in milliseconds,
+// all the expiration times are stored in milliseconds.
+func nowMilli() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
 func (db *DB) expEncodeTimeKey(dataType byte, key []byte, when int64) []byte {
 	buf := make([]byte, len(key)+10+len(db.indexVarBuf))
 
@@ -87,10 +93,12 @@ func (db *DB) expDecodeTimeKey(tk []byte) (byte, []byte, int64, error) {
 	return tk[pos+9], tk[pos+10:], int64(binary.BigEndian.Uint64(tk[pos+1:])), nil
 }
 
+// expire expires the key after duration milliseconds.
 func (db *DB) expire(t *batch, dataType byte, key []byte, duration int64) {
-	db.expireAt(t, dataType, key, time.Now().Unix()+duration)
+	db.expireAt(t, dataType, key, nowMilli()+duration)
 }
 
+// expireAt expires the key at when, the unix time in milliseconds.
 func (db *DB) expireAt(t *batch, dataType byte, key []byte, when int64) {
 	mk := db.expEncodeMetaKey(dataType, key)
 	tk := db.expEncodeTimeKey(dataType, key, when)
@@ -101,13 +109,23 @@ func (db *DB) expireAt(t *batch, dataType byte, key []byte, when int64) {
 	db.ttlChecker.setNextCheckTime(when, false)
 }
 
+// ttl returns the remaining time to live in seconds, rounded like Redis.
 func (db *DB) ttl(dataType byte, key []byte) (t int64, err error) {
+	if t, err = db.pttl(dataType, key); err != nil || t == -1 {
+		return t, err
+	}
+
+	return (t + 500) / 1000, nil
+}
+
+// pttl returns the remaining time to live in milliseconds.
+func (db *DB) pttl(dataType byte, key []byte) (t int64, err error) {
 	mk := db.expEncodeMetaKey(dataType, key)
 
 	if t, err = Int64(db.bucket.Get(mk)); err != nil || t == 0 {
 		t = -1
 	} else {
-		t -= time.Now().Unix()
+		t -= nowMilli()
 		if t <= 0 {
 			t = -1
 		}
@@ -117,6 +135,52 @@ func (db *DB) ttl(dataType byte, key []byte) (t int64, err error) {
 	return t, err
 }
 
+// expired returns whether the key of the data type has an expiration time
+// which has already passed. Read operations treat the expired data as missing
+// even if the ttl checker has not deleted it yet.
+func (db *DB) expired(dataType byte, key []byte) (bool, error) {
+	when, err := Int64(db.bucket.Get(db.expEncodeMetaKey(dataType, key)))
+	if err != nil || when == 0 {
+		return false, err
+	} else if when > nowMilli() {
+		return false, nil
+	}
+
+	// make sure the ttl checker will delete it as soon as possible
+	db.ttlChecker.setNextCheckTime(when, false)
+	return true, nil
+}
+
+// delExpired deletes the data of key like the ttl checker does if the key has
+// already expired, so the following write works on a new key. It must be
+// called with the batch of the data type locked.
+//
+// The deletions are committed with the following write in one commit, and
+// they are hidden in the view until then, or applied to the view of the
+// transaction.
+func (db *DB) delExpired(t *batch, dataType byte, key []byte) error {
+	if exp, err := db.expired(dataType, key); err != nil || !exp {
+		return err
+	}
+
+	t.hiding = t.tx == nil
+	defer func() {
+		t.hiding = false
+	}()
+
+	db.ttlChecker.cbs[dataType](t, key)
+	if _, err := db.rmExpire(t, dataType, key); err != nil {
+		return err
+	}
+
+	db.notify(t, getDataType(dataType), "expired", key)
+
+	if t.tx != nil {
+		return t.Commit()
+	}
+	return nil
+}
+
 func (db *DB) rmExpire(t *batch, dataType byte, key []byte) (int64, error) {
 	mk := db.expEncodeMetaKey(dataType, key)
 	v, err := db.bucket.Get(mk)
@@ -153,7 +217,7 @@ func (c *ttlChecker) setNextCheckTime(when int64, force bool) {
 }
 
 func (c *ttlChecker) check() {
-	now := time.Now().Unix()
+	now := nowMilli()
 
 	c.Lock()
 	nc := c.nc
@@ -163,7 +227,7 @@ func (c *ttlChecker) check() {
 		return
 	}
 
-	nc = now + 3600
+	nc = now + 3600*1000
 
 	db := c.db
 	dbGet := db.bucket.Get
@@ -202,6 +266,8 @@ func (c *ttlChecker) check() {
 				t.Delete(tk)
 				t.Delete(mk)
 
+				db.notify(t, getDataType(dt), "expired", k)
+
 				t.Commit()
 			}
 
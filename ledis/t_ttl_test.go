@@ -2,11 +2,13 @@ package ledis
 
 import (
 	"fmt"
+	"os"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/siddontang/go/hack"
+	"github.com/siddontang/ledisdb/config"
 )
 
 var m sync.Mutex
@@ -20,6 +22,10 @@ type adaptor struct {
 	expireAt func([]byte, int64) (int64, error)
 	ttl      func([]byte) (int64, error)
 
+	pexpire   func([]byte, int64) (int64, error)
+	pexpireAt func([]byte, int64) (int64, error)
+	pttl      func([]byte) (int64, error)
+
 	showIdent func() string
 }
 
@@ -38,6 +44,9 @@ func kvAdaptor(db *DB) *adaptor {
 	adp.expire = db.Expire
 	adp.expireAt = db.ExpireAt
 	adp.ttl = db.TTL
+	adp.pexpire = db.PExpire
+	adp.pexpireAt = db.PExpireAt
+	adp.pttl = db.PTTL
 
 	return adp
 }
@@ -74,6 +83,9 @@ func listAdaptor(db *DB) *adaptor {
 	adp.expire = db.LExpire
 	adp.expireAt = db.LExpireAt
 	adp.ttl = db.LTTL
+	adp.pexpire = db.LPExpire
+	adp.pexpireAt = db.LPExpireAt
+	adp.pttl = db.LPTTL
 
 	return adp
 }
@@ -112,6 +124,9 @@ func hashAdaptor(db *DB) *adaptor {
 	adp.expire = db.HExpire
 	adp.expireAt = db.HExpireAt
 	adp.ttl = db.HTTL
+	adp.pexpire = db.HPExpire
+	adp.pexpireAt = db.HPExpireAt
+	adp.pttl = db.HPTTL
 
 	return adp
 }
@@ -127,7 +142,7 @@ func zsetAdaptor(db *DB) *adaptor {
 		for i := 0; i < 3; i++ {
 			memb := []byte(hack.String(k) + fmt.Sprintf("_%d", i))
 			pair := ScorePair{
-				Score:  int64(i),
+				Score:  float64(i),
 				Member: memb}
 
 			datas = append(datas, pair)
@@ -151,6 +166,9 @@ func zsetAdaptor(db *DB) *adaptor {
 	adp.expire = db.ZExpire
 	adp.expireAt = db.ZExpireAt
 	adp.ttl = db.ZTTL
+	adp.pexpire = db.ZPExpire
+	adp.pexpireAt = db.ZPExpireAt
+	adp.pttl = db.ZPTTL
 
 	return adp
 }
@@ -187,6 +205,9 @@ func setAdaptor(db *DB) *adaptor {
 	adp.expire = db.SExpire
 	adp.expireAt = db.SExpireAt
 	adp.ttl = db.STTL
+	adp.pexpire = db.SPExpire
+	adp.pexpireAt = db.SPExpireAt
+	adp.pttl = db.SPTTL
 
 	return adp
 
@@ -435,6 +456,54 @@ func TestExpCompose(t *testing.T) {
 	return
 }
 
+func TestPExpire(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	k := []byte("pexpire_key")
+
+	for _, entry := range allAdaptors(db) {
+		ident := entry.showIdent()
+
+		entry.set(k, []byte("1"))
+
+		if ok, _ := entry.pexpire(k, 2000); ok != 1 {
+			t.Fatal(ident, false)
+		}
+
+		if n, _ := entry.pttl(k); !(1500 < n && n <= 2000) {
+			t.Fatal(ident, n)
+		}
+
+		// ttl is rounded to the nearest second like Redis
+		if n, _ := entry.ttl(k); n != 2 {
+			t.Fatal(ident, n)
+		}
+
+		when := nowMilli() + 100
+		if ok, _ := entry.pexpireAt(k, when); ok != 1 {
+			t.Fatal(ident, false)
+		}
+
+		if _, err := entry.pexpireAt(k, nowMilli()-1); err != errExpireValue {
+			t.Fatal(ident, err)
+		}
+
+		time.Sleep(150 * time.Millisecond)
+
+		if exist, _ := entry.exists(k); exist != 0 {
+			t.Fatal(ident, "expired key exists")
+		}
+
+		if n, _ := entry.pttl(k); n != -1 {
+			t.Fatal(ident, n)
+		}
+
+		entry.del(k)
+	}
+}
+
 func TestTTLCodec(t *testing.T) {
 	db := getTestDB()
 
@@ -462,3 +531,192 @@ func TestTTLCodec(t *testing.T) {
 	}
 
 }
+
+func TestExpiredRead(t *testing.T) {
+	db := getTestDB()
+	m.Lock()
+	defer m.Unlock()
+
+	key := []byte("__expired_read__")
+	member := []byte("m")
+
+	db.Set(key, []byte("v"))
+	db.RPush(key, member)
+	db.HSet(key, member, []byte("v"))
+	db.SAdd(key, member)
+	db.ZAdd(key, ScorePair{Score: 1, Member: member})
+
+	// the expiration time has already passed but the ttl checker may not delete the data yet
+	when := time.Now().Unix() - 10
+	for _, tp := range []struct {
+		t        *batch
+		dataType byte
+	}{
+		{db.kvBatch, KVType},
+		{db.listBatch, ListType},
+		{db.hashBatch, HashType},
+		{db.setBatch, SetType},
+		{db.zsetBatch, ZSetType},
+	} {
+		tp.t.Lock()
+		db.expireAt(tp.t, tp.dataType, key, when)
+		err := tp.t.Commit()
+		tp.t.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, entry := range allAdaptors(db) {
+		if n, err := entry.exists(key); err != nil {
+			t.Fatal(entry.showIdent(), err)
+		} else if n != 0 {
+			t.Fatal(entry.showIdent(), n)
+		}
+	}
+
+	if v, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	if v, err := db.LRange(key, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 0 {
+		t.Fatal(len(v))
+	}
+
+	if v, err := db.HGet(key, member); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(string(v))
+	}
+
+	if n, err := db.SIsMember(key, member); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := db.ZScore(key, member); err != ErrScoreMiss {
+		t.Fatal(err)
+	}
+
+	if n, err := db.ZCard(key); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if keys, err := db.Scan(KV, key, 1, true, ""); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 0 && string(keys[0]) == string(key) {
+		t.Fatal("expired key is scanned")
+	}
+
+	// update an expired key works like a new key
+	if n, err := db.Append(key, []byte("a")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.RPush(key, member); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.SAdd(key, []byte("m2")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := db.SCard(key); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	for _, entry := range []*adaptor{kvAdaptor(db), listAdaptor(db), setAdaptor(db)} {
+		if n, err := entry.ttl(key); err != nil {
+			t.Fatal(entry.showIdent(), err)
+		} else if n != -1 {
+			t.Fatal(entry.showIdent(), n)
+		}
+	}
+
+	db.Del(key)
+	db.LClear(key)
+	db.HClear(key)
+	db.SClear(key)
+	db.ZClear(key)
+}
+
+func TestExpiredWriteCommit(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_expired_commit"
+	cfg.UseReplication = true
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	key := []byte("a")
+	db.HMset(key, FVPair{[]byte("f1"), []byte("1")}, FVPair{[]byte("f2"), []byte("2")})
+
+	db.hashBatch.Lock()
+	db.expireAt(db.hashBatch, HashType, key, nowMilli()-1000)
+	err = db.hashBatch.Commit()
+	db.hashBatch.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, _ := l.ReplicationStat()
+	lastID := st.LastID
+
+	// the expired data is deleted in the same commit of the write
+	if n, err := db.HSet(key, []byte("f1"), []byte("3")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if st, _ = l.ReplicationStat(); st.LastID != lastID+1 {
+		t.Fatal(st.LastID, lastID)
+	}
+
+	if n, err := db.HLen(key); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	} else if v, _ := db.HGet(key, []byte("f2")); v != nil {
+		t.Fatal(string(v))
+	}
+
+	// the deletion is rolled back with the failed write
+	db.hashBatch.Lock()
+	db.expireAt(db.hashBatch, HashType, key, nowMilli()-1000)
+	err = db.hashBatch.Commit()
+	db.hashBatch.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = db.HMset(key, FVPair{[]byte("f3"), []byte("3")}, FVPair{nil, []byte("4")}); err == nil {
+		t.Fatal("must fail for the empty field")
+	} else if v, _ := db.bucket.Get(db.hEncodeHashKey(key, []byte("f1"))); string(v) != "3" {
+		t.Fatal(string(v))
+	} else if n, _ := db.HLen(key); n != 0 {
+		t.Fatal(n)
+	}
+}
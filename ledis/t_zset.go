@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"math"
 	"time"
 
 	"github.com/siddontang/go/hack"
@@ -12,33 +13,36 @@ import (
 
 // For zset const.
 const (
-	MinScore     int64 = -1<<63 + 1
-	MaxScore     int64 = 1<<63 - 1
-	InvalidScore int64 = -1 << 63
-
 	AggregateSum byte = 0
 	AggregateMin byte = 1
 	AggregateMax byte = 2
 )
 
+// For zset score range, same as -inf and +inf in Redis.
+var (
+	MinScore = math.Inf(-1)
+	MaxScore = math.Inf(1)
+
+	// InvalidScore is NaN, check it with math.IsNaN.
+	InvalidScore = math.NaN()
+)
+
 // ScorePair is the pair of score and member.
 type ScorePair struct {
-	Score  int64
+	Score  float64
 	Member []byte
 }
 
 var errZSizeKey = errors.New("invalid zsize key")
 var errZSetKey = errors.New("invalid zset key")
 var errZScoreKey = errors.New("invalid zscore key")
-var errScoreOverflow = errors.New("zset score overflow")
+var errScoreNaN = errors.New("resulting score is not a number (NaN)")
 var errInvalidAggregate = errors.New("invalid aggregate")
 var errInvalidWeightNum = errors.New("invalid weight number")
 var errInvalidSrcKeyNum = errors.New("invalid src key number")
 
 const (
-	zsetNScoreSep    byte = '<'
-	zsetPScoreSep    byte = zsetNScoreSep + 1
-	zsetStopScoreSep byte = zsetPScoreSep + 1
+	zsetScoreSep byte = '<'
 
 	zsetStartMemSep byte = ':'
 	zsetStopMemSep  byte = zsetStartMemSep + 1
@@ -141,7 +145,30 @@ func (db *DB) zEncodeStopSetKey(key []byte) []byte {
 	return k
 }
 
-func (db *DB) zEncodeScoreKey(key []byte, member []byte, score int64) []byte {
+// zEncodeScore encodes the float64 score to an uint64 which has the same order
+// in big endian bytes. For positive scores the sign bit is flipped, for negative
+// scores all bits are flipped.
+func zEncodeScore(score float64) uint64 {
+	if score == 0 {
+		// -0 and +0 are the same score
+		score = 0
+	}
+
+	u := math.Float64bits(score)
+	if u&(1<<63) == 0 {
+		return u | 1<<63
+	}
+	return ^u
+}
+
+func zDecodeScore(u uint64) float64 {
+	if u&(1<<63) != 0 {
+		return math.Float64frombits(u &^ (1 << 63))
+	}
+	return math.Float64frombits(^u)
+}
+
+func (db *DB) zEncodeScoreKey(key []byte, member []byte, score float64) []byte {
 	buf := make([]byte, len(key)+len(member)+13+len(db.indexVarBuf))
 
 	pos := copy(buf, db.indexVarBuf)
@@ -155,14 +182,10 @@ func (db *DB) zEncodeScoreKey(key []byte, member []byte, score int64) []byte {
 	copy(buf[pos:], key)
 	pos += len(key)
 
-	if score < 0 {
-		buf[pos] = zsetNScoreSep
-	} else {
-		buf[pos] = zsetPScoreSep
-	}
-
+	buf[pos] = zsetScoreSep
 	pos++
-	binary.BigEndian.PutUint64(buf[pos:], uint64(score))
+
+	binary.BigEndian.PutUint64(buf[pos:], zEncodeScore(score))
 	pos += 8
 
 	buf[pos] = zsetStartMemSep
@@ -172,17 +195,17 @@ func (db *DB) zEncodeScoreKey(key []byte, member []byte, score int64) []byte {
 	return buf
 }
 
-func (db *DB) zEncodeStartScoreKey(key []byte, score int64) []byte {
+func (db *DB) zEncodeStartScoreKey(key []byte, score float64) []byte {
 	return db.zEncodeScoreKey(key, nil, score)
 }
 
-func (db *DB) zEncodeStopScoreKey(key []byte, score int64) []byte {
+func (db *DB) zEncodeStopScoreKey(key []byte, score float64) []byte {
 	k := db.zEncodeScoreKey(key, nil, score)
 	k[len(k)-1] = zsetStopMemSep
 	return k
 }
 
-func (db *DB) zDecodeScoreKey(ek []byte) (key []byte, member []byte, score int64, err error) {
+func (db *DB) zDecodeScoreKey(ek []byte) (key []byte, member []byte, score float64, err error) {
 	pos := 0
 	pos, err = db.checkKeyIndex(ek)
 	if err != nil {
@@ -215,13 +238,13 @@ func (db *DB) zDecodeScoreKey(ek []byte) (key []byte, member []byte, score int64
 		return
 	}
 
-	if (ek[pos] != zsetNScoreSep) && (ek[pos] != zsetPScoreSep) {
+	if ek[pos] != zsetScoreSep {
 		err = errZScoreKey
 		return
 	}
 	pos++
 
-	score = int64(binary.BigEndian.Uint64(ek[pos:]))
+	score = zDecodeScore(binary.BigEndian.Uint64(ek[pos:]))
 	pos += 8
 
 	if ek[pos] != zsetStartMemSep {
@@ -235,9 +258,9 @@ func (db *DB) zDecodeScoreKey(ek []byte) (key []byte, member []byte, score int64
 	return
 }
 
-func (db *DB) zSetItem(t *batch, key []byte, score int64, member []byte) (int64, error) {
-	if score <= MinScore || score >= MaxScore {
-		return 0, errScoreOverflow
+func (db *DB) zSetItem(t *batch, key []byte, score float64, member []byte) (int64, error) {
+	if math.IsNaN(score) {
+		return 0, errScoreNaN
 	}
 
 	var exists int64
@@ -248,7 +271,7 @@ func (db *DB) zSetItem(t *batch, key []byte, score int64, member []byte) (int64,
 	} else if v != nil {
 		exists = 1
 
-		s, err := Int64(v, err)
+		s, err := Float64(v, err)
 		if err != nil {
 			return 0, err
 		}
@@ -257,7 +280,7 @@ func (db *DB) zSetItem(t *batch, key []byte, score int64, member []byte) (int64,
 		t.Delete(sk)
 	}
 
-	t.Put(ek, PutInt64(score))
+	t.Put(ek, PutFloat64(score))
 
 	sk := db.zEncodeScoreKey(key, member, score)
 	t.Put(sk, []byte{})
@@ -276,7 +299,7 @@ func (db *DB) zDelItem(t *batch, key []byte, member []byte, skipDelScore bool) (
 		//exists
 		if !skipDelScore {
 			//we must del score
-			s, err := Int64(v, err)
+			s, err := Float64(v, err)
 			if err != nil {
 				return 0, err
 			}
@@ -306,6 +329,7 @@ func (db *DB) zExpireAt(key []byte, when int64) (int64, error) {
 	}
 
 	db.expireAt(t, ZSetType, key, when)
+	db.notify(t, ZSET, "expire", key)
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -323,6 +347,10 @@ func (db *DB) ZAdd(key []byte, args ...ScorePair) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	var num int64
 	for i := 0; i < len(args); i++ {
 		score := args[i].Score
@@ -344,6 +372,8 @@ func (db *DB) ZAdd(key []byte, args ...ScorePair) (int64, error) {
 		return 0, err
 	}
 
+	db.notify(t, ZSET, "zadd", key)
+
 	err := t.Commit()
 	return num, err
 }
@@ -355,12 +385,18 @@ func (db *DB) zIncrSize(t *batch, key []byte, delta int64) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	size += delta
-	if size <= 0 {
+	if size+delta <= 0 {
+		if size > 0 {
+			db.incrKeyNum(t, ZSET, -1)
+		}
 		size = 0
 		t.Delete(sk)
 		db.rmExpire(t, ZSetType, key)
 	} else {
+		if size <= 0 {
+			db.incrKeyNum(t, ZSET, 1)
+		}
+		size += delta
 		t.Put(sk, PutInt64(size))
 	}
 
@@ -373,16 +409,26 @@ func (db *DB) ZCard(key []byte) (int64, error) {
 		return 0, err
 	}
 
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	sk := db.zEncodeSizeKey(key)
 	return Int64(db.bucket.Get(sk))
 }
 
 // ZScore gets the score of member.
-func (db *DB) ZScore(key []byte, member []byte) (int64, error) {
+func (db *DB) ZScore(key []byte, member []byte) (float64, error) {
 	if err := checkZSetKMSize(key, member); err != nil {
 		return InvalidScore, err
 	}
 
+	if exp, err := db.expired(ZSetType, key); err != nil {
+		return InvalidScore, err
+	} else if exp {
+		return InvalidScore, ErrScoreMiss
+	}
+
 	score := InvalidScore
 
 	k := db.zEncodeSetKey(key, member)
@@ -391,7 +437,7 @@ func (db *DB) ZScore(key []byte, member []byte) (int64, error) {
 	} else if v == nil {
 		return InvalidScore, ErrScoreMiss
 	} else {
-		if score, err = Int64(v, nil); err != nil {
+		if score, err = Float64(v, nil); err != nil {
 			return InvalidScore, err
 		}
 	}
@@ -409,6 +455,10 @@ func (db *DB) ZRem(key []byte, members ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	var num int64
 	for i := 0; i < len(members); i++ {
 		if err := checkZSetKMSize(key, members[i]); err != nil {
@@ -422,16 +472,24 @@ func (db *DB) ZRem(key []byte, members ...[]byte) (int64, error) {
 		}
 	}
 
-	if _, err := db.zIncrSize(t, key, -num); err != nil {
+	size, err := db.zIncrSize(t, key, -num)
+	if err != nil {
 		return 0, err
 	}
 
-	err := t.Commit()
+	if num > 0 {
+		db.notify(t, ZSET, "zrem", key)
+		if size == 0 {
+			db.notify(t, ZSET, "del", key)
+		}
+	}
+
+	err = t.Commit()
 	return num, err
 }
 
 // ZIncrBy increases the score of member with delta.
-func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
+func (db *DB) ZIncrBy(key []byte, delta float64, member []byte) (float64, error) {
 	if err := checkZSetKMSize(key, member); err != nil {
 		return InvalidScore, err
 	}
@@ -440,28 +498,33 @@ func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, key); err != nil {
+		return InvalidScore, err
+	}
+
 	ek := db.zEncodeSetKey(key, member)
 
-	var oldScore int64
+	var oldScore float64
 	v, err := db.bucket.Get(ek)
 	if err != nil {
 		return InvalidScore, err
 	} else if v == nil {
 		db.zIncrSize(t, key, 1)
 	} else {
-		if oldScore, err = Int64(v, err); err != nil {
+		if oldScore, err = Float64(v, err); err != nil {
 			return InvalidScore, err
 		}
 	}
 
 	newScore := oldScore + delta
-	if newScore >= MaxScore || newScore <= MinScore {
-		return InvalidScore, errScoreOverflow
+	if math.IsNaN(newScore) {
+		// e.g. +inf plus -inf
+		return InvalidScore, errScoreNaN
 	}
 
 	sk := db.zEncodeScoreKey(key, member, newScore)
 	t.Put(sk, []byte{})
-	t.Put(ek, PutInt64(newScore))
+	t.Put(ek, PutFloat64(newScore))
 
 	if v != nil {
 		// so as to update score, we must delete the old one
@@ -469,15 +532,22 @@ func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 		t.Delete(oldSk)
 	}
 
+	db.notify(t, ZSET, "zincr", key)
+
 	err = t.Commit()
 	return newScore, err
 }
 
 // ZCount gets the number of score in [min, max]
-func (db *DB) ZCount(key []byte, min int64, max int64) (int64, error) {
+func (db *DB) ZCount(key []byte, min float64, max float64) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	minKey := db.zEncodeStartScoreKey(key, min)
 	maxKey := db.zEncodeStopScoreKey(key, max)
 
@@ -498,6 +568,10 @@ func (db *DB) zrank(key []byte, member []byte, reverse bool) (int64, error) {
 		return 0, err
 	}
 
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return -1, err
+	}
+
 	k := db.zEncodeSetKey(key, member)
 
 	it := db.bucket.NewIterator()
@@ -508,7 +582,7 @@ func (db *DB) zrank(key []byte, member []byte, reverse bool) (int64, error) {
 		return -1, nil
 	}
 
-	s, err := Int64(v, nil)
+	s, err := Float64(v, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -542,7 +616,7 @@ func (db *DB) zrank(key []byte, member []byte, reverse bool) (int64, error) {
 	return -1, nil
 }
 
-func (db *DB) zIterator(key []byte, min int64, max int64, offset int, count int, reverse bool) *store.RangeLimitIterator {
+func (db *DB) zIterator(key []byte, min float64, max float64, offset int, count int, reverse bool) *store.RangeLimitIterator {
 	minKey := db.zEncodeStartScoreKey(key, min)
 	maxKey := db.zEncodeStopScoreKey(key, max)
 
@@ -552,7 +626,7 @@ func (db *DB) zIterator(key []byte, min int64, max int64, offset int, count int,
 	return db.bucket.RevRangeLimitIterator(minKey, maxKey, store.RangeClose, offset, count)
 }
 
-func (db *DB) zRemRange(t *batch, key []byte, min int64, max int64, offset int, count int) (int64, error) {
+func (db *DB) zRemRange(t *batch, key []byte, min float64, max float64, offset int, count int) (int64, error) {
 	if len(key) > MaxKeySize {
 		return 0, errKeySize
 	}
@@ -583,7 +657,7 @@ func (db *DB) zRemRange(t *batch, key []byte, min int64, max int64, offset int,
 	return num, nil
 }
 
-func (db *DB) zRange(key []byte, min int64, max int64, offset int, count int, reverse bool) ([]ScorePair, error) {
+func (db *DB) zRange(key []byte, min float64, max float64, offset int, count int, reverse bool) ([]ScorePair, error) {
 	if len(key) > MaxKeySize {
 		return nil, errKeySize
 	}
@@ -592,6 +666,12 @@ func (db *DB) zRange(key []byte, min int64, max int64, offset int, count int, re
 		return []ScorePair{}, nil
 	}
 
+	if exp, err := db.expired(ZSetType, key); err != nil {
+		return nil, err
+	} else if exp {
+		return []ScorePair{}, nil
+	}
+
 	nv := count
 	// count may be very large, so we must limit it for below mem make.
 	if nv <= 0 || nv > 1024 {
@@ -676,6 +756,9 @@ func (db *DB) ZClear(key []byte) (int64, error) {
 
 	rmCnt, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1)
 	if err == nil {
+		if rmCnt > 0 {
+			db.notify(t, ZSET, "del", key)
+		}
 		err = t.Commit()
 	}
 
@@ -689,8 +772,10 @@ func (db *DB) ZMclear(keys ...[]byte) (int64, error) {
 	defer t.Unlock()
 
 	for _, key := range keys {
-		if _, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1); err != nil {
+		if n, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1); err != nil {
 			return 0, err
+		} else if n > 0 {
+			db.notify(t, ZSET, "del", key)
 		}
 	}
 
@@ -707,7 +792,7 @@ func (db *DB) ZRange(key []byte, start int, stop int) ([]ScorePair, error) {
 // ZRangeByScore gets the data with score in min and max.
 // min and max must be inclusive
 // if no limit, set offset = 0 and count = -1
-func (db *DB) ZRangeByScore(key []byte, min int64, max int64,
+func (db *DB) ZRangeByScore(key []byte, min float64, max float64,
 	offset int, count int) ([]ScorePair, error) {
 	return db.ZRangeByScoreGeneric(key, min, max, offset, count, false)
 }
@@ -730,8 +815,15 @@ func (db *DB) ZRemRangeByRank(key []byte, start int, stop int) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	rmCnt, err = db.zRemRange(t, key, MinScore, MaxScore, offset, count)
 	if err == nil {
+		if rmCnt > 0 {
+			db.notify(t, ZSET, "zremrangebyrank", key)
+		}
 		err = t.Commit()
 	}
 
@@ -739,13 +831,20 @@ func (db *DB) ZRemRangeByRank(key []byte, start int, stop int) (int64, error) {
 }
 
 // ZRemRangeByScore removes the data with score at [min, max]
-func (db *DB) ZRemRangeByScore(key []byte, min int64, max int64) (int64, error) {
+func (db *DB) ZRemRangeByScore(key []byte, min float64, max float64) (int64, error) {
 	t := db.zsetBatch
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	rmCnt, err := db.zRemRange(t, key, min, max, 0, -1)
 	if err == nil {
+		if rmCnt > 0 {
+			db.notify(t, ZSET, "zremrangebyscore", key)
+		}
 		err = t.Commit()
 	}
 
@@ -765,7 +864,7 @@ func (db *DB) ZRevRank(key []byte, member []byte) (int64, error) {
 // ZRevRangeByScore gets the data with score at [min, max]
 // min and max must be inclusive
 // if no limit, set offset = 0 and count = -1
-func (db *DB) ZRevRangeByScore(key []byte, min int64, max int64, offset int, count int) ([]ScorePair, error) {
+func (db *DB) ZRevRangeByScore(key []byte, min float64, max float64, offset int, count int) ([]ScorePair, error) {
 	return db.ZRangeByScoreGeneric(key, min, max, offset, count, true)
 }
 
@@ -782,7 +881,7 @@ func (db *DB) ZRangeGeneric(key []byte, start int, stop int, reverse bool) ([]Sc
 // ZRangeByScoreGeneric is a generic function to scan zset with score.
 // min and max must be inclusive
 // if no limit, set offset = 0 and count = -1
-func (db *DB) ZRangeByScoreGeneric(key []byte, min int64, max int64,
+func (db *DB) ZRangeByScoreGeneric(key []byte, min float64, max float64,
 	offset int, count int, reverse bool) ([]ScorePair, error) {
 
 	return db.zRange(key, min, max, offset, count, reverse)
@@ -801,7 +900,7 @@ func (db *DB) ZExpire(key []byte, duration int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.zExpireAt(key, time.Now().Unix()+duration)
+	return db.zExpireAt(key, nowMilli()+duration*1000)
 }
 
 // ZExpireAt expires the zset at when.
@@ -810,7 +909,7 @@ func (db *DB) ZExpireAt(key []byte, when int64) (int64, error) {
 		return 0, errExpireValue
 	}
 
-	return db.zExpireAt(key, when)
+	return db.zExpireAt(key, when*1000)
 }
 
 // ZTTL gets the TTL of zset.
@@ -822,6 +921,33 @@ func (db *DB) ZTTL(key []byte) (int64, error) {
 	return db.ttl(ZSetType, key)
 }
 
+// ZPExpire expires the zset with duration in milliseconds.
+func (db *DB) ZPExpire(key []byte, duration int64) (int64, error) {
+	if duration <= 0 {
+		return 0, errExpireValue
+	}
+
+	return db.zExpireAt(key, nowMilli()+duration)
+}
+
+// ZPExpireAt expires the zset at when, the unix time in milliseconds.
+func (db *DB) ZPExpireAt(key []byte, when int64) (int64, error) {
+	if when <= nowMilli() {
+		return 0, errExpireValue
+	}
+
+	return db.zExpireAt(key, when)
+}
+
+// ZPTTL gets the TTL of zset in milliseconds.
+func (db *DB) ZPTTL(key []byte) (int64, error) {
+	if err := checkKeySize(key); err != nil {
+		return -1, err
+	}
+
+	return db.pttl(ZSetType, key)
+}
+
 // ZPersist removes the TTL of zset.
 func (db *DB) ZPersist(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
@@ -832,30 +958,42 @@ func (db *DB) ZPersist(key []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	n, err := db.rmExpire(t, ZSetType, key)
 	if err != nil {
 		return 0, err
 	}
 
+	if n > 0 {
+		db.notify(t, ZSET, "persist", key)
+	}
+
 	err = t.Commit()
 	return n, err
 }
 
-func getAggregateFunc(aggregate byte) func(int64, int64) int64 {
+func getAggregateFunc(aggregate byte) func(float64, float64) float64 {
 	switch aggregate {
 	case AggregateSum:
-		return func(a int64, b int64) int64 {
-			return a + b
+		return func(a float64, b float64) float64 {
+			// same as Redis, +inf plus -inf is 0
+			if s := a + b; !math.IsNaN(s) {
+				return s
+			}
+			return 0
 		}
 	case AggregateMax:
-		return func(a int64, b int64) int64 {
+		return func(a float64, b float64) float64 {
 			if a > b {
 				return a
 			}
 			return b
 		}
 	case AggregateMin:
-		return func(a int64, b int64) int64 {
+		return func(a float64, b float64) float64 {
 			if a > b {
 				return b
 			}
@@ -865,10 +1003,19 @@ func getAggregateFunc(aggregate byte) func(int64, int64) int64 {
 	return nil
 }
 
+// zWeightScore returns the score multiplied by weight, same as Redis,
+// the NaN result like inf * 0 is treated as 0.
+func zWeightScore(score float64, weight float64) float64 {
+	if s := score * weight; !math.IsNaN(s) {
+		return s
+	}
+	return 0
+}
+
 // ZUnionStore unions the zsets and stores to dest zset.
-func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, aggregate byte) (int64, error) {
+func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []float64, aggregate byte) (int64, error) {
 
-	var destMap = map[string]int64{}
+	var destMap = map[string]float64{}
 	aggregateFunc := getAggregateFunc(aggregate)
 	if aggregateFunc == nil {
 		return 0, errInvalidAggregate
@@ -881,7 +1028,7 @@ func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 			return 0, errInvalidWeightNum
 		}
 	} else {
-		weights = make([]int64, len(srcKeys))
+		weights = make([]float64, len(srcKeys))
 		for i := 0; i < len(weights); i++ {
 			weights[i] = 1
 		}
@@ -894,9 +1041,9 @@ func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 		}
 		for _, pair := range scorePairs {
 			if score, ok := destMap[hack.String(pair.Member)]; !ok {
-				destMap[hack.String(pair.Member)] = pair.Score * weights[i]
+				destMap[hack.String(pair.Member)] = zWeightScore(pair.Score, weights[i])
 			} else {
-				destMap[hack.String(pair.Member)] = aggregateFunc(score, pair.Score*weights[i])
+				destMap[hack.String(pair.Member)] = aggregateFunc(score, zWeightScore(pair.Score, weights[i]))
 			}
 		}
 	}
@@ -905,6 +1052,10 @@ func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, destKey); err != nil {
+		return 0, err
+	}
+
 	db.zDelete(t, destKey)
 
 	for member, score := range destMap {
@@ -920,6 +1071,11 @@ func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 	var n = int64(len(destMap))
 	sk := db.zEncodeSizeKey(destKey)
 	t.Put(sk, PutInt64(n))
+	if n > 0 {
+		db.incrKeyNum(t, ZSET, 1)
+	}
+
+	db.notify(t, ZSET, "zunionstore", destKey)
 
 	if err := t.Commit(); err != nil {
 		return 0, err
@@ -928,7 +1084,7 @@ func (db *DB) ZUnionStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 }
 
 // ZInterStore intersects the zsets and stores to dest zset.
-func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, aggregate byte) (int64, error) {
+func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []float64, aggregate byte) (int64, error) {
 
 	aggregateFunc := getAggregateFunc(aggregate)
 	if aggregateFunc == nil {
@@ -942,19 +1098,19 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 			return 0, errInvalidWeightNum
 		}
 	} else {
-		weights = make([]int64, len(srcKeys))
+		weights = make([]float64, len(srcKeys))
 		for i := 0; i < len(weights); i++ {
 			weights[i] = 1
 		}
 	}
 
-	var destMap = map[string]int64{}
+	var destMap = map[string]float64{}
 	scorePairs, err := db.ZRange(srcKeys[0], 0, -1)
 	if err != nil {
 		return 0, err
 	}
 	for _, pair := range scorePairs {
-		destMap[hack.String(pair.Member)] = pair.Score * weights[0]
+		destMap[hack.String(pair.Member)] = zWeightScore(pair.Score, weights[0])
 	}
 
 	for i, key := range srcKeys[1:] {
@@ -962,10 +1118,10 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 		if err != nil {
 			return 0, err
 		}
-		tmpMap := map[string]int64{}
+		tmpMap := map[string]float64{}
 		for _, pair := range scorePairs {
 			if score, ok := destMap[hack.String(pair.Member)]; ok {
-				tmpMap[hack.String(pair.Member)] = aggregateFunc(score, pair.Score*weights[i+1])
+				tmpMap[hack.String(pair.Member)] = aggregateFunc(score, zWeightScore(pair.Score, weights[i+1]))
 			}
 		}
 		destMap = tmpMap
@@ -975,6 +1131,10 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, destKey); err != nil {
+		return 0, err
+	}
+
 	db.zDelete(t, destKey)
 
 	for member, score := range destMap {
@@ -989,6 +1149,11 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 	n := int64(len(destMap))
 	sk := db.zEncodeSizeKey(destKey)
 	t.Put(sk, PutInt64(n))
+	if n > 0 {
+		db.incrKeyNum(t, ZSET, 1)
+	}
+
+	db.notify(t, ZSET, "zinterstore", destKey)
 
 	if err := t.Commit(); err != nil {
 		return 0, err
@@ -998,6 +1163,12 @@ func (db *DB) ZInterStore(destKey []byte, srcKeys [][]byte, weights []int64, agg
 
 // ZRangeByLex scans the zset lexicographically
 func (db *DB) ZRangeByLex(key []byte, min []byte, max []byte, rangeType uint8, offset int, count int) ([][]byte, error) {
+	if exp, err := db.expired(ZSetType, key); err != nil {
+		return nil, err
+	} else if exp {
+		return [][]byte{}, nil
+	}
+
 	if min == nil {
 		min = db.zEncodeStartSetKey(key)
 	} else {
@@ -1039,6 +1210,10 @@ func (db *DB) ZRemRangeByLex(key []byte, min []byte, max []byte, rangeType uint8
 	t.Lock()
 	defer t.Unlock()
 
+	if err := db.delExpired(t, ZSetType, key); err != nil {
+		return 0, err
+	}
+
 	it := db.bucket.RangeIterator(min, max, rangeType)
 	defer it.Close()
 
@@ -1048,6 +1223,10 @@ func (db *DB) ZRemRangeByLex(key []byte, min []byte, max []byte, rangeType uint8
 		n++
 	}
 
+	if n > 0 {
+		db.notify(t, ZSET, "zremrangebylex", key)
+	}
+
 	if err := t.Commit(); err != nil {
 		return 0, err
 	}
@@ -1057,6 +1236,10 @@ func (db *DB) ZRemRangeByLex(key []byte, min []byte, max []byte, rangeType uint8
 
 // ZLexCount gets the count of zset lexicographically.
 func (db *DB) ZLexCount(key []byte, min []byte, max []byte, rangeType uint8) (int64, error) {
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	if min == nil {
 		min = db.zEncodeStartSetKey(key)
 	} else {
@@ -1084,6 +1267,11 @@ func (db *DB) ZKeyExists(key []byte) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
+
+	if exp, err := db.expired(ZSetType, key); err != nil || exp {
+		return 0, err
+	}
+
 	sk := db.zEncodeSizeKey(key)
 	v, err := db.bucket.Get(sk)
 	if v != nil && err == nil {
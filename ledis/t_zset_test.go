@@ -2,6 +2,7 @@ package ledis
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
@@ -17,7 +18,7 @@ func bin(sz string) []byte {
 }
 
 func pair(memb string, score int) ScorePair {
-	return ScorePair{int64(score), bin(memb)}
+	return ScorePair{float64(score), bin(memb)}
 }
 
 func TestZSetCodec(t *testing.T) {
@@ -80,8 +81,8 @@ func TestDBZSet(t *testing.T) {
 		t.Fatal(s)
 	}
 
-	if s, err := db.ZScore(key, bin("zzz")); err != ErrScoreMiss || s != InvalidScore {
-		t.Fatal(fmt.Sprintf("s=[%d] err=[%s]", s, err))
+	if s, err := db.ZScore(key, bin("zzz")); err != ErrScoreMiss || !math.IsNaN(s) {
+		t.Fatal(fmt.Sprintf("s=[%v] err=[%s]", s, err))
 	}
 
 	// {c':2, 'd':3}
@@ -207,10 +208,10 @@ func TestZSetOrder(t *testing.T) {
 	if datas, _ := db.ZRange(key, 0, endPos); len(datas) != 6 {
 		t.Fatal(len(datas))
 	} else {
-		scores := []int64{0, 1, 2, 5, 6, 999}
+		scores := []float64{0, 1, 2, 5, 6, 999}
 		for i := 0; i < len(datas); i++ {
 			if datas[i].Score != scores[i] {
-				t.Fatal(fmt.Sprintf("[%d]=%d", i, datas[i]))
+				t.Fatal(fmt.Sprintf("[%d]=%v", i, datas[i]))
 			}
 		}
 	}
@@ -253,7 +254,7 @@ func TestZUnionStore(t *testing.T) {
 	db.ZAdd(key2, ScorePair{2, []byte("three")})
 
 	keys := [][]byte{key1, key2}
-	weights := []int64{1, 2}
+	weights := []float64{1, 2}
 
 	out := []byte("out")
 
@@ -326,7 +327,7 @@ func TestZInterStore(t *testing.T) {
 	db.ZAdd(key2, ScorePair{2, []byte("three")})
 
 	keys := [][]byte{key1, key2}
-	weights := []int64{2, 3}
+	weights := []float64{2, 3}
 	out := []byte("out")
 
 	db.ZAdd(out, ScorePair{3, []byte("out")})
@@ -484,3 +485,76 @@ func TestZKeyExists(t *testing.T) {
 		t.Fatal("invalid value ", n)
 	}
 }
+
+func TestZSetScoreCodec(t *testing.T) {
+	scores := []float64{math.Inf(-1), -math.MaxFloat64, -1e10, -1.5, -1, -math.SmallestNonzeroFloat64,
+		0, math.SmallestNonzeroFloat64, 0.1, 1, 1.5, 1e10, math.MaxFloat64, math.Inf(1)}
+
+	for i, s := range scores {
+		if v := zDecodeScore(zEncodeScore(s)); v != s {
+			t.Fatal(s, v)
+		}
+
+		if i > 0 && zEncodeScore(scores[i-1]) >= zEncodeScore(s) {
+			t.Fatal("invalid order", scores[i-1], s)
+		}
+	}
+
+	if zEncodeScore(math.Copysign(0, -1)) != zEncodeScore(0) {
+		t.Fatal("-0 must be the same as 0")
+	}
+}
+
+func TestZSetFloatScore(t *testing.T) {
+	db := getTestDB()
+	key := []byte("zset_float_score_test")
+	db.ZClear(key)
+
+	db.ZAdd(key, ScorePair{1.5, bin("a")}, ScorePair{-2.25, bin("b")}, ScorePair{0, bin("c")},
+		ScorePair{math.Inf(1), bin("d")}, ScorePair{math.Inf(-1), bin("e")})
+
+	if _, err := db.ZAdd(key, ScorePair{math.NaN(), bin("f")}); err == nil {
+		t.Fatal("NaN score must fail")
+	}
+
+	if v, err := db.ZRangeByScore(key, MinScore, MaxScore, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []ScorePair{{math.Inf(-1), bin("e")}, {-2.25, bin("b")}, {0, bin("c")},
+		{1.5, bin("a")}, {math.Inf(1), bin("d")}}) {
+		t.Fatal(v)
+	}
+
+	if n, err := db.ZCount(key, -2.25, 1.5); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	if s, err := db.ZIncrBy(key, 0.25, bin("a")); err != nil {
+		t.Fatal(err)
+	} else if s != 1.75 {
+		t.Fatal(s)
+	}
+
+	if _, err := db.ZIncrBy(key, math.Inf(-1), bin("d")); err == nil {
+		t.Fatal("+inf plus -inf must fail")
+	}
+
+	dest := []byte("zset_float_score_dest")
+	if _, err := db.ZUnionStore(dest, [][]byte{key}, []float64{0.5}, AggregateSum); err != nil {
+		t.Fatal(err)
+	} else if s, err := db.ZScore(dest, bin("b")); err != nil {
+		t.Fatal(err)
+	} else if s != -1.125 {
+		t.Fatal(s)
+	}
+
+	if _, err := db.ZUnionStore(dest, [][]byte{key}, []float64{0}, AggregateSum); err != nil {
+		t.Fatal(err)
+	} else if s, err := db.ZScore(dest, bin("d")); err != nil {
+		t.Fatal(err)
+	} else if s != 0 {
+		// inf * 0 is 0 like Redis
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,198 @@
+package ledis
+
+import (
+	"errors"
+	"math"
+
+	"github.com/siddontang/ledisdb/store"
+)
+
+// For transaction errors.
+var (
+	ErrNestTx = errors.New("nest transaction not supported")
+	ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+	ErrSnapshotInTx = errors.New("snapshot not supported in transaction")
+)
+
+// Tx is the transaction of the DB. All the writes in the transaction are
+// collected in one write batch and committed atomically as one replication
+// log, and the reads in the transaction can see the uncommitted writes.
+//
+// Tx holds the write lock of Ledis until committed or rolled back, so other
+// writes are blocked, and it is not thread safe.
+type Tx struct {
+	*DB
+
+	view *store.BatchView
+
+	// the selected DBs, the ttl checkers of them may need to check earlier
+	dbs map[int]*DB
+
+	// the key events fired after committed
+	events []KeyEvent
+
+	// the changes of the key numbers applied after committed
+	deltas []keyDelta
+}
+
+// IsTransaction returns whether the DB is used in a transaction.
+func (db *DB) IsTransaction() bool {
+	return db.tx != nil
+}
+
+// Begin begins a transaction, the transaction must be committed or rolled back.
+func (db *DB) Begin() (*Tx, error) {
+	if db.IsTransaction() {
+		return nil, ErrNestTx
+	}
+
+	tx := new(Tx)
+
+	tx.DB = new(DB)
+	tx.DB.l = db.l
+
+	tx.l.wLock.Lock()
+
+	tx.view = db.l.ldb.NewBatchView()
+
+	tx.DB.sdb = db.sdb
+	tx.DB.bucket = tx.view
+	tx.DB.tx = tx
+
+	tx.DB.kvBatch = tx.newBatch()
+	tx.DB.listBatch = tx.newBatch()
+	tx.DB.hashBatch = tx.newBatch()
+	tx.DB.zsetBatch = tx.newBatch()
+	tx.DB.setBatch = tx.newBatch()
+
+	tx.DB.ttlChecker = tx.DB.newTTLChecker()
+	tx.DB.ttlChecker.nc = math.MaxInt64
+
+	tx.dbs = make(map[int]*DB)
+	tx.selectDB(db)
+
+	return tx, nil
+}
+
+// Update executes fn in a transaction, all the writes in fn are committed
+// atomically if fn returns nil, otherwise they are rolled back and the error
+// is returned. The transaction is rolled back too if fn panics.
+//
+// fn must not commit or roll back the transaction itself.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+func (tx *Tx) newBatch() *batch {
+	return tx.l.newBatch(tx.l.ldb.NewWriteBatch(), &txBatchLocker{}, tx)
+}
+
+func (tx *Tx) selectDB(db *DB) {
+	tx.DB.setIndex(db.index)
+	tx.DB.lbkeys = db.lbkeys
+	tx.dbs[db.index] = db
+}
+
+// Select chooses the database in the transaction.
+func (tx *Tx) Select(index int) error {
+	if tx.view == nil {
+		return ErrTxDone
+	}
+
+	db, err := tx.l.Select(index)
+	if err != nil {
+		return err
+	}
+
+	tx.selectDB(db)
+	return nil
+}
+
+// Snapshot is not supported, the transaction holds the write lock which
+// the snapshot waits for.
+func (tx *Tx) Snapshot() (*Snapshot, error) {
+	return nil, ErrSnapshotInTx
+}
+
+// Commit commits all the writes of the transaction atomically.
+func (tx *Tx) Commit() error {
+	if tx.view == nil {
+		return ErrTxDone
+	}
+
+	var err error
+	var deltas []keyDelta
+	if wb := tx.view.WriteBatch(); wb.BatchData().Len() > 0 {
+		if tx.l.cfg.GetReadonly() {
+			err = ErrWriteInROnly
+		} else {
+			tx.l.touchWatched(wb)
+			deltas = tx.l.ks.stage(wb, tx.deltas)
+			err = tx.l.handleCommit(wb, wb)
+		}
+	}
+
+	var events []KeyEvent
+	if err == nil {
+		tx.l.ks.apply(deltas)
+		events = tx.events
+
+		// the keys expired in the transaction can be checked earlier
+		tx.ttlChecker.Lock()
+		nc := tx.ttlChecker.nc
+		tx.ttlChecker.Unlock()
+
+		for _, db := range tx.dbs {
+			db.ttlChecker.setNextCheckTime(nc, false)
+		}
+	}
+
+	tx.close()
+
+	// fire after the write lock is released
+	tx.l.fireKeyEvents(events)
+	return err
+}
+
+// Rollback discards all the writes of the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.view == nil {
+		return ErrTxDone
+	}
+
+	err := tx.view.Rollback()
+
+	tx.close()
+	return err
+}
+
+func (tx *Tx) close() {
+	for _, t := range []*batch{tx.kvBatch, tx.listBatch, tx.hashBatch, tx.zsetBatch, tx.setBatch} {
+		t.WriteBatch.Close()
+	}
+
+	tx.view.Close()
+	tx.view = nil
+	tx.events = nil
+	tx.deltas = nil
+
+	tx.l.wLock.Unlock()
+}
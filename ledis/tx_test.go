@@ -0,0 +1,249 @@
+package ledis
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestTx(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_tx"
+	cfg.UseReplication = true
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	key := []byte("tx_key")
+	db.Set(key, []byte("1"))
+
+	lastID, _ := l.r.LastLogID()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Begin(); err != ErrNestTx {
+		t.Fatal(err)
+	}
+
+	if n, err := tx.Incr(key); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	// see the own write in transaction
+	if n, err := tx.Incr(key); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatal(n)
+	}
+
+	tx.HSet(key, []byte("f"), []byte("v"))
+	tx.ZAdd(key, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")})
+	tx.ZRem(key, []byte("a"))
+	tx.RPush(key, []byte("1"), []byte("2"))
+	tx.SAdd(key, []byte("m"))
+
+	if v, err := tx.ZRange(key, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || string(v[0].Member) != "b" {
+		t.Fatal(v)
+	}
+
+	// not committed yet, and the transaction holds the write lock
+	// so only check with the store directly
+	if v, err := l.ldb.Get(db.encodeKVKey(key)); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "1" {
+		t.Fatal(string(v))
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Fatal(err)
+	}
+
+	if id, _ := l.r.LastLogID(); id != lastID+1 {
+		t.Fatalf("transaction must be one log, %d -> %d", lastID, id)
+	}
+
+	if v, _ := db.Get(key); string(v) != "3" {
+		t.Fatal(string(v))
+	}
+
+	if v, _ := db.HGet(key, []byte("f")); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+
+	if n, _ := db.ZCard(key); n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, _ := db.LLen(key); n != 2 {
+		t.Fatal(n)
+	}
+
+	if n, _ := db.SCard(key); n != 1 {
+		t.Fatal(n)
+	}
+
+	// rollback
+	tx, _ = db.Begin()
+	tx.Set(key, []byte("10"))
+	tx.Select(1)
+	tx.Set(key, []byte("10"))
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := db.Get(key); string(v) != "3" {
+		t.Fatal(string(v))
+	}
+
+	db1, _ := l.Select(1)
+	if n, _ := db1.Exists(key); n != 0 {
+		t.Fatal(n)
+	}
+
+	if id, _ := l.r.LastLogID(); id != lastID+1 {
+		t.Fatalf("rollback must not log, %d -> %d", lastID, id)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("update_key")
+	index := []byte("update_index")
+
+	db.HClear(key)
+	db.ZClear(index)
+
+	err := db.Update(func(tx *Tx) error {
+		if _, err := tx.HSet(key, []byte("f"), []byte("v")); err != nil {
+			return err
+		}
+		_, err := tx.ZAdd(index, ScorePair{1, key})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := db.HGet(key, []byte("f")); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+
+	if s, _ := db.ZScore(index, key); s != 1 {
+		t.Fatal(s)
+	}
+
+	// rollback by error
+	errUpdate := errors.New("update error")
+	err = db.Update(func(tx *Tx) error {
+		tx.HSet(key, []byte("f"), []byte("v2"))
+		tx.ZAdd(index, ScorePair{2, key})
+		return errUpdate
+	})
+	if err != errUpdate {
+		t.Fatal(err)
+	}
+
+	if v, _ := db.HGet(key, []byte("f")); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+
+	if s, _ := db.ZScore(index, key); s != 1 {
+		t.Fatal(s)
+	}
+
+	// rollback by panic
+	func() {
+		defer func() {
+			if e := recover(); e == nil {
+				t.Fatal("must panic")
+			}
+		}()
+
+		db.Update(func(tx *Tx) error {
+			tx.HSet(key, []byte("f"), []byte("v3"))
+			panic("update panic")
+		})
+	}()
+
+	if v, _ := db.HGet(key, []byte("f")); string(v) != "v" {
+		t.Fatal(string(v))
+	}
+
+	// the write lock must be released
+	if _, err := db.HSet(key, []byte("f"), []byte("v4")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	db := getTestDB()
+
+	key := []byte("watch_key")
+	db.Del(key)
+	db.HClear(key)
+
+	w := db.l.NewWatcher()
+	defer w.Close()
+
+	w.Watch(db, HASH, key)
+
+	db.Set(key, []byte("1"))
+
+	db1, _ := db.l.Select(1)
+	db1.HSet(key, []byte("f"), []byte("v"))
+
+	if w.Changed() {
+		t.Fatal("other data type or db must not change the watcher")
+	}
+
+	db.HSet(key, []byte("f"), []byte("v"))
+	if !w.Changed() {
+		t.Fatal("must changed")
+	}
+
+	w.Close()
+	if w.Changed() {
+		t.Fatal("must not changed after closed")
+	}
+
+	w.Watch(db, ZSET, key)
+
+	tx, _ := db.Begin()
+	tx.ZAdd(key, ScorePair{1, []byte("a")})
+	if w.Changed() {
+		t.Fatal("must not changed before committed")
+	}
+	tx.Commit()
+
+	if !w.Changed() {
+		t.Fatal("must changed")
+	}
+
+	w.Close()
+	w.Watch(db, ZSET, key)
+	db.ZExpire(key, 100)
+	if !w.Changed() {
+		t.Fatal("must changed by expire")
+	}
+}
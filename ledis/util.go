@@ -3,6 +3,7 @@ package ledis
 import (
 	"encoding/binary"
 	"errors"
+	"math"
 	"strconv"
 
 	"github.com/siddontang/go/hack"
@@ -48,6 +49,47 @@ func PutInt64(v int64) []byte {
 	return b
 }
 
+// Float64 gets 64 float with the little endian format.
+func Float64(v []byte, err error) (float64, error) {
+	u, err := Uint64(v, err)
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Float64frombits(u), nil
+}
+
+// PutFloat64 puts the 64 float.
+func PutFloat64(v float64) []byte {
+	return PutInt64(int64(math.Float64bits(v)))
+}
+
+// StrFloat64 gets the 64 float with string format.
+func StrFloat64(v []byte, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	} else if v == nil {
+		return 0, nil
+	} else {
+		return strconv.ParseFloat(hack.String(v), 64)
+	}
+}
+
+// AppendScore appends the zset score formatted like Redis, inf and -inf for
+// the infinities, and returns the extended buffer.
+func AppendScore(dst []byte, score float64) []byte {
+	if math.IsInf(score, 1) {
+		return append(dst, "inf"...)
+	} else if math.IsInf(score, -1) {
+		return append(dst, "-inf"...)
+	}
+
+	if a := math.Abs(score); a == 0 || (a >= 1e-4 && a < 1e17) {
+		return strconv.AppendFloat(dst, score, 'f', -1, 64)
+	}
+	return strconv.AppendFloat(dst, score, 'g', -1, 64)
+}
+
 // StrInt64 gets the 64 integer with string format.
 func StrInt64(v []byte, err error) (int64, error) {
 	if err != nil {
@@ -0,0 +1,203 @@
+package ledis
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/siddontang/go/sync2"
+	"github.com/siddontang/ledisdb/store"
+)
+
+var errWatchKey = errors.New("invalid watch key")
+
+type watchKey struct {
+	index    int
+	dataType byte
+	key      string
+}
+
+// Watcher watches keys for the optimistic locking like Redis WATCH,
+// it is changed if any watched key is modified after watching.
+type Watcher struct {
+	l *Ledis
+
+	keys []watchKey
+
+	// protected by the lock of watchers
+	changed bool
+}
+
+type watchers struct {
+	sync.Mutex
+
+	// the number of watched keys, to skip the key decoding
+	// if nothing is watched
+	n sync2.AtomicInt64
+
+	keys map[watchKey]map[*Watcher]struct{}
+}
+
+func newWatchers() *watchers {
+	ws := new(watchers)
+	ws.keys = make(map[watchKey]map[*Watcher]struct{})
+	return ws
+}
+
+// NewWatcher creates a Watcher, it must be closed after use.
+func (l *Ledis) NewWatcher() *Watcher {
+	w := new(Watcher)
+	w.l = l
+	return w
+}
+
+// Watch watches the key of the data type in the DB.
+func (w *Watcher) Watch(db *DB, dataType DataType, key []byte) error {
+	storeDataType, err := getDataStoreType(dataType)
+	if err != nil {
+		return err
+	}
+
+	k := watchKey{db.index, getExpDataType(storeDataType), string(key)}
+
+	ws := w.l.watchers
+	ws.Lock()
+	m, ok := ws.keys[k]
+	if !ok {
+		m = make(map[*Watcher]struct{})
+		ws.keys[k] = m
+	}
+
+	if _, ok = m[w]; !ok {
+		m[w] = struct{}{}
+		w.keys = append(w.keys, k)
+		ws.n.Add(1)
+	}
+	ws.Unlock()
+
+	return nil
+}
+
+// Changed returns whether any watched key has been modified.
+func (w *Watcher) Changed() bool {
+	ws := w.l.watchers
+	ws.Lock()
+	changed := w.changed
+	ws.Unlock()
+	return changed
+}
+
+// Close unwatches all the keys, the Watcher can be used again after closed.
+func (w *Watcher) Close() {
+	ws := w.l.watchers
+	ws.Lock()
+	for _, k := range w.keys {
+		m := ws.keys[k]
+		delete(m, w)
+		if len(m) == 0 {
+			delete(ws.keys, k)
+		}
+		ws.n.Add(-1)
+	}
+	w.keys = nil
+	w.changed = false
+	ws.Unlock()
+}
+
+// touchWatched marks the watchers changed if their keys are in the write batch,
+// it must be called before the write batch is committed.
+func (l *Ledis) touchWatched(wb *store.WriteBatch) {
+	ws := l.watchers
+	if ws.n.Get() == 0 {
+		return
+	}
+
+	items, err := wb.BatchData().Items()
+
+	ws.Lock()
+	defer ws.Unlock()
+
+	if err != nil {
+		ws.touchAll()
+		return
+	}
+
+	for _, item := range items {
+		k, err := decodeWatchKey(item.Key)
+		if err != nil {
+			continue
+		}
+
+		for w := range ws.keys[k] {
+			w.changed = true
+		}
+	}
+}
+
+// touchAllWatched marks all the watchers changed, used when all the data may be changed.
+func (l *Ledis) touchAllWatched() {
+	ws := l.watchers
+	ws.Lock()
+	ws.touchAll()
+	ws.Unlock()
+}
+
+func (ws *watchers) touchAll() {
+	for _, m := range ws.keys {
+		for w := range m {
+			w.changed = true
+		}
+	}
+}
+
+// decodeWatchKey decodes the db index, data type and key from the store key.
+func decodeWatchKey(ek []byte) (k watchKey, err error) {
+	var pos int
+	if k.index, pos, err = decodeDBIndex(ek); err != nil {
+		return
+	} else if pos >= len(ek) {
+		err = errWatchKey
+		return
+	}
+
+	dataType := ek[pos]
+	pos++
+
+	var key []byte
+	switch dataType {
+	case KVType, HSizeType, LMetaType, ZSizeType, SSizeType:
+		key = ek[pos:]
+	case HashType, ListType, ZSetType, ZScoreType, SetType:
+		if pos+2 > len(ek) {
+			err = errWatchKey
+			return
+		}
+		keyLen := int(binary.BigEndian.Uint16(ek[pos:]))
+		pos += 2
+		if pos+keyLen > len(ek) {
+			err = errWatchKey
+			return
+		}
+		key = ek[pos : pos+keyLen]
+		if dataType == ZScoreType {
+			dataType = ZSetType
+		}
+	case ExpMetaType, ExpTimeType:
+		if dataType == ExpTimeType {
+			pos += 8
+		}
+		if pos+1 > len(ek) {
+			err = errWatchKey
+			return
+		}
+		dataType = ek[pos]
+		key = ek[pos+1:]
+	default:
+		err = errWatchKey
+		return
+	}
+
+	k.dataType = getExpDataType(dataType)
+	k.key = string(key)
+	return
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"sync"
+
 	"github.com/siddontang/go/log"
 )
 
@@ -8,28 +10,65 @@ const (
 	accessTimeFormat = "2006/01/02 15:04:05"
 )
 
+// accessLog logs the commands, it can be opened, changed or disabled at runtime.
 type accessLog struct {
+	sync.RWMutex
+
+	// nil if disabled
 	l *log.Logger
 }
 
 func newAcessLog(baseName string) (*accessLog, error) {
 	l := new(accessLog)
 
-	h, err := log.NewTimeRotatingFileHandler(baseName, log.WhenDay, 1)
-	if err != nil {
+	if err := l.open(baseName); err != nil {
 		return nil, err
 	}
 
-	l.l = log.New(h, log.Ltime)
-
 	return l, nil
 }
 
+// open opens the new log file and closes the old one, empty to disable.
+func (l *accessLog) open(baseName string) error {
+	var nl *log.Logger
+	if len(baseName) > 0 {
+		h, err := log.NewTimeRotatingFileHandler(baseName, log.WhenDay, 1)
+		if err != nil {
+			return err
+		}
+
+		nl = log.New(h, log.Ltime)
+	}
+
+	l.Lock()
+	old := l.l
+	l.l = nl
+	l.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (l *accessLog) enabled() bool {
+	l.RLock()
+	b := l.l != nil
+	l.RUnlock()
+	return b
+}
+
 func (l *accessLog) Close() {
-	l.l.Close()
+	l.open("")
 }
 
 func (l *accessLog) Log(remoteAddr string, usedTime int64, request []byte, err error) {
+	l.RLock()
+	defer l.RUnlock()
+
+	if l.l == nil {
+		return
+	}
 
 	format := `%s %q %d [%s]`
 
@@ -0,0 +1,595 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/siddontang/go/hack"
+)
+
+var (
+	errNoPermCommand = errors.New("NOPERM this user has no permissions to run the command")
+	errNoPermKey     = errors.New("NOPERM this user has no permissions to access one of the keys")
+	errNoPermDB      = errors.New("NOPERM this user has no permissions to access the database")
+	errDefaultUser   = errors.New("the default user can not be deleted")
+	errDefaultPass   = errors.New("the password of the default user is auth_password")
+	errNoACLFile     = errors.New("acl_file is not configured")
+)
+
+// the ACL command categories
+const (
+	aclRead        = "read"
+	aclWrite       = "write"
+	aclAdmin       = "admin"
+	aclReplication = "replication"
+	aclScripting   = "scripting"
+)
+
+var aclCategories = []string{aclRead, aclWrite, aclAdmin, aclReplication, aclScripting}
+
+// defaultUser is the user of the clients not authenticated as other users,
+// its password is auth_password or checked by AuthMethod.
+const defaultUser = "default"
+
+// commandCategory returns the category of the command, some subcommands
+// have the different categories.
+func commandCategory(cmd string, args [][]byte) string {
+	var sub string
+	if len(args) > 0 {
+		sub = strings.ToLower(hack.String(args[0]))
+	}
+
+	switch cmd {
+	case "acl":
+		if sub == "whoami" {
+			return ""
+		}
+	case "client":
+		if sub == "id" || sub == "getname" || sub == "setname" {
+			return ""
+		}
+	case "xlsort", "xssort", "xzsort":
+		if len(sortKeys(args)) > 1 {
+			return aclWrite
+		}
+	}
+
+	if c, ok := regCmds[cmd]; ok {
+		return c.category
+	}
+	return ""
+}
+
+// commandKeys returns the keys accessed by the command.
+func commandKeys(cmd string, args [][]byte) [][]byte {
+	if c, ok := regCmds[cmd]; ok {
+		return c.commandKeys(args)
+	}
+	return nil
+}
+
+// aclUser is immutable after added, the changes make a new one.
+type aclUser struct {
+	name    string
+	enabled bool
+
+	// nopass makes any password valid
+	nopass    bool
+	passwords map[string]struct{}
+
+	allCategories bool
+	categories    map[string]struct{}
+
+	// the key glob patterns, all the keys are allowed if any pattern is *
+	patterns []string
+
+	allDBs bool
+	dbs    map[int]struct{}
+}
+
+func newACLUser(name string) *aclUser {
+	u := new(aclUser)
+	u.name = name
+	u.reset()
+	return u
+}
+
+func newDefaultUser() *aclUser {
+	u := newACLUser(defaultUser)
+	u.enabled = true
+	u.allCategories = true
+	u.patterns = []string{"*"}
+	u.allDBs = true
+	return u
+}
+
+func (u *aclUser) reset() {
+	u.enabled = false
+	u.nopass = false
+	u.passwords = make(map[string]struct{})
+	u.allCategories = false
+	u.categories = make(map[string]struct{})
+	u.patterns = nil
+	u.allDBs = false
+	u.dbs = make(map[int]struct{})
+}
+
+func (u *aclUser) clone() *aclUser {
+	n := *u
+
+	n.passwords = make(map[string]struct{}, len(u.passwords))
+	for k := range u.passwords {
+		n.passwords[k] = struct{}{}
+	}
+	n.categories = make(map[string]struct{}, len(u.categories))
+	for k := range u.categories {
+		n.categories[k] = struct{}{}
+	}
+	n.patterns = append([]string(nil), u.patterns...)
+	n.dbs = make(map[int]struct{}, len(u.dbs))
+	for k := range u.dbs {
+		n.dbs[k] = struct{}{}
+	}
+	return &n
+}
+
+func hashPassword(password string) string {
+	h := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(h[:])
+}
+
+func isCategory(name string) bool {
+	for _, c := range aclCategories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setRule applies a rule of ACL SETUSER:
+//
+//	on, off                 enable or disable the user
+//	>password, <password    add or remove the password
+//	#hash, !hash            add or remove the SHA256 hex of the password
+//	nopass, resetpass       allow any password or remove all the passwords
+//	+@category, -@category  allow or disallow the category, @all for all
+//	allcommands, nocommands same as +@all and -@all
+//	~pattern                allow the keys matching the glob pattern
+//	allkeys, resetkeys      same as ~* or disallow all the keys
+//	db=index                allow the database
+//	alldbs, resetdbs        allow all or none of the databases
+//	reset                   remove all the permissions and disable the user
+func (u *aclUser) setRule(rule string) error {
+	lower := strings.ToLower(rule)
+
+	switch {
+	case lower == "on":
+		u.enabled = true
+	case lower == "off":
+		u.enabled = false
+	case lower == "nopass":
+		u.nopass = true
+		u.passwords = make(map[string]struct{})
+	case lower == "resetpass":
+		u.nopass = false
+		u.passwords = make(map[string]struct{})
+	case lower == "allcommands" || lower == "+@all":
+		u.allCategories = true
+		u.categories = make(map[string]struct{})
+	case lower == "nocommands" || lower == "-@all":
+		u.allCategories = false
+		u.categories = make(map[string]struct{})
+	case lower == "allkeys":
+		u.patterns = []string{"*"}
+	case lower == "resetkeys":
+		u.patterns = nil
+	case lower == "alldbs":
+		u.allDBs = true
+		u.dbs = make(map[int]struct{})
+	case lower == "resetdbs":
+		u.allDBs = false
+		u.dbs = make(map[int]struct{})
+	case lower == "reset":
+		u.reset()
+	case strings.HasPrefix(rule, ">"):
+		u.nopass = false
+		u.passwords[hashPassword(rule[1:])] = struct{}{}
+	case strings.HasPrefix(rule, "<"):
+		delete(u.passwords, hashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "#") || strings.HasPrefix(rule, "!"):
+		h := strings.ToLower(rule[1:])
+		if b, err := hex.DecodeString(h); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid password hash '%s'", rule)
+		}
+		if rule[0] == '#' {
+			u.nopass = false
+			u.passwords[h] = struct{}{}
+		} else {
+			delete(u.passwords, h)
+		}
+	case strings.HasPrefix(lower, "+@") || strings.HasPrefix(lower, "-@"):
+		category := lower[2:]
+		if !isCategory(category) {
+			return fmt.Errorf("unknown ACL category '%s'", category)
+		}
+
+		if lower[0] == '+' {
+			if !u.allCategories {
+				u.categories[category] = struct{}{}
+			}
+		} else if u.allCategories {
+			// -@category after +@all allows the other categories
+			u.allCategories = false
+			for _, c := range aclCategories {
+				if c != category {
+					u.categories[c] = struct{}{}
+				}
+			}
+		} else {
+			delete(u.categories, category)
+		}
+	case strings.HasPrefix(rule, "~"):
+		if len(rule) == 1 {
+			return fmt.Errorf("invalid ACL rule '%s'", rule)
+		}
+		u.patterns = append(u.patterns, rule[1:])
+	case strings.HasPrefix(lower, "db="):
+		index, err := strconv.Atoi(rule[3:])
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid ACL rule '%s'", rule)
+		}
+		if !u.allDBs {
+			u.dbs[index] = struct{}{}
+		}
+	default:
+		return fmt.Errorf("unknown ACL rule '%s'", rule)
+	}
+
+	return nil
+}
+
+// rules returns the rules describing the user, used by ACL LIST and the ACL file.
+func (u *aclUser) rules() []string {
+	var rules []string
+	if u.enabled {
+		rules = append(rules, "on")
+	} else {
+		rules = append(rules, "off")
+	}
+
+	if u.nopass {
+		rules = append(rules, "nopass")
+	}
+	for _, h := range u.passwordHashes() {
+		rules = append(rules, "#"+h)
+	}
+
+	if u.allCategories {
+		rules = append(rules, "+@all")
+	} else if len(u.categories) == 0 {
+		rules = append(rules, "-@all")
+	} else {
+		for _, c := range aclCategories {
+			if _, ok := u.categories[c]; ok {
+				rules = append(rules, "+@"+c)
+			}
+		}
+	}
+
+	if len(u.patterns) == 0 {
+		rules = append(rules, "resetkeys")
+	}
+	for _, p := range u.patterns {
+		rules = append(rules, "~"+p)
+	}
+
+	if u.allDBs {
+		rules = append(rules, "alldbs")
+	} else if len(u.dbs) == 0 {
+		rules = append(rules, "resetdbs")
+	}
+	for _, index := range u.dbIndexes() {
+		rules = append(rules, "db="+strconv.Itoa(index))
+	}
+
+	return rules
+}
+
+func (u *aclUser) passwordHashes() []string {
+	hashes := make([]string, 0, len(u.passwords))
+	for h := range u.passwords {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func (u *aclUser) dbIndexes() []int {
+	indexes := make([]int, 0, len(u.dbs))
+	for index := range u.dbs {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func (u *aclUser) checkPassword(password string) bool {
+	if u.nopass {
+		return true
+	}
+	_, ok := u.passwords[hashPassword(password)]
+	return ok
+}
+
+func (u *aclUser) allowCategory(category string) bool {
+	if u.allCategories || len(category) == 0 {
+		return true
+	}
+	_, ok := u.categories[category]
+	return ok
+}
+
+func (u *aclUser) allowKey(key []byte) bool {
+	for _, p := range u.patterns {
+		if p == "*" || globMatch(p, hack.String(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *aclUser) allowDB(index int) bool {
+	if u.allDBs {
+		return true
+	}
+	_, ok := u.dbs[index]
+	return ok
+}
+
+// acl is the users of the access control lists, the default user is
+// always present.
+type acl struct {
+	sync.RWMutex
+
+	// the path of the ACL file, empty if not configured
+	path string
+
+	users map[string]*aclUser
+}
+
+func newACL(path string) (*acl, error) {
+	a := new(acl)
+	a.path = path
+	a.users = map[string]*aclUser{defaultUser: newDefaultUser()}
+
+	if len(path) > 0 {
+		if err := a.load(); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *acl) user(name string) *aclUser {
+	a.RLock()
+	u := a.users[name]
+	a.RUnlock()
+	return u
+}
+
+// tokenUser returns the enabled user having the password, empty if no or
+// more than one user has it.
+func (a *acl) tokenUser(password string) string {
+	h := hashPassword(password)
+
+	a.RLock()
+	defer a.RUnlock()
+
+	var name string
+	for _, u := range a.users {
+		if _, ok := u.passwords[h]; !ok || !u.enabled {
+			continue
+		} else if len(name) > 0 {
+			return ""
+		}
+		name = u.name
+	}
+	return name
+}
+
+func (a *acl) setUser(name string, rules []string) error {
+	a.Lock()
+	defer a.Unlock()
+
+	var u *aclUser
+	if old, ok := a.users[name]; ok {
+		u = old.clone()
+	} else {
+		u = newACLUser(name)
+	}
+
+	for _, rule := range rules {
+		if err := u.setRule(rule); err != nil {
+			return err
+		}
+	}
+
+	if name == defaultUser && (u.nopass || len(u.passwords) > 0) {
+		return errDefaultPass
+	}
+
+	a.users[name] = u
+	return nil
+}
+
+func (a *acl) delUser(names []string) (int64, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	var n int64
+	for _, name := range names {
+		if name == defaultUser {
+			return n, errDefaultUser
+		}
+		if _, ok := a.users[name]; ok {
+			delete(a.users, name)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (a *acl) userNames() []string {
+	a.RLock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	a.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// list returns the users in the format of the ACL file.
+func (a *acl) list() []string {
+	names := a.userNames()
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		if u := a.user(name); u != nil {
+			lines = append(lines, fmt.Sprintf("user %s %s", name, strings.Join(u.rules(), " ")))
+		}
+	}
+	return lines
+}
+
+// parseACL parses the ACL file, every line is "user name rule...", the empty
+// lines and the lines beginning with # are ignored.
+func parseACL(data []byte) (map[string]*aclUser, error) {
+	users := map[string]*aclUser{defaultUser: newDefaultUser()}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		} else if len(fields) < 2 || fields[0] != "user" {
+			return nil, fmt.Errorf("invalid ACL file line %d", n)
+		}
+
+		name := fields[1]
+		u := users[name]
+		if u == nil || name == defaultUser {
+			// the rules in the file are complete
+			u = newACLUser(name)
+		}
+
+		for _, rule := range fields[2:] {
+			if err := u.setRule(rule); err != nil {
+				return nil, fmt.Errorf("ACL file line %d: %s", n, err.Error())
+			}
+		}
+
+		if name == defaultUser && (u.nopass || len(u.passwords) > 0) {
+			return nil, fmt.Errorf("ACL file line %d: %s", n, errDefaultPass.Error())
+		}
+
+		users[name] = u
+	}
+
+	return users, s.Err()
+}
+
+// load replaces the users with the ACL file, nothing is changed if the
+// file is invalid.
+func (a *acl) load() error {
+	if len(a.path) == 0 {
+		return errNoACLFile
+	}
+
+	data, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	users, err := parseACL(data)
+	if err != nil {
+		return err
+	}
+
+	a.Lock()
+	a.users = users
+	a.Unlock()
+	return nil
+}
+
+func (a *acl) save() error {
+	if len(a.path) == 0 {
+		return errNoACLFile
+	}
+
+	var buf bytes.Buffer
+	for _, line := range a.list() {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	// write a temp file and rename, so the file is never half written
+	tmp := a.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// check checks whether the user of the client can run the command.
+func (a *acl) check(c *client) error {
+	u := a.user(c.user)
+	if u == nil || !u.enabled {
+		// the user is deleted or disabled, must authenticate again
+		return ErrNotAuthenticated
+	}
+
+	category := commandCategory(c.cmd, c.args)
+	if !u.allowCategory(category) {
+		return errNoPermCommand
+	}
+
+	if c.cmd == "select" {
+		if len(c.args) == 1 {
+			if index, err := strconv.Atoi(hack.String(c.args[0])); err == nil && !u.allowDB(index) {
+				return errNoPermDB
+			}
+		}
+		return nil
+	} else if len(category) == 0 {
+		return nil
+	}
+
+	if c.db != nil && !u.allowDB(c.db.Index()) {
+		return errNoPermDB
+	}
+
+	if len(u.patterns) == 1 && u.patterns[0] == "*" {
+		return nil
+	}
+	for _, key := range commandKeys(c.cmd, c.args) {
+		if !u.allowKey(key) {
+			return errNoPermKey
+		}
+	}
+
+	return nil
+}
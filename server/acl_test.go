@@ -0,0 +1,271 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestCommandACLs(t *testing.T) {
+	args := func(ss ...string) [][]byte {
+		ay := make([][]byte, 0, len(ss))
+		for _, s := range ss {
+			ay = append(ay, []byte(s))
+		}
+		return ay
+	}
+
+	tbl := []struct {
+		cmd  string
+		args [][]byte
+		keys [][]byte
+	}{
+		{"get", args("a"), args("a")},
+		{"mset", args("a", "1", "b", "2"), args("a", "b")},
+		{"blpop", args("a", "b", "10"), args("a", "b")},
+		{"bitop", args("and", "d", "a", "b"), args("d", "a", "b")},
+		{"zunionstore", args("d", "2", "a", "b", "weights", "1", "2"), args("d", "a", "b")},
+		{"eval", args("return 1", "1", "a", "b"), args("a")},
+		{"xlsort", args("a", "limit", "0", "1", "store", "d"), args("a", "d")},
+		{"xmigrate", args("127.0.0.1", "6380", "KV", "a", "0", "100"), args("a")},
+		{"ping", nil, nil},
+	}
+
+	for _, v := range tbl {
+		if keys := commandKeys(v.cmd, v.args); !reflect.DeepEqual(keys, v.keys) {
+			t.Fatalf("%s keys %q != %q", v.cmd, keys, v.keys)
+		}
+	}
+
+	if c := commandCategory("xlsort", args("a", "store", "d")); c != aclWrite {
+		t.Fatal(c)
+	} else if c = commandCategory("acl", args("whoami")); c != "" {
+		t.Fatal(c)
+	} else if c = commandCategory("acl", args("list")); c != aclAdmin {
+		t.Fatal(c)
+	}
+}
+
+func TestACLUserRules(t *testing.T) {
+	u := newACLUser("test")
+
+	for _, rule := range []string{"on", ">pass", "+@all", "-@admin", "~a:*", "~b:*", "db=1", "db=0"} {
+		if err := u.setRule(rule); err != nil {
+			t.Fatal(rule, err)
+		}
+	}
+
+	if !u.checkPassword("pass") || u.checkPassword("wrong") {
+		t.Fatal("invalid password")
+	} else if !u.allowCategory(aclWrite) || u.allowCategory(aclAdmin) || !u.allowCategory("") {
+		t.Fatal("invalid categories")
+	} else if !u.allowKey([]byte("a:1")) || u.allowKey([]byte("c:1")) {
+		t.Fatal("invalid keys")
+	} else if !u.allowDB(1) || u.allowDB(2) {
+		t.Fatal("invalid dbs")
+	}
+
+	line := fmt.Sprintf("user test %s", strings.Join(u.rules(), " "))
+	exp := fmt.Sprintf("user test on #%s +@read +@write +@replication +@scripting ~a:* ~b:* db=0 db=1", hashPassword("pass"))
+	if line != exp {
+		t.Fatal(line)
+	}
+
+	users, err := parseACL([]byte("# users\n\n" + line + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(users["test"], u) {
+		t.Fatal("parsed user is not equal")
+	} else if users[defaultUser] == nil {
+		t.Fatal("must have the default user")
+	}
+
+	for _, rule := range []string{"+@unknown", "~", "db=a", "#abc", "unknown"} {
+		if err := u.setRule(rule); err == nil {
+			t.Fatalf("%s must fail", rule)
+		}
+	}
+
+	if _, err := parseACL([]byte("user default on >pass")); err == nil {
+		t.Fatal("the default user can not have password")
+	} else if _, err := parseACL([]byte("users test on")); err == nil {
+		t.Fatal("invalid line must fail")
+	}
+}
+
+func TestACL(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_acl"
+	cfg.Addr = "127.0.0.1:11193"
+	cfg.HttpAddr = "127.0.0.1:11194"
+	cfg.ACLFile = "users.acl"
+
+	os.RemoveAll(cfg.DataDir)
+	os.MkdirAll(cfg.DataDir, 0755)
+
+	aclFile := path.Join(cfg.DataDir, cfg.ACLFile)
+	ioutil.WriteFile(aclFile, []byte(`
+user default on +@read +@write ~* db=0
+user admin on >apass +@all ~* alldbs
+user reader on >rpass +@read ~r:* db=0
+`), 0600)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	connect := func() *goredis.Conn {
+		c, err := goredis.Connect(cfg.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	noPerm := func(c *goredis.Conn, args ...interface{}) {
+		if _, err := c.Do(args[0].(string), args[1:]...); err == nil || !strings.Contains(err.Error(), "NOPERM") {
+			t.Fatalf("%v must have no permission, but %v", args, err)
+		}
+	}
+
+	c := connect()
+	defer c.Close()
+
+	if s, _ := goredis.String(c.Do("acl", "whoami")); s != defaultUser {
+		t.Fatal(s)
+	} else if _, err = c.Do("set", "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	noPerm(c, "select", "1")
+	noPerm(c, "acl", "list")
+
+	if _, err = c.Do("auth", "admin", "wrong"); err == nil {
+		t.Fatal("must fail")
+	} else if _, err = c.Do("auth", "admin", "apass"); err != nil {
+		t.Fatal(err)
+	} else if s, _ := goredis.String(c.Do("acl", "whoami")); s != "admin" {
+		t.Fatal(s)
+	} else if _, err = c.Do("select", "1"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("acl", "setuser", "writer", "on", ">wpass", "+@write", "~w:*", "db=0"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("acl", "setuser", "scripter", "on", ">spass", "+@scripting", "+@read", "~s:*", "alldbs"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("acl", "setuser", "writer", "+@unknown"); err == nil {
+		t.Fatal("must fail")
+	} else if _, err = c.Do("acl", "setuser", "default", ">pass"); err == nil {
+		t.Fatal("must fail")
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("acl", "getuser", "writer")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 10 {
+		t.Fatal(v)
+	} else if s, _ := goredis.String(v[5], nil); s != "+@write" {
+		t.Fatal(s)
+	}
+
+	if v, err := goredis.Strings(c.Do("acl", "users")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"admin", "default", "reader", "scripter", "writer"}) {
+		t.Fatal(v)
+	}
+
+	if _, err = c.Do("acl", "save"); err != nil {
+		t.Fatal(err)
+	} else if data, _ := ioutil.ReadFile(aclFile); !strings.Contains(string(data), "user writer on #") {
+		t.Fatal(string(data))
+	}
+
+	// writer
+	c2 := connect()
+	defer c2.Close()
+
+	if _, err = c2.Do("auth", "writer", "wpass"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c2.Do("set", "w:1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	noPerm(c2, "set", "r:1", "1")
+	noPerm(c2, "mset", "w:2", "1", "r:1", "1")
+	noPerm(c2, "get", "w:1")
+	noPerm(c2, "select", "1")
+
+	// reader
+	c3 := connect()
+	defer c3.Close()
+
+	if _, err = c3.Do("auth", "reader", "rpass"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c3.Do("get", "r:1"); err != nil {
+		t.Fatal(err)
+	}
+	noPerm(c3, "get", "w:1")
+	noPerm(c3, "set", "r:1", "1")
+	noPerm(c3, "eval", "return 1", "0")
+
+	// the commands called by the scripts are checked as the caller
+	c4 := connect()
+	defer c4.Close()
+
+	if _, err = c4.Do("auth", "scripter", "spass"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c4.Do("eval", "return ledis.call('get', KEYS[1])", "1", "s:1"); err != nil {
+		t.Fatal(err)
+	}
+	noPerm(c4, "eval", "return ledis.call('get', KEYS[1])", "1", "r:1")
+	noPerm(c4, "eval", "return ledis.call('get', 'r:1')", "0")
+	noPerm(c4, "eval", "return ledis.call('set', 's:1', '1')", "0")
+
+	// the clients of the deleted users must authenticate again
+	if n, err := goredis.Int64(c.Do("acl", "deluser", "writer", "nobody")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	} else if _, err = c.Do("acl", "deluser", "default"); err == nil {
+		t.Fatal("must fail")
+	} else if _, err = c2.Do("set", "w:1", "1"); err == nil || err.Error() != ErrNotAuthenticated.Error() {
+		t.Fatal(err)
+	} else if _, err = c2.Do("auth", "writer", "wpass"); err == nil {
+		t.Fatal("must fail")
+	}
+
+	// load the saved users
+	if _, err = c.Do("acl", "load"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c2.Do("auth", "writer", "wpass"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the HTTP clients are the default user
+	for _, v := range []struct {
+		path   string
+		noPerm bool
+	}{
+		{"/GET/a", false},
+		{"/1/GET/a", true},
+		{"/ACL/LIST", true},
+	} {
+		r, err := http.Get(fmt.Sprintf("http://%s%s", cfg.HttpAddr, v.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
+		if strings.Contains(string(b), "NOPERM") != v.noPerm {
+			t.Fatal(v.path, string(b))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 	"sync"
 
 	"crypto/tls"
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/goredis"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/ledis"
@@ -19,8 +22,11 @@ import (
 type App struct {
 	cfg *config.Config
 
-	listener     net.Listener
-	httpListener net.Listener
+	listener        net.Listener
+	httpListener    net.Listener
+	metricsListener net.Listener
+
+	memcacheListener net.Listener
 
 	ldb *ledis.Ledis
 
@@ -30,6 +36,16 @@ type App struct {
 
 	access *accessLog
 
+	acl *acl
+
+	slowlog *slowlog
+
+	cmdStats commandStats
+
+	interceptors []Interceptor
+
+	monitors *monitors
+
 	//for slave replication
 	m *master
 
@@ -49,6 +65,17 @@ type App struct {
 	rcm sync.Mutex
 	rcs map[*respClient]struct{}
 
+	mcm sync.Mutex
+	mcs map[*memcacheClient]struct{}
+
+	clientID sync2.AtomicInt64
+
+	pause *clientPause
+
+	pubsub *pubsub
+
+	notifier *keyspaceNotifier
+
 	migrateM          sync.Mutex
 	migrateClients    map[string]*goredis.Client
 	migrateKeyLockers map[string]*migrateKeyLocker
@@ -101,6 +128,17 @@ func NewApp(cfg *config.Config) (*App, error) {
 	app.slaveSyncAck = make(chan uint64)
 
 	app.rcs = make(map[*respClient]struct{})
+	app.mcs = make(map[*memcacheClient]struct{})
+
+	app.pubsub = newPubSub()
+
+	app.pause = newClientPause(app)
+
+	app.slowlog = newSlowlog(app)
+
+	app.cmdStats = newCommandStats()
+
+	app.monitors = newMonitors()
 
 	app.migrateClients = make(map[string]*goredis.Client)
 	app.newMigrateKeyLockers()
@@ -111,6 +149,10 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
+	if app.notifier, err = newKeyspaceNotifier(app); err != nil {
+		return nil, err
+	}
+
 	var tlsCfg *tls.Config
 	if cfg.TLS.Enabled {
 		tlsCfg, err = tlsConfig(&cfg.TLS)
@@ -150,18 +192,26 @@ func NewApp(cfg *config.Config) (*App, error) {
 		}
 	}
 
-	if len(cfg.AccessLog) > 0 {
-		if path.Dir(cfg.AccessLog) == "." {
-			app.access, err = newAcessLog(path.Join(cfg.DataDir, cfg.AccessLog))
-		} else {
-			app.access, err = newAcessLog(cfg.AccessLog)
+	if len(cfg.MetricsAddr) > 0 {
+		if app.metricsListener, err = listen(netType(cfg.MetricsAddr), cfg.MetricsAddr, tlsCfg); err != nil {
+			return nil, err
 		}
+	}
 
-		if err != nil {
+	if len(cfg.MemcacheAddr) > 0 {
+		if app.memcacheListener, err = listen(netType(cfg.MemcacheAddr), cfg.MemcacheAddr, nil); err != nil {
 			return nil, err
 		}
 	}
 
+	if app.access, err = newAcessLog(app.dataDirPath(cfg.AccessLog)); err != nil {
+		return nil, err
+	}
+
+	if app.acl, err = newACL(app.dataDirPath(cfg.ACLFile)); err != nil {
+		return nil, err
+	}
+
 	if app.snap, err = newSnapshotStore(cfg); err != nil {
 		return nil, err
 	}
@@ -175,12 +225,23 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
+	if app.memcacheListener != nil {
+		if _, err = app.ldb.Select(cfg.MemcacheDB); err != nil {
+			return nil, fmt.Errorf("invalid memcache_db: %v", err)
+		}
+	}
+
 	app.m = newMaster(app)
 
 	app.openScript()
 
 	app.ldb.AddNewLogEventHandler(app.publishNewLog)
 
+	// the writes do not make the key events if nothing is published
+	if app.notifier.enabled() {
+		app.ldb.AddKeyEventHandler(app.notifier.handleKeyEvent)
+	}
+
 	return app, nil
 }
 
@@ -207,8 +268,20 @@ func (app *App) Close() {
 		app.httpListener.Close()
 	}
 
+	if app.metricsListener != nil {
+		app.metricsListener.Close()
+	}
+
+	if app.memcacheListener != nil {
+		app.memcacheListener.Close()
+	}
+
+	app.pause.unpause()
+
 	app.closeAllRespClients()
 
+	app.closeAllMemcacheClients()
+
 	//wait all connection closed
 	app.connWait.Wait()
 
@@ -220,9 +293,7 @@ func (app *App) Close() {
 
 	app.snap.Close()
 
-	if app.access != nil {
-		app.access.Close()
-	}
+	app.access.Close()
 
 	app.ldb.Close()
 }
@@ -234,6 +305,10 @@ func (app *App) Run() {
 
 	go app.httpServe()
 
+	go app.metricsServe()
+
+	go app.memcacheServe()
+
 	for {
 		select {
 		case <-app.quit:
@@ -260,10 +335,105 @@ func (app *App) httpServe() {
 		newClientHTTP(app, w, r)
 	})
 
-	svr := http.Server{Handler: mux}
+	mux.HandleFunc("/pipeline", func(w http.ResponseWriter, r *http.Request) {
+		newClientHTTPBatch(app, w, r, false)
+	})
+
+	mux.HandleFunc("/multi", func(w http.ResponseWriter, r *http.Request) {
+		newClientHTTPBatch(app, w, r, true)
+	})
+
+	if app.metricsListener == nil {
+		mux.HandleFunc("/metrics", app.serveMetrics)
+	}
+
+	// the escaped REST keys may have "//", "." or "..", the REST requests are
+	// served before the mux, which cleans the path and redirects them
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.EscapedPath(), restPrefix) {
+			newClientREST(app, w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}
+
+	svr := http.Server{Handler: http.HandlerFunc(handler)}
 	svr.Serve(app.httpListener)
 }
 
+func (app *App) authEnabled() bool {
+	return len(app.cfg.GetAuthPassword()) > 0 || app.cfg.AuthMethod != nil
+}
+
+// dataDirPath returns the path of the file like the access log and the ACL
+// file, the relative name is in the data dir.
+func (app *App) dataDirPath(name string) string {
+	if len(name) > 0 && path.Dir(name) == "." {
+		return path.Join(app.cfg.DataDir, name)
+	}
+	return name
+}
+
+var errReadonlySlave = errors.New("readonly can not be changed on a slave")
+
+// setConfig changes the config parameter at runtime and applies it.
+func (app *App) setConfig(name string, value string) error {
+	name = strings.ToLower(name)
+
+	switch name {
+	case "readonly":
+		app.m.Lock()
+		isSlave := len(app.cfg.SlaveOf) > 0
+		app.m.Unlock()
+
+		if isSlave {
+			return errReadonlySlave
+		}
+	case "access-log":
+		// the old log is kept if failed
+		if err := app.access.open(app.dataDirPath(value)); err != nil {
+			return err
+		}
+	}
+
+	return app.cfg.Set(name, value)
+}
+
+// ReloadConfig reloads the config file and applies the changed parameters
+// which can be changed at runtime, others are ignored.
+func (app *App) ReloadConfig() error {
+	if len(app.cfg.FileName) == 0 {
+		return config.ErrNoConfigFile
+	}
+
+	cfg, err := config.NewConfigWithFile(app.cfg.FileName)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range config.SettableParams {
+		value := cfg.Get(name)[0].Value
+		if app.cfg.Get(name)[0].Value == value {
+			continue
+		}
+
+		if err = app.setConfig(name, value); err != nil {
+			log.Errorf("reload config %s error %s", name, err.Error())
+		} else {
+			log.Infof("reload config %s", name)
+		}
+	}
+
+	return nil
+}
+
+// resetStat resets the statistics like CONFIG RESETSTAT.
+func (app *App) resetStat() {
+	app.cmdStats.reset()
+	app.ldb.StoreStat().Reset()
+	app.info.resetStat()
+}
+
 func (app *App) Ledis() *ledis.Ledis {
 	return app.ldb
 }
@@ -12,6 +12,7 @@ import (
 var testAppOnce sync.Once
 var testAppAuthOnce sync.Once
 var testApp *App
+var testAppAuth *App
 
 var testLedisClient *goredis.Client
 var testLedisClientAuth *goredis.Client
@@ -42,13 +43,13 @@ func startTestAppAuth(password string) {
 		os.RemoveAll(cfg.DataDir)
 
 		var err error
-		testApp, err = NewApp(cfg)
+		testAppAuth, err = NewApp(cfg)
 		if err != nil {
 			println(err.Error())
 			panic(err)
 		}
 
-		go testApp.Run()
+		go testAppAuth.Run()
 	}
 
 	testAppAuthOnce.Do(f)
@@ -2,9 +2,10 @@ package server
 
 import (
 	"bytes"
-	//	"fmt"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/siddontang/go/sync2"
@@ -21,6 +22,9 @@ type responseWriter interface {
 	writeFVPairArray([]ledis.FVPair)
 	writeScorePairArray([]ledis.ScorePair, bool)
 	writeBulkFrom(int64, io.Reader)
+	writeDouble(float64)
+	writeBool(bool)
+	writePush([]interface{})
 	flush()
 }
 
@@ -41,6 +45,9 @@ type client struct {
 
 	isAuthed bool
 
+	// the ACL user, changed by AUTH
+	user string
+
 	resp responseWriter
 
 	syncBuf bytes.Buffer
@@ -52,6 +59,47 @@ type client struct {
 	buf bytes.Buffer
 
 	slaveListeningAddr string
+
+	// isSlave is set by REPLCONF with slaveListeningAddr, it is read by the
+	// publishers to check the output buffer limit of the client class
+	isSlave sync2.AtomicBool
+
+	// for transaction, multi is not nil after MULTI,
+	// and tx is not nil when executing the queued commands in EXEC
+	multi   *multiState
+	tx      *ledis.Tx
+	watcher *ledis.Watcher
+
+	// the response is written by the client and the monitor goroutine,
+	// so writing the response must hold respLock
+	respLock sync.Mutex
+
+	// for pub/sub, the messages are published to the client in other
+	// goroutines, subLock guards the subscriptions changed by the client
+	// and checked by the publishers
+	subLock     sync.Mutex
+	subChannels map[string]struct{}
+	subPatterns map[string]struct{}
+
+	// out is the output buffer of the RESP connection, the published
+	// messages are appended to it, nil for other clients
+	out *outputBuffer
+
+	// the RESP protocol version, 2 or 3, switched by HELLO
+	proto int
+
+	id         int64
+	createTime time.Time
+
+	// killed by CLIENT KILL itself, the connection is closed after replying
+	killed bool
+
+	// the processed commands are sent to the client after MONITOR
+	monitoring bool
+
+	// the statistics are read by CLIENT LIST in other goroutines
+	statLock sync.Mutex
+	stat     clientStat
 }
 
 func newClient(app *App) *client {
@@ -60,39 +108,57 @@ func newClient(app *App) *client {
 	c.app = app
 	c.ldb = app.ldb
 	c.isAuthed = false
+	c.user = defaultUser
+	c.proto = 2
 	c.db, _ = app.ldb.Select(0) //use default db
 
+	c.id = app.clientID.Add(1)
+	c.createTime = time.Now()
+	c.stat.lastTime = c.createTime
+
 	return c
 }
 
 func (c *client) close() {
+	c.unwatch()
+	c.unsubscribeAll()
 
+	if c.monitoring {
+		c.app.monitors.remove(c)
+	}
 }
 
 func (c *client) authEnabled() bool {
-	return len(c.app.cfg.AuthPassword) > 0 || c.app.cfg.AuthMethod != nil
+	return c.app.authEnabled()
 }
 
-func (c *client) perform() {
-	var err error
+// checkACL checks whether the ACL user can run the command, AUTH and HELLO
+// are always allowed to authenticate again.
+func (c *client) checkACL() error {
+	if c.cmd == "auth" || c.cmd == "hello" {
+		return nil
+	}
+	return c.app.acl.check(c)
+}
 
+func (c *client) perform() {
 	start := time.Now()
 
 	c.cmd = strings.ToLower(c.cmd)
 
-	if len(c.cmd) == 0 {
-		err = ErrEmptyCommand
-	} else if exeCmd, ok := regCmds[c.cmd]; !ok {
-		err = ErrNotFound
-	} else if c.authEnabled() && !c.isAuthed && c.cmd != "auth" {
-		err = ErrNotAuthenticated
-	} else {
-		err = exeCmd(c)
+	err := c.intercept(0)
+
+	if err != nil && c.multi != nil && !isTxCommand(c.cmd) {
+		// same as Redis, EXEC fails if any command can not be queued
+		c.multi.aborted = true
 	}
 
-	if c.app.access != nil {
-		duration := time.Since(start)
+	duration := time.Since(start)
+
+	c.app.slowlog.log(c, duration)
+	c.app.cmdStats.record(c.cmd, duration, err)
 
+	if c.app.access.enabled() {
 		fullCmd := c.catGenericCommand()
 		cost := duration.Nanoseconds() / 1000000
 
@@ -108,9 +174,41 @@ func (c *client) perform() {
 		c.resp.writeError(err)
 	}
 	c.resp.flush()
+
+	c.updateStat()
 	return
 }
 
+// execute checks and executes the command, or queues it in MULTI.
+func (c *client) execute() error {
+	exeCmd, ok := regCmds[c.cmd]
+
+	if len(c.cmd) == 0 {
+		return ErrEmptyCommand
+	} else if !ok {
+		return ErrNotFound
+	} else if !exeCmd.checkArity(c.args) {
+		return ErrCmdParams
+	} else if c.authEnabled() && !c.isAuthed && c.cmd != "auth" && c.cmd != "hello" {
+		return ErrNotAuthenticated
+	} else if err := c.checkACL(); err != nil {
+		return err
+	} else if c.subscribed() && c.proto != 3 && !isSubscribeCommand(c.cmd) && c.cmd != "ping" {
+		// RESP3 can use any command in the subscribed mode, the published
+		// messages are push messages
+		return errSubscribedMode
+	} else if exeCmd.flags&cmdWrite != 0 && c.app.cfg.GetReadonly() {
+		// the slaves are readonly too
+		return ledis.ErrWriteInROnly
+	} else if c.multi != nil && !isTxCommand(c.cmd) {
+		return c.queueCommand(exeCmd.f)
+	}
+
+	c.app.pause.wait(c.cmd)
+	c.app.monitors.feed(c)
+	return exeCmd.f(c)
+}
+
 func (c *client) catGenericCommand() []byte {
 	buffer := c.buf
 	buffer.Reset()
@@ -125,6 +223,7 @@ func (c *client) catGenericCommand() []byte {
 	return buffer.Bytes()
 }
 
+// writeValue writes the value of the array reply or the script result.
 func writeValue(w responseWriter, value interface{}) {
 	switch v := value.(type) {
 	case []interface{}:
@@ -139,7 +238,17 @@ func writeValue(w responseWriter, value interface{}) {
 		w.writeBulk(nil)
 	case int64:
 		w.writeInteger(v)
+	case float64:
+		w.writeDouble(v)
+	case bool:
+		w.writeBool(v)
+	case error:
+		w.writeError(v)
+	case []ledis.FVPair:
+		w.writeFVPairArray(v)
+	case scorePairArray:
+		w.writeScorePairArray(v.lst, v.withScores)
 	default:
-		panic("invalid value type")
+		panic(fmt.Sprintf("invalid value type %T %v", value, v))
 	}
 }
@@ -20,15 +20,6 @@ var allowedContentTypes = map[string]struct{}{
 	"bson":    {},
 	"msgpack": {},
 }
-var httpUnsupportedCommands = map[string]struct{}{
-	"slaveof":  {},
-	"fullsync": {},
-	"sync":     {},
-	"quit":     {},
-	"begin":    {},
-	"commit":   {},
-	"rollback": {},
-}
 
 type httpClient struct {
 	*client
@@ -38,6 +29,10 @@ type httpWriter struct {
 	contentType string
 	cmd         string
 	w           http.ResponseWriter
+
+	// batch collects the results of the commands in a pipeline, which are
+	// written together later
+	batch *[]interface{}
 }
 
 func newClientHTTP(app *App, w http.ResponseWriter, r *http.Request) {
@@ -54,10 +49,50 @@ func newClientHTTP(app *App, w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(err.Error()))
 		return
 	}
+
+	if err = c.authRequest(r); err == nil && c.authEnabled() && !c.isAuthed {
+		err = ErrNotAuthenticated
+	}
+	if err != nil {
+		c.client.close()
+		w.Header().Set("WWW-Authenticate", `Basic realm="ledis"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
 	c.perform()
 	c.client.close()
 }
 
+// authRequest authenticates the client with the credentials of the request
+// like AUTH, HTTP Basic is the user and the password, and a bearer token is
+// the password of the default user or the only ACL user having it.
+func (c *httpClient) authRequest(r *http.Request) error {
+	if user, password, ok := r.BasicAuth(); ok {
+		if len(user) == 0 {
+			user = defaultUser
+		}
+		if !c.auth(user, password) {
+			return ErrAuthenticationFailure
+		}
+		return nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return nil
+	}
+
+	token := strings.TrimSpace(auth[7:])
+	if c.auth(defaultUser, token) {
+		return nil
+	} else if user := c.app.acl.tokenUser(token); len(user) > 0 && c.auth(user, token) {
+		return nil
+	}
+	return ErrAuthenticationFailure
+}
+
 func (c *httpClient) addr(r *http.Request) string {
 	return r.RemoteAddr
 }
@@ -84,14 +119,15 @@ func (c *httpClient) makeRequest(app *App, r *http.Request, w http.ResponseWrite
 	}
 
 	c.cmd = strings.ToLower(cmd)
-	if _, ok := httpUnsupportedCommands[c.cmd]; ok {
+	// the HTTP clients have no connection state
+	if exeCmd, ok := regCmds[c.cmd]; ok && exeCmd.flags&cmdNoScript != 0 {
 		return fmt.Errorf("unsupported command: '%s'", cmd)
 	}
 
 	c.args = args
 
 	c.remoteAddr = c.addr(r)
-	c.resp = &httpWriter{contentType, cmd, w}
+	c.resp = &httpWriter{contentType: contentType, cmd: cmd, w: w}
 	return nil
 }
 
@@ -125,6 +161,11 @@ func (w *httpWriter) genericWrite(result interface{}) {
 	m := map[string]interface{}{
 		w.cmd: result,
 	}
+	if w.batch != nil {
+		*w.batch = append(*w.batch, m)
+		return
+	}
+
 	switch w.contentType {
 	case "json":
 		writeJSON(&m, w.w)
@@ -195,7 +236,7 @@ func (w *httpWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool)
 		arr = make([]string, 2*len(lst))
 		for i, data := range lst {
 			arr[2*i] = hack.String(data.Member)
-			arr[2*i+1] = strconv.FormatInt(data.Score, 10)
+			arr[2*i+1] = hack.String(zformatScore(data.Score))
 		}
 	} else {
 		arr = make([]string, len(lst))
@@ -210,6 +251,18 @@ func (w *httpWriter) writeBulkFrom(n int64, rb io.Reader) {
 	w.writeError(fmt.Errorf("unsupport"))
 }
 
+func (w *httpWriter) writeDouble(f float64) {
+	w.genericWrite(hack.String(zformatScore(f)))
+}
+
+func (w *httpWriter) writeBool(b bool) {
+	w.genericWrite(b)
+}
+
+func (w *httpWriter) writePush(lst []interface{}) {
+	w.writeArray(lst)
+}
+
 func (w *httpWriter) flush() {
 
 }
@@ -0,0 +1,314 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/go/bson"
+	"github.com/ugorji/go/codec"
+)
+
+var (
+	errBatchBody     = errors.New("the body must be an array of commands, or a document with the commands field for bson")
+	errBatchArgs     = errors.New("invalid command, it must be a non-empty array of strings or numbers")
+	errBatchTooLarge = errors.New("the body is too large")
+)
+
+// batchCommand is a command in the body of /pipeline and /multi.
+type batchCommand struct {
+	name string
+	args [][]byte
+
+	// err is the error of parsing, the command is not performed
+	err error
+}
+
+// newClientHTTPBatch performs the commands in the body of POST /pipeline one
+// by one, or atomically in a transaction for /multi, and replies the array
+// of the results like httpWriter, e.g. {"pipeline":[{"SET":[true,"OK"]}]}.
+func newClientHTTPBatch(app *App, w http.ResponseWriter, r *http.Request, atomic bool) {
+	app.connWait.Add(1)
+	defer app.connWait.Done()
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	reqType, respType, err := batchContentTypes(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := new(httpClient)
+	c.client = newClient(app)
+	defer c.client.close()
+
+	c.remoteAddr = c.addr(r)
+
+	if err = c.authRequest(r); err == nil && c.authEnabled() && !c.isAuthed {
+		err = ErrNotAuthenticated
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ledis"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// the body is read after authenticated, and no more than the limit
+	cmds, code, err := readBatchCommands(r, reqType, app.cfg.HttpMaxBodySize)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	if db := r.FormValue("db"); len(db) > 0 {
+		index, err := strconv.Atoi(db)
+		if err == nil {
+			c.db, err = app.ldb.Select(index)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var results []interface{}
+	name := "pipeline"
+	if atomic {
+		results = c.performMulti(cmds, respType)
+		name = "multi"
+	} else {
+		results = c.performPipeline(cmds, respType)
+	}
+
+	resp := &httpWriter{contentType: respType, cmd: name, w: w}
+	resp.genericWrite(results)
+}
+
+// batchContentTypes returns the type of the body by Content-Type or the type
+// query, and the type of the response by the type query or same as the body.
+func batchContentTypes(r *http.Request) (reqType string, respType string, err error) {
+	respType = strings.ToLower(r.URL.Query().Get("type"))
+
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mt {
+	case "application/json":
+		reqType = "json"
+	case "application/bson":
+		reqType = "bson"
+	case "application/msgpack", "application/x-msgpack":
+		reqType = "msgpack"
+	default:
+		reqType = respType
+	}
+
+	if len(reqType) == 0 {
+		reqType = "json"
+	}
+	if len(respType) == 0 {
+		respType = reqType
+	}
+
+	if _, ok := allowedContentTypes[respType]; !ok {
+		return "", "", fmt.Errorf("unsupported content type: '%s', only json, bson, msgpack are supported", respType)
+	} else if _, ok := allowedContentTypes[reqType]; !ok {
+		return "", "", fmt.Errorf("unsupported content type: '%s', only json, bson, msgpack are supported", reqType)
+	}
+	return
+}
+
+// readBatchCommands decodes the commands, an array of the commands in JSON
+// and msgpack, or the commands field of the document in bson, every command
+// is an array of the name and the args. It returns the status code with the
+// error, 413 if the body is larger than maxSize.
+func readBatchCommands(r *http.Request, reqType string, maxSize int) ([]batchCommand, int, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	} else if len(body) > maxSize {
+		return nil, http.StatusRequestEntityTooLarge, errBatchTooLarge
+	}
+
+	var v interface{}
+	switch reqType {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		err = dec.Decode(&v)
+	case "msgpack":
+		var mh codec.MsgpackHandle
+		err = codec.NewDecoderBytes(body, &mh).Decode(&v)
+	case "bson":
+		var doc bson.M
+		if err = bson.Unmarshal(body, &doc); err == nil {
+			v = doc["commands"]
+		}
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	lst, ok := v.([]interface{})
+	if !ok {
+		return nil, http.StatusBadRequest, errBatchBody
+	}
+
+	cmds := make([]batchCommand, len(lst))
+	for i, v := range lst {
+		cmds[i] = parseBatchCommand(v)
+	}
+	return cmds, http.StatusOK, nil
+}
+
+func parseBatchCommand(v interface{}) batchCommand {
+	var cmd batchCommand
+
+	lst, ok := v.([]interface{})
+	if !ok || len(lst) == 0 {
+		cmd.err = errBatchArgs
+		return cmd
+	}
+
+	args := make([][]byte, len(lst))
+	for i, arg := range lst {
+		switch arg := arg.(type) {
+		case string:
+			args[i] = []byte(arg)
+		case []byte:
+			args[i] = arg
+		case json.Number:
+			args[i] = []byte(arg)
+		case int:
+			args[i] = strconv.AppendInt(nil, int64(arg), 10)
+		case int64:
+			args[i] = strconv.AppendInt(nil, arg, 10)
+		case uint64:
+			args[i] = strconv.AppendUint(nil, arg, 10)
+		case float64:
+			args[i] = strconv.AppendFloat(nil, arg, 'f', -1, 64)
+		default:
+			cmd.err = errBatchArgs
+		}
+	}
+
+	cmd.name = string(args[0])
+	cmd.args = args[1:]
+	return cmd
+}
+
+// checkBatchCommand returns the error if the command can not be performed,
+// the HTTP clients have no connection state.
+func checkBatchCommand(cmd batchCommand) error {
+	if cmd.err != nil {
+		return cmd.err
+	} else if exeCmd, ok := regCmds[strings.ToLower(cmd.name)]; ok && exeCmd.flags&cmdNoScript != 0 {
+		return fmt.Errorf("unsupported command: '%s'", cmd.name)
+	}
+	return nil
+}
+
+// performPipeline performs the commands in order, the error of one command
+// does not stop others.
+func (c *httpClient) performPipeline(cmds []batchCommand, respType string) []interface{} {
+	results := make([]interface{}, 0, len(cmds))
+
+	for _, cmd := range cmds {
+		w := &httpWriter{contentType: respType, cmd: cmd.name, batch: &results}
+
+		if err := checkBatchCommand(cmd); err != nil {
+			w.writeError(err)
+			continue
+		}
+
+		c.cmd = cmd.name
+		c.args = cmd.args
+		c.resp = w
+
+		c.perform()
+	}
+
+	return results
+}
+
+// performMulti performs the commands in MULTI and EXEC. Same as Redis, no
+// command is performed if any one can not be queued, and the others get
+// the EXECABORT error.
+func (c *httpClient) performMulti(cmds []batchCommand, respType string) []interface{} {
+	rw := new(replyWriter)
+	c.resp = rw
+
+	c.cmd = "multi"
+	c.args = nil
+	c.perform()
+
+	results := make([]interface{}, 0, len(cmds))
+
+	// e.g. MULTI is denied by ACL, the commands must not be performed
+	if err, ok := rw.replies[0].(error); ok {
+		for _, cmd := range cmds {
+			w := &httpWriter{contentType: respType, cmd: cmd.name, batch: &results}
+			w.writeError(err)
+		}
+		return results
+	}
+
+	queueErrs := make([]error, len(cmds))
+	for i, cmd := range cmds {
+		if err := checkBatchCommand(cmd); err != nil {
+			queueErrs[i] = err
+			c.multi.aborted = true
+			continue
+		}
+
+		c.cmd = cmd.name
+		c.args = cmd.args
+		c.perform()
+
+		if err, ok := rw.replies[len(rw.replies)-1].(error); ok {
+			queueErrs[i] = err
+		}
+	}
+
+	c.cmd = "exec"
+	c.args = nil
+	c.perform()
+
+	var execErr error
+	var replies []interface{}
+
+	switch v := rw.replies[len(rw.replies)-1].(type) {
+	case error:
+		execErr = v
+	case []interface{}:
+		replies = v
+	}
+
+	for i, cmd := range cmds {
+		w := &httpWriter{contentType: respType, cmd: cmd.name, batch: &results}
+
+		switch {
+		case queueErrs[i] != nil:
+			w.writeError(queueErrs[i])
+		case execErr != nil:
+			w.writeError(execErr)
+		case len(replies) > 0:
+			writeValue(w, replies[0])
+			replies = replies[1:]
+		default:
+			w.writeBulk(nil)
+		}
+	}
+
+	return results
+}
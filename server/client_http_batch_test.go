@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/go/bson"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/ugorji/go/codec"
+)
+
+func TestHttpPipeline(t *testing.T) {
+	startTestApp()
+
+	post := func(path string, contentType string, body []byte) (int, []byte) {
+		r, err := http.Post(fmt.Sprintf("http://%s%s", testApp.cfg.HttpAddr, path), contentType, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Body.Close()
+
+		b, _ := ioutil.ReadAll(r.Body)
+		return r.StatusCode, b
+	}
+
+	code, b := post("/pipeline?db=1", "application/json",
+		[]byte(`[["SET","pipeline_a",1],["INCR","pipeline_a"],["HSET","pipeline_a","f","v"],["GET","pipeline_a"],["SUBSCRIBE","c"],["UNKNOWN"],[]]`))
+	if code != http.StatusOK {
+		t.Fatal(code, string(b))
+	}
+
+	exp := `{"pipeline":[{"SET":[true,"OK"]},{"INCR":2},{"HSET":1},{"GET":"2"},` +
+		`{"SUBSCRIBE":[false,"ERR unsupported command: 'SUBSCRIBE'"]},{"UNKNOWN":[false,"ERR command not found"]},` +
+		`{"":[false,"ERR invalid command, it must be a non-empty array of strings or numbers"]}]}`
+	if string(b) != exp {
+		t.Fatal(string(b))
+	}
+
+	// the transaction is discarded if any command can not be queued
+	code, b = post("/multi?db=1", "application/json", []byte(`[["INCR","pipeline_a"],["INCR"]]`))
+	if code != http.StatusOK {
+		t.Fatal(code)
+	}
+
+	exp = `{"multi":[{"INCR":[false,"ERR EXECABORT Transaction discarded because of previous errors."]},` +
+		`{"INCR":[false,"ERR invalid command param"]}]}`
+	if string(b) != exp {
+		t.Fatal(string(b))
+	}
+
+	// the error of one command does not stop others in EXEC
+	var mh codec.MsgpackHandle
+	var buf []byte
+	codec.NewEncoderBytes(&buf, &mh).Encode([][]interface{}{
+		{"INCR", "pipeline_a"},
+		{"LPUSH", "pipeline_a", "x"},
+		{"ZADD", "pipeline_z", 1, []byte("m")},
+		{"ZRANGE", "pipeline_z", 0, -1, "WITHSCORES"},
+	})
+	if code, b = post("/multi?db=1&type=json", "application/msgpack", buf); code != http.StatusOK {
+		t.Fatal(code)
+	}
+
+	exp = `{"multi":[{"INCR":3},{"LPUSH":1},{"ZADD":1},{"ZRANGE":["m","1"]}]}`
+	if string(b) != exp {
+		t.Fatal(string(b))
+	}
+
+	buf, _ = bson.Marshal(bson.M{"commands": []interface{}{
+		[]interface{}{"GET", "pipeline_a"},
+	}})
+	if code, b = post("/pipeline?db=1", "application/bson", buf); code != http.StatusOK {
+		t.Fatal(code)
+	}
+
+	var v struct {
+		Pipeline []map[string]string `bson:"pipeline"`
+	}
+	if err := bson.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	} else if len(v.Pipeline) != 1 || v.Pipeline[0]["GET"] != "3" {
+		t.Fatal(v)
+	}
+
+	if code, _ = post("/pipeline", "application/json", []byte(`{"a":1}`)); code != http.StatusBadRequest {
+		t.Fatal(code)
+	}
+
+	r, err := http.Get(fmt.Sprintf("http://%s/multi", testApp.cfg.HttpAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal(r.StatusCode)
+	}
+
+	var m map[string]interface{}
+	if _, b = post("/pipeline", "", []byte(`[["PING"]]`)); json.Unmarshal(b, &m) != nil || m["pipeline"] == nil {
+		t.Fatal(string(b))
+	}
+}
+
+func TestHttpPipelineBodyLimit(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_http_pipeline_limit"
+	cfg.Addr = "127.0.0.1:11200"
+	cfg.HttpAddr = "127.0.0.1:11201"
+	cfg.AuthPassword = "password"
+	cfg.HttpMaxBodySize = 64
+
+	os.RemoveAll(cfg.DataDir)
+
+	s, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Run()
+	defer s.Close()
+
+	post := func(body []byte, auth bool) int {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("http://%s/pipeline", cfg.HttpAddr), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if auth {
+			req.Header.Set("Authorization", "Bearer password")
+		}
+
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		return r.StatusCode
+	}
+
+	large := []byte(`[["SET","a","` + strings.Repeat("x", 64) + `"]]`)
+	if code := post(large, false); code != http.StatusUnauthorized {
+		t.Fatal(code)
+	} else if code := post(large, true); code != http.StatusRequestEntityTooLarge {
+		t.Fatal(code)
+	} else if code := post([]byte(`[["PING"]]`), true); code != http.StatusOK {
+		t.Fatal(code)
+	}
+}
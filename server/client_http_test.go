@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/siddontang/goredis"
 )
 
 func TestHttp(t *testing.T) {
@@ -38,3 +41,68 @@ func TestHttp(t *testing.T) {
 	}
 
 }
+
+func TestHttpAuth(t *testing.T) {
+	startTestAppAuth("password")
+
+	c, err := goredis.Connect("127.0.0.1:20000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.Do("auth", "password"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("acl", "setuser", "http_reader", "on", ">reader", "+@read", "~*", "alldbs"); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Do("acl", "deluser", "http_reader")
+
+	do := func(path string, setAuth func(r *http.Request)) (int, string) {
+		r, err := http.NewRequest("GET", "http://127.0.0.1:20001"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setAuth(r)
+
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(b)
+	}
+
+	basic := func(user, password string) func(r *http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, password) }
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	tbl := []struct {
+		path    string
+		setAuth func(r *http.Request)
+		status  int
+		body    string
+	}{
+		{"/PING", func(r *http.Request) {}, http.StatusUnauthorized, ErrNotAuthenticated.Error()},
+		{"/PING", basic("", "wrong"), http.StatusUnauthorized, ErrAuthenticationFailure.Error()},
+		{"/PING", bearer("wrong"), http.StatusUnauthorized, ErrAuthenticationFailure.Error()},
+		{"/SET/http_auth/1", basic("", "password"), http.StatusOK, `{"SET":[true,"OK"]}`},
+		{"/GET/http_auth", basic("default", "password"), http.StatusOK, `{"GET":"1"}`},
+		{"/GET/http_auth", bearer("password"), http.StatusOK, `{"GET":"1"}`},
+		{"/GET/http_auth", basic("http_reader", "reader"), http.StatusOK, `{"GET":"1"}`},
+		{"/GET/http_auth", bearer("reader"), http.StatusOK, `{"GET":"1"}`},
+		{"/SET/http_auth/2", bearer("reader"), http.StatusOK, errNoPermCommand.Error()},
+		{"/GET/http_auth", basic("http_reader", "password"), http.StatusUnauthorized, ErrAuthenticationFailure.Error()},
+	}
+
+	for _, v := range tbl {
+		status, body := do(v.path, v.setAuth)
+		if status != v.status || !strings.Contains(body, v.body) {
+			t.Fatalf("%s: %d %s", v.path, status, body)
+		}
+	}
+}
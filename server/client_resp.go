@@ -2,6 +2,7 @@ package server
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"runtime"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,30 +18,78 @@ import (
 	"github.com/siddontang/go/log"
 	"github.com/siddontang/go/num"
 	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
 var errReadRequest = errors.New("invalid request protocol")
 var errClientQuit = errors.New("remote client quit")
+var errMaxClients = errors.New("ERR max number of clients reached")
+var errOutputBufferLimit = errors.New("output buffer limit reached")
 
 type respClient struct {
 	*client
 
 	conn net.Conn
 
+	br         *bufio.Reader
 	respReader *goredis.RespReader
 
+	rw *respWriter
+
 	activeQuit bool
 }
 
 type respWriter struct {
 	buff *bufio.Writer
+
+	ob *outputBuffer
+}
+
+// the max capacity of the sent buffer reused for the next replies
+const outputBufferReuseSize = 1024 * 1024
+
+// outputBuffer keeps the replies not sent to the client, and a goroutine
+// sends them, so writing the published messages never blocks on the slow
+// clients. The client is closed if the buffer exceeds the limit of its class.
+type outputBuffer struct {
+	c    *respClient
+	conn net.Conn
+
+	m    sync.Mutex
+	cond *sync.Cond
+
+	buf     []byte
+	sending bool
+	closed  bool
+	err     error
+
+	// the time the buffer exceeded the soft limit, zero if not exceeded
+	softTime time.Time
+
+	// the RESP protocol version of the client to encode the pushes
+	proto int
+
+	// partial is set when the client has written a part of its reply and
+	// the rest is still in its bufio.Writer, the messages published in the
+	// meantime are kept in pending and appended after the reply
+	partial bool
+	pending []byte
 }
 
-func (app *App) addRespClient(c *respClient) {
+// addRespClient adds the client unless there are maxClients clients already,
+// the number is checked with the same lock, so the concurrent accepts can not
+// exceed the limit.
+func (app *App) addRespClient(c *respClient, maxClients int) bool {
 	app.rcm.Lock()
+	defer app.rcm.Unlock()
+
+	if maxClients > 0 && len(app.rcs) >= maxClients {
+		return false
+	}
+
 	app.rcs[c] = struct{}{}
-	app.rcm.Unlock()
+	return true
 }
 
 func (app *App) delRespClient(c *respClient) {
@@ -78,15 +128,26 @@ func newClientRESP(conn net.Conn, app *App) {
 		tcpConn.SetWriteBuffer(app.cfg.ConnWriteBufferSize)
 	}
 
-	br := bufio.NewReaderSize(conn, app.cfg.ConnReadBufferSize)
-	c.respReader = goredis.NewRespReader(br)
+	c.br = bufio.NewReaderSize(conn, app.cfg.ConnReadBufferSize)
+	c.respReader = goredis.NewRespReader(c.br)
 
-	c.resp = newWriterRESP(conn, app.cfg.ConnWriteBufferSize)
+	c.rw = newWriterRESP(c, app.cfg.ConnWriteBufferSize)
+	c.resp = c.rw
+	c.out = c.rw.ob
 	c.remoteAddr = conn.RemoteAddr().String()
 
 	app.connWait.Add(1)
 
-	app.addRespClient(c)
+	if maxClients, _ := app.cfg.GetClientLimits(); !app.addRespClient(c, maxClients) {
+		app.info.Clients.RejectedNum.Add(1)
+
+		c.rw.ob.close()
+		conn.Write([]byte(fmt.Sprintf("-%s\r\n", errMaxClients.Error())))
+		conn.Close()
+
+		app.connWait.Done()
+		return
+	}
 
 	go c.run()
 }
@@ -103,7 +164,8 @@ func (c *respClient) run() {
 
 		c.client.close()
 
-		c.conn.Close()
+		// the replies like +OK of QUIT are sent before closing
+		c.rw.ob.close()
 
 		// if c.tx != nil {
 		// 	c.tx.Rollback()
@@ -127,8 +189,16 @@ func (c *respClient) run() {
 
 	kc := time.Duration(c.app.cfg.ConnKeepaliveInterval) * time.Second
 	for {
-		if kc > 0 {
-			c.conn.SetReadDeadline(time.Now().Add(kc))
+		timeout := kc
+		idle := c.idleTimeout()
+		if idle > 0 && (kc == 0 || idle < kc) {
+			timeout = idle
+		}
+
+		if timeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(timeout))
+		} else {
+			c.conn.SetReadDeadline(time.Time{})
 		}
 
 		c.cmd = ""
@@ -137,15 +207,35 @@ func (c *respClient) run() {
 		reqData, err := c.respReader.ParseRequest()
 		if err == nil {
 			err = c.handleRequest(reqData)
+		} else if e, ok := err.(net.Error); ok && e.Timeout() && idle > 0 && timeout == idle {
+			c.app.info.Clients.TimeoutNum.Add(1)
 		}
 
 		if err != nil {
 			return
 		}
+
+		// wait for the replies to be sent before reading the next request,
+		// the output buffer of a client sending many requests without
+		// reading the replies does not grow without bound
+		c.rw.ob.wait(c.app.cfg.ConnWriteBufferSize)
+	}
+}
+
+// idleTimeout returns the idle timeout of the client, 0 if the client is
+// never closed for idle.
+func (c *respClient) idleTimeout() time.Duration {
+	_, timeout := c.app.cfg.GetClientLimits()
+	if timeout <= 0 || len(c.slaveListeningAddr) > 0 || c.subscribed() || c.monitoring {
+		return 0
 	}
+	return time.Duration(timeout) * time.Second
 }
 
 func (c *respClient) handleRequest(reqData [][]byte) error {
+	c.respLock.Lock()
+	defer c.respLock.Unlock()
+
 	if len(reqData) == 0 {
 		c.cmd = ""
 		c.args = reqData[0:0]
@@ -167,7 +257,6 @@ func (c *respClient) handleRequest(reqData [][]byte) error {
 		c.activeQuit = true
 		c.resp.writeStatus(OK)
 		c.resp.flush()
-		c.conn.Close()
 		return errClientQuit
 	} else if c.cmd == "shutdown" {
 		c.conn.Close()
@@ -184,6 +273,12 @@ func (c *respClient) handleRequest(reqData [][]byte) error {
 
 	c.perform()
 
+	c.updateBufferStat()
+
+	if c.killed {
+		return errClientQuit
+	}
+
 	return nil
 }
 
@@ -217,14 +312,226 @@ func (c *respClient) handleXSelectCmd() error {
 	return nil
 }
 
+// setProto switches the response writer of the RESP protocol version.
+func (c *client) setProto(proto int) error {
+	switch w := c.resp.(type) {
+	case *respWriter:
+		if proto == 3 {
+			c.resp = &resp3Writer{w}
+		}
+	case *resp3Writer:
+		if proto == 2 {
+			c.resp = w.respWriter
+		}
+	default:
+		return fmt.Errorf("protocol %d is not supported in this connection", proto)
+	}
+
+	c.proto = proto
+	if c.out != nil {
+		c.out.setProto(proto)
+	}
+	return nil
+}
+
 //	response writer
 
-func newWriterRESP(conn net.Conn, size int) *respWriter {
+func newWriterRESP(c *respClient, size int) *respWriter {
 	w := new(respWriter)
-	w.buff = bufio.NewWriterSize(conn, size)
+	w.ob = newOutputBuffer(c)
+	w.buff = bufio.NewWriterSize(w.ob, size)
 	return w
 }
 
+func newOutputBuffer(c *respClient) *outputBuffer {
+	b := new(outputBuffer)
+	b.c = c
+	b.conn = c.conn
+	b.cond = sync.NewCond(&b.m)
+
+	go b.run()
+	return b
+}
+
+// outputBufferLimit returns the limit of the client class, it is called
+// when writing the reply or a published message, and the lock of the
+// output buffer is held. For a published message, it is called by the
+// publisher with subLock held, so it only reads the subscriptions and
+// isSlave.
+func (c *respClient) outputBufferLimit() config.OutputBufferLimit {
+	l := c.app.cfg.GetClientOutputBufferLimit()
+	if c.isSlave.Get() {
+		return l.Slave
+	} else if c.subscribed() {
+		return l.PubSub
+	}
+	return l.Normal
+}
+
+// exceedLimit checks the limit with the buffered replies, the lock must be held.
+func (b *outputBuffer) exceedLimit() bool {
+	l := b.c.outputBufferLimit()
+
+	n := int64(len(b.buf) + len(b.pending))
+	if l.HardLimit > 0 && n > l.HardLimit {
+		return true
+	}
+
+	if l.SoftLimit <= 0 || n <= l.SoftLimit {
+		b.softTime = time.Time{}
+		return false
+	} else if b.softTime.IsZero() {
+		b.softTime = time.Now()
+		return false
+	}
+	return time.Since(b.softTime) > time.Duration(l.SoftSeconds)*time.Second
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.err != nil {
+		return 0, b.err
+	} else if b.closed {
+		return 0, errClientQuit
+	}
+
+	b.buf = append(b.buf, p...)
+	b.partial = true
+
+	if err := b.checkLimit(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// checkLimit closes the client if the buffered replies exceed the limit,
+// and wakes up the sender, the lock must be held.
+func (b *outputBuffer) checkLimit() error {
+	if b.exceedLimit() {
+		log.Infof("close client %s, %s %d bytes", b.c.remoteAddr, errOutputBufferLimit.Error(), len(b.buf)+len(b.pending))
+		b.c.app.info.Clients.OutputBufferLimitNum.Add(1)
+
+		b.err = errOutputBufferLimit
+		b.buf = nil
+		b.pending = nil
+		b.conn.Close()
+	}
+
+	b.cond.Broadcast()
+	return b.err
+}
+
+// endReply is called after the client flushes its reply, the messages
+// published while the reply was partially written follow it.
+func (b *outputBuffer) endReply() {
+	b.m.Lock()
+	b.partial = false
+	if len(b.pending) > 0 && b.err == nil {
+		b.buf = append(b.buf, b.pending...)
+		b.pending = nil
+		b.cond.Broadcast()
+	}
+	b.m.Unlock()
+}
+
+// push appends the published message to the buffer, it is called by the
+// publishers and does not wait for the command the client is running.
+func (b *outputBuffer) push(msg []interface{}) {
+	var data bytes.Buffer
+	rw := &respWriter{buff: bufio.NewWriter(&data)}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.err != nil || b.closed {
+		return
+	}
+
+	if b.proto == 3 {
+		(&resp3Writer{rw}).writePush(msg)
+	} else {
+		rw.writePush(msg)
+	}
+	rw.buff.Flush()
+
+	if b.partial {
+		b.pending = append(b.pending, data.Bytes()...)
+	} else {
+		b.buf = append(b.buf, data.Bytes()...)
+	}
+	b.checkLimit()
+}
+
+func (b *outputBuffer) setProto(proto int) {
+	b.m.Lock()
+	b.proto = proto
+	b.m.Unlock()
+}
+
+// run sends the buffered replies until closed, the connection is closed
+// after all the replies are sent.
+func (b *outputBuffer) run() {
+	var data []byte
+
+	b.m.Lock()
+	for {
+		for len(b.buf) == 0 && !b.closed && b.err == nil {
+			b.cond.Wait()
+		}
+
+		if b.err != nil || len(b.buf) == 0 {
+			break
+		}
+
+		data, b.buf = b.buf, data[:0]
+		b.sending = true
+		b.m.Unlock()
+
+		_, err := b.conn.Write(data)
+		if cap(data) > outputBufferReuseSize {
+			// do not keep the memory of a large reply
+			data = nil
+		}
+
+		b.m.Lock()
+		b.sending = false
+		if err != nil && b.err == nil {
+			b.err = err
+		}
+		b.cond.Broadcast()
+	}
+	b.m.Unlock()
+
+	b.conn.Close()
+}
+
+// len returns the bytes not sent.
+func (b *outputBuffer) len() int {
+	b.m.Lock()
+	n := len(b.buf)
+	b.m.Unlock()
+	return n
+}
+
+// wait waits until the bytes not sent are no more than n, or failed.
+func (b *outputBuffer) wait(n int) {
+	b.m.Lock()
+	for len(b.buf) > n && b.err == nil {
+		b.cond.Wait()
+	}
+	b.m.Unlock()
+}
+
+// close closes the connection after the buffered replies are sent.
+func (b *outputBuffer) close() {
+	b.m.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.m.Unlock()
+}
+
 func (w *respWriter) writeError(err error) {
 	w.buff.Write(hack.Slice("-"))
 	if err != nil {
@@ -268,24 +575,7 @@ func (w *respWriter) writeArray(lst []interface{}) {
 		w.buff.Write(Delims)
 
 		for i := 0; i < len(lst); i++ {
-			switch v := lst[i].(type) {
-			case []interface{}:
-				w.writeArray(v)
-			case [][]byte:
-				w.writeSliceArray(v)
-			case []byte:
-				w.writeBulk(v)
-			case nil:
-				w.writeBulk(nil)
-			case int64:
-				w.writeInteger(v)
-			case string:
-				w.writeStatus(v)
-			case error:
-				w.writeError(v)
-			default:
-				panic(fmt.Sprintf("invalid array type %T %v", lst[i], v))
-			}
+			writeValue(w, lst[i])
 		}
 	}
 }
@@ -340,7 +630,7 @@ func (w *respWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool)
 			w.writeBulk(lst[i].Member)
 
 			if withScores {
-				w.writeBulk(num.FormatInt64ToSlice(lst[i].Score))
+				w.writeBulk(zformatScore(lst[i].Score))
 			}
 		}
 	}
@@ -351,10 +641,142 @@ func (w *respWriter) writeBulkFrom(n int64, rb io.Reader) {
 	w.buff.Write(hack.Slice(strconv.FormatInt(n, 10)))
 	w.buff.Write(Delims)
 
-	io.Copy(w.buff, rb)
+	// the data like the snapshot may be large, wait for every part to be
+	// sent instead of buffering all of it
+	buf := make([]byte, w.buff.Size())
+	for {
+		m, err := rb.Read(buf)
+		if m > 0 {
+			w.buff.Write(buf[:m])
+			w.ob.wait(w.buff.Size())
+		}
+		if err != nil {
+			break
+		}
+	}
 	w.buff.Write(Delims)
 }
 
+func (w *respWriter) writeDouble(f float64) {
+	w.writeBulk(zformatScore(f))
+}
+
+func (w *respWriter) writeBool(b bool) {
+	if b {
+		w.writeInteger(1)
+	} else {
+		w.writeInteger(0)
+	}
+}
+
+func (w *respWriter) writePush(lst []interface{}) {
+	w.writeArray(lst)
+}
+
 func (w *respWriter) flush() {
 	w.buff.Flush()
+	if w.ob != nil {
+		w.ob.endReply()
+	}
+}
+
+// resp3Writer writes the response in RESP3, which has the types like map,
+// double, boolean and push, it is used after HELLO 3.
+type resp3Writer struct {
+	*respWriter
+}
+
+func (w *resp3Writer) writeBulk(b []byte) {
+	if b == nil {
+		w.writeNull()
+	} else {
+		w.respWriter.writeBulk(b)
+	}
+}
+
+func (w *resp3Writer) writeNull() {
+	w.buff.WriteByte('_')
+	w.buff.Write(Delims)
+}
+
+func (w *resp3Writer) writeAggregate(prefix byte, n int) {
+	w.buff.WriteByte(prefix)
+	w.buff.Write(hack.Slice(strconv.Itoa(n)))
+	w.buff.Write(Delims)
+}
+
+func (w *resp3Writer) writeArray(lst []interface{}) {
+	if lst == nil {
+		w.writeNull()
+		return
+	}
+
+	w.writeAggregate('*', len(lst))
+	for i := 0; i < len(lst); i++ {
+		writeValue(w, lst[i])
+	}
+}
+
+func (w *resp3Writer) writeSliceArray(lst [][]byte) {
+	if lst == nil {
+		w.writeNull()
+		return
+	}
+
+	w.writeAggregate('*', len(lst))
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i])
+	}
+}
+
+func (w *resp3Writer) writeFVPairArray(lst []ledis.FVPair) {
+	w.writeAggregate('%', len(lst))
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i].Field)
+		w.writeBulk(lst[i].Value)
+	}
+}
+
+// writeScorePairArray writes an array of [member, score] pairs with scores, same as Redis.
+func (w *resp3Writer) writeScorePairArray(lst []ledis.ScorePair, withScores bool) {
+	w.writeAggregate('*', len(lst))
+	for i := 0; i < len(lst); i++ {
+		if withScores {
+			w.writeAggregate('*', 2)
+			w.writeBulk(lst[i].Member)
+			w.writeDouble(lst[i].Score)
+		} else {
+			w.writeBulk(lst[i].Member)
+		}
+	}
+}
+
+func (w *resp3Writer) writeDouble(f float64) {
+	w.buff.WriteByte(',')
+	w.buff.Write(zformatScore(f))
+	w.buff.Write(Delims)
+}
+
+func (w *resp3Writer) writeBool(b bool) {
+	if b {
+		w.buff.Write(hack.Slice("#t"))
+	} else {
+		w.buff.Write(hack.Slice("#f"))
+	}
+	w.buff.Write(Delims)
+}
+
+// writeMap writes the keys and values in turn as a map.
+func (w *resp3Writer) writeMap(lst []interface{}) {
+	w.writeAggregate('%', len(lst)/2)
+	for i := 0; i < len(lst); i++ {
+		writeValue(w, lst[i])
+	}
+}
+
+func (w *resp3Writer) writePush(lst []interface{}) {
+	w.writeAggregate('>', len(lst))
+	for i := 0; i < len(lst); i++ {
+		writeValue(w, lst[i])
+	}
 }
@@ -4,7 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/siddontang/go/sync2"
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
 )
 
 func TestRespWriter(t *testing.T) {
@@ -71,3 +82,276 @@ func TestRespWriter(t *testing.T) {
 	}
 
 }
+
+func TestResp3Writer(t *testing.T) {
+	for _, fixture := range []struct {
+		f func(w *resp3Writer)
+		e string
+	}{
+		{
+			f: func(w *resp3Writer) { w.writeBulk(nil) },
+			e: "_\r\n",
+		},
+		{
+			f: func(w *resp3Writer) { w.writeArray([]interface{}{[]byte("a"), nil, 1.5, true}) },
+			e: "*4\r\n$1\r\na\r\n_\r\n,1.5\r\n#t\r\n",
+		},
+		{
+			f: func(w *resp3Writer) { w.writeSliceArray(nil) },
+			e: "_\r\n",
+		},
+		{
+			f: func(w *resp3Writer) {
+				w.writeFVPairArray([]ledis.FVPair{{Field: []byte("f"), Value: []byte("v")}})
+			},
+			e: "%1\r\n$1\r\nf\r\n$1\r\nv\r\n",
+		},
+		{
+			f: func(w *resp3Writer) {
+				w.writeScorePairArray([]ledis.ScorePair{{Score: 1, Member: []byte("a")}, {Score: math.Inf(1), Member: []byte("b")}}, true)
+			},
+			e: "*2\r\n*2\r\n$1\r\na\r\n,1\r\n*2\r\n$1\r\nb\r\n,inf\r\n",
+		},
+		{
+			f: func(w *resp3Writer) {
+				w.writeScorePairArray([]ledis.ScorePair{{Score: 1, Member: []byte("a")}}, false)
+			},
+			e: "*1\r\n$1\r\na\r\n",
+		},
+		{
+			f: func(w *resp3Writer) { w.writeBool(false) },
+			e: "#f\r\n",
+		},
+		{
+			f: func(w *resp3Writer) { w.writePush([]interface{}{[]byte("message"), []byte("ch"), []byte("hi")}) },
+			e: ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n",
+		},
+		{
+			// the replies of EXEC
+			f: func(w *resp3Writer) {
+				w.writeArray([]interface{}{[]ledis.FVPair{{Field: []byte("f"), Value: []byte("v")}}, 2.5})
+			},
+			e: "*2\r\n%1\r\n$1\r\nf\r\n$1\r\nv\r\n,2.5\r\n",
+		},
+	} {
+		var b bytes.Buffer
+		w := &resp3Writer{&respWriter{buff: bufio.NewWriter(&b)}}
+		fixture.f(w)
+		w.flush()
+		if b.String() != fixture.e {
+			t.Errorf("resp3Writer, actual: %q, expected: %q", b.String(), fixture.e)
+		}
+	}
+}
+
+func TestClientLimits(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_client_limits"
+	cfg.Addr = "127.0.0.1:11195"
+	cfg.MaxClients = 2
+	cfg.Timeout = 1
+	cfg.ClientOutputBufferLimit.PubSub.HardLimit = 1024 * 1024
+
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	infoValue := func(c *goredis.Conn, name string) string {
+		s, err := goredis.String(c.Do("info", "clients"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(s, "\n") {
+			if strings.HasPrefix(line, name+":") {
+				return strings.TrimSpace(line[len(name)+1:])
+			}
+		}
+		return ""
+	}
+
+	c1, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+
+	// the subscriber never reads the messages
+	c2, err := net.Dial("tcp", cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	c2.Write([]byte("*2\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n"))
+
+	if _, err = c1.Do("ping"); err != nil {
+		t.Fatal(err)
+	}
+
+	// maxclients
+	c3, err := net.Dial("tcp", cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c3.Close()
+
+	if line, _ := bufio.NewReader(c3).ReadString('\n'); line != "-"+errMaxClients.Error()+"\r\n" {
+		t.Fatal(line)
+	} else if n := infoValue(c1, "rejected_connections"); n != "1" {
+		t.Fatal(n)
+	}
+
+	// the output buffer of the subscriber exceeds the limit
+	msg := make([]byte, 4096)
+	for i := 0; ; i++ {
+		if n, err := goredis.Int64(c1.Do("publish", "ch", msg)); err != nil {
+			t.Fatal(err)
+		} else if n == 0 {
+			break
+		} else if i > 100000 {
+			t.Fatal("the subscriber is not closed")
+		}
+	}
+
+	if n := infoValue(c1, "output_buffer_limit_closed_clients"); n != "1" {
+		t.Fatal(n)
+	}
+
+	// the idle timeout
+	time.Sleep(1500 * time.Millisecond)
+	if _, err = c1.Do("ping"); err == nil {
+		t.Fatal("must be closed for idle")
+	}
+
+	c4, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c4.Close()
+
+	if n := infoValue(c4, "timeout_closed_clients"); n != "1" {
+		t.Fatal(n)
+	}
+}
+
+func TestAddRespClientLimit(t *testing.T) {
+	app := &App{rcs: make(map[*respClient]struct{})}
+
+	// the concurrent accepts can not exceed maxclients
+	var wg sync.WaitGroup
+	var added sync2.AtomicInt64
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if app.addRespClient(new(respClient), 10) {
+				added.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := added.Get(); n != 10 {
+		t.Fatal(n)
+	} else if n := app.respClientNum(); n != 10 {
+		t.Fatal(n)
+	}
+}
+
+func TestOutputBufferLimitSlaveClass(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_client_slave_class"
+	cfg.Addr = "127.0.0.1:11202"
+	cfg.UseReplication = true
+
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	conn, err := net.Dial("tcp", cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	doResp3(t, conn, r, "hello", "3")
+	doResp3(t, conn, r, "subscribe", "slave_ch")
+
+	// the publishers check the limit of the class while REPLCONF changes it
+	const publishers = 4
+	stop := make(chan struct{})
+	published := make(chan int, publishers)
+	for i := 0; i < publishers; i++ {
+		go func() {
+			n := 0
+			defer func() { published <- n }()
+
+			c, err := goredis.Connect(cfg.Addr)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				if _, err := c.Do("publish", "slave_ch", "m"); err != nil {
+					return
+				}
+				n++
+			}
+		}()
+	}
+
+	// REPLCONF after the publishers are running
+	if v, ok := doResp3(t, conn, r).(resp3Push); !ok {
+		t.Fatal(v)
+	}
+
+	const replconfs = 100
+	var b bytes.Buffer
+	for i := 0; i < replconfs; i++ {
+		b.WriteString("*3\r\n$8\r\nreplconf\r\n$14\r\nlistening-port\r\n$5\r\n11203\r\n")
+	}
+	conn.Write(b.Bytes())
+
+	msgs := 1
+	for oks := 0; oks < replconfs; {
+		switch v := doResp3(t, conn, r).(type) {
+		case resp3Push:
+			msgs++
+		case string:
+			if v != OK {
+				t.Fatal(v)
+			}
+			oks++
+		default:
+			t.Fatal(v)
+		}
+	}
+	close(stop)
+
+	n := 0
+	for i := 0; i < publishers; i++ {
+		n += <-published
+	}
+	for ; msgs < n; msgs++ {
+		if v, ok := doResp3(t, conn, r).(resp3Push); !ok {
+			t.Fatal(v)
+		}
+	}
+}
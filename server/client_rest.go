@@ -0,0 +1,471 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/ledis"
+	"github.com/ugorji/go/codec"
+)
+
+// The RESTful API is served under restPrefix:
+//
+//	/v1/{db}/kv/{key}
+//	/v1/{db}/hash/{key}[/{field}]
+//	/v1/{db}/zset/{key}[/{member}]
+//
+// The path segments are URL escaped so the keys can contain slashes and
+// binary data, and the values are the request bodies. See doc/openapi.yaml.
+const restPrefix = "/v1/"
+
+var (
+	errRESTNotFound = errors.New("not found")
+	errRESTRoute    = errors.New("no such resource")
+	errRESTEmptyKey = errors.New("empty key")
+	errRESTFormat   = errors.New("unsupported response type, only raw, json, msgpack are supported")
+	errRESTBody     = errors.New("the body must be a string or a number")
+	errRESTTooLarge = errors.New("request body too large")
+)
+
+// restResource is the resource in the path of the request.
+type restResource struct {
+	db  int
+	typ string
+	key []byte
+	// sub is the hash field or the zset member, nil for the whole key
+	sub []byte
+}
+
+func newClientREST(app *App, w http.ResponseWriter, r *http.Request) {
+	app.connWait.Add(1)
+	defer app.connWait.Done()
+
+	format, err := restFormat(r)
+	if err != nil {
+		restWriteError(w, "json", http.StatusNotAcceptable, err)
+		return
+	}
+
+	res, err := parseRESTPath(r.URL.EscapedPath())
+	if err == errRESTRoute {
+		restWriteError(w, format, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		restWriteError(w, format, http.StatusBadRequest, err)
+		return
+	}
+
+	c := new(httpClient)
+	c.client = newClient(app)
+	defer c.client.close()
+
+	c.remoteAddr = c.addr(r)
+
+	if err = c.authRequest(r); err == nil && c.authEnabled() && !c.isAuthed {
+		err = ErrNotAuthenticated
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ledis"`)
+		restWriteError(w, format, http.StatusUnauthorized, err)
+		return
+	}
+
+	if c.db, err = app.ldb.Select(res.db); err != nil {
+		restWriteError(w, format, http.StatusBadRequest, err)
+		return
+	}
+
+	c.serveREST(w, r, res, format)
+}
+
+// parseRESTPath parses /v1/{db}/{type}/{key}[/{sub}].
+func parseRESTPath(p string) (*restResource, error) {
+	segs := strings.Split(strings.TrimPrefix(p, restPrefix), "/")
+	if len(segs) < 3 || len(segs) > 4 {
+		return nil, errRESTRoute
+	}
+
+	for i, s := range segs {
+		var err error
+		if segs[i], err = url.PathUnescape(s); err != nil {
+			return nil, err
+		}
+	}
+
+	res := new(restResource)
+
+	var err error
+	if res.db, err = strconv.Atoi(segs[0]); err != nil {
+		return nil, errRESTRoute
+	}
+
+	res.typ = segs[1]
+	switch res.typ {
+	case "kv":
+		if len(segs) != 3 {
+			return nil, errRESTRoute
+		}
+	case "hash", "zset":
+	default:
+		return nil, errRESTRoute
+	}
+
+	if len(segs[2]) == 0 {
+		return nil, errRESTEmptyKey
+	}
+	res.key = []byte(segs[2])
+
+	if len(segs) == 4 {
+		res.sub = []byte(segs[3])
+	}
+	return res, nil
+}
+
+// restFormat returns the format of the response, raw, json or msgpack.
+// The type query is used first like the command API, then the Accept header.
+func restFormat(r *http.Request) (string, error) {
+	if t := strings.ToLower(r.URL.Query().Get("type")); len(t) > 0 {
+		switch t {
+		case "raw", "json", "msgpack":
+			return t, nil
+		}
+		return "", errRESTFormat
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/json"):
+		return "json", nil
+	case strings.Contains(accept, "msgpack"):
+		return "msgpack", nil
+	}
+	return "raw", nil
+}
+
+// restCommand maps the method and the resource to the command.
+func restCommand(r *http.Request, res *restResource) (cmd string, args [][]byte, allow string) {
+	switch res.typ {
+	case "kv":
+		allow = "GET, PUT, DELETE"
+		switch r.Method {
+		case "GET":
+			cmd, args = "get", [][]byte{res.key}
+		case "PUT":
+			if ttl := r.URL.Query().Get("ttl"); len(ttl) > 0 {
+				cmd, args = "setex", [][]byte{res.key, []byte(ttl), nil}
+			} else {
+				cmd, args = "set", [][]byte{res.key, nil}
+			}
+		case "DELETE":
+			cmd, args = "del", [][]byte{res.key}
+		}
+	case "hash":
+		if res.sub == nil {
+			allow = "GET, DELETE"
+			switch r.Method {
+			case "GET":
+				cmd, args = "hgetall", [][]byte{res.key}
+			case "DELETE":
+				cmd, args = "hclear", [][]byte{res.key}
+			}
+			return
+		}
+
+		allow = "GET, PUT, DELETE"
+		switch r.Method {
+		case "GET":
+			cmd, args = "hget", [][]byte{res.key, res.sub}
+		case "PUT":
+			cmd, args = "hset", [][]byte{res.key, res.sub, nil}
+		case "DELETE":
+			cmd, args = "hdel", [][]byte{res.key, res.sub}
+		}
+	case "zset":
+		if res.sub == nil {
+			allow = "GET, DELETE"
+			switch r.Method {
+			case "GET":
+				q := r.URL.Query()
+				min, max := q.Get("min"), q.Get("max")
+				if len(min) == 0 {
+					min = "-inf"
+				}
+				if len(max) == 0 {
+					max = "+inf"
+				}
+
+				cmd, args = "zrangebyscore", [][]byte{res.key, []byte(min), []byte(max), []byte("withscores")}
+				if offset, count := q.Get("offset"), q.Get("count"); len(offset) > 0 || len(count) > 0 {
+					if len(offset) == 0 {
+						offset = "0"
+					}
+					if len(count) == 0 {
+						count = "-1"
+					}
+					args = append(args, []byte("limit"), []byte(offset), []byte(count))
+				}
+			case "DELETE":
+				cmd, args = "zclear", [][]byte{res.key}
+			}
+			return
+		}
+
+		allow = "GET, PUT, DELETE"
+		switch r.Method {
+		case "GET":
+			cmd, args = "zscore", [][]byte{res.key, res.sub}
+		case "PUT":
+			// the body is the score
+			cmd, args = "zadd", [][]byte{res.key, nil, res.sub}
+		case "DELETE":
+			cmd, args = "zrem", [][]byte{res.key, res.sub}
+		}
+	}
+	return
+}
+
+func (c *httpClient) serveREST(w http.ResponseWriter, r *http.Request, res *restResource, format string) {
+	cmd, args, allow := restCommand(r, res)
+	if len(cmd) == 0 {
+		w.Header().Set("Allow", allow)
+		restWriteError(w, format, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	if r.Method == "PUT" {
+		body, status, err := readRESTBody(r)
+		if err != nil {
+			restWriteError(w, format, status, err)
+			return
+		}
+
+		// the value is the nil placeholder in args
+		for i := range args {
+			if args[i] == nil {
+				args[i] = body
+			}
+		}
+	}
+
+	rw := new(replyWriter)
+	c.cmd = cmd
+	c.args = args
+	c.resp = rw
+
+	c.perform()
+
+	var reply interface{}
+	if len(rw.replies) > 0 {
+		reply = rw.replies[len(rw.replies)-1]
+	}
+
+	if err, ok := reply.(error); ok {
+		restWriteError(w, format, restStatus(err), err)
+		return
+	}
+
+	switch cmd {
+	case "get", "hget":
+		v, _ := reply.([]byte)
+		if v == nil {
+			restWriteError(w, format, http.StatusNotFound, errRESTNotFound)
+			return
+		}
+		restWriteValue(w, format, v)
+	case "zscore":
+		v, ok := reply.(float64)
+		if !ok {
+			restWriteError(w, format, http.StatusNotFound, errRESTNotFound)
+			return
+		}
+		restWriteValue(w, format, zformatScore(v))
+	case "hgetall":
+		lst, _ := reply.([]ledis.FVPair)
+		if len(lst) == 0 {
+			restWriteError(w, format, http.StatusNotFound, errRESTNotFound)
+			return
+		}
+
+		data := make([][]byte, 0, 2*len(lst))
+		for _, v := range lst {
+			data = append(data, v.Field, v.Value)
+		}
+		b64 := restBase64(w, format, data...)
+
+		m := make(map[string]string, len(lst))
+		for _, v := range lst {
+			m[restString(v.Field, b64)] = restString(v.Value, b64)
+		}
+		restWrite(w, format, http.StatusOK, m)
+	case "zrangebyscore":
+		sp, _ := reply.(scorePairArray)
+
+		type member struct {
+			Member string `json:"member" codec:"member"`
+			Score  string `json:"score" codec:"score"`
+		}
+		data := make([][]byte, len(sp.lst))
+		for i, v := range sp.lst {
+			data[i] = v.Member
+		}
+		b64 := restBase64(w, format, data...)
+
+		lst := make([]member, len(sp.lst))
+		for i, v := range sp.lst {
+			lst[i] = member{restString(v.Member, b64), hack.String(zformatScore(v.Score))}
+		}
+		restWrite(w, format, http.StatusOK, lst)
+	default:
+		// the writes and the deletions are idempotent
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// readRESTBody reads the value in the request body, it is a JSON string or
+// number for application/json, a msgpack string or number for
+// application/msgpack, and the raw bytes otherwise.
+func readRESTBody(r *http.Request) ([]byte, int, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(ledis.MaxValueSize)+1))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	} else if len(body) > ledis.MaxValueSize {
+		return nil, http.StatusRequestEntityTooLarge, errRESTTooLarge
+	}
+
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var v interface{}
+	switch mt {
+	case "application/json":
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err = dec.Decode(&v); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	case "application/msgpack", "application/x-msgpack":
+		var mh codec.MsgpackHandle
+		if err = codec.NewDecoderBytes(body, &mh).Decode(&v); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	default:
+		return body, http.StatusOK, nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		return []byte(v), http.StatusOK, nil
+	case []byte:
+		return v, http.StatusOK, nil
+	case json.Number:
+		return []byte(v), http.StatusOK, nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), http.StatusOK, nil
+	case uint64:
+		return strconv.AppendUint(nil, v, 10), http.StatusOK, nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 32), http.StatusOK, nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), http.StatusOK, nil
+	}
+	return nil, http.StatusBadRequest, errRESTBody
+}
+
+// restStatus returns the HTTP status code of the error reply.
+func restStatus(err error) int {
+	switch {
+	case err == ErrNotAuthenticated || err == ErrAuthenticationFailure:
+		return http.StatusUnauthorized
+	case err == ledis.ErrWriteInROnly || strings.HasPrefix(err.Error(), "NOPERM"):
+		return http.StatusForbidden
+	case err == ErrCmdParams || err == ErrValue || err == ErrSyntax ||
+		strings.HasPrefix(err.Error(), "invalid "):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// restWriteValue writes a single value, the raw bytes for the raw format.
+func restWriteValue(w http.ResponseWriter, format string, v []byte) {
+	if format == "raw" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(v)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(v)
+		return
+	}
+
+	restWrite(w, format, http.StatusOK, restString(v, restBase64(w, format, v)))
+}
+
+// restBase64 returns whether the data of the reply is encoded in base64, it
+// is only for JSON whose strings can not keep the bytes not valid UTF-8.
+// All the data of a reply is encoded if any one is not valid UTF-8, and the
+// Ledis-Encoding header is set to base64.
+func restBase64(w http.ResponseWriter, format string, data ...[]byte) bool {
+	if format == "msgpack" {
+		return false
+	}
+
+	for _, b := range data {
+		if !utf8.Valid(b) {
+			w.Header().Set("Ledis-Encoding", "base64")
+			return true
+		}
+	}
+	return false
+}
+
+func restString(b []byte, b64 bool) string {
+	if b64 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return hack.String(b)
+}
+
+func restWriteError(w http.ResponseWriter, format string, status int, err error) {
+	restWrite(w, format, status, map[string]string{"error": err.Error()})
+}
+
+// restWrite writes v in msgpack, or in JSON for the other formats because
+// the collections and the errors have no raw form.
+func restWrite(w http.ResponseWriter, format string, status int, v interface{}) {
+	var buf []byte
+	var err error
+	var contentType string
+
+	if format == "msgpack" {
+		var mh codec.MsgpackHandle
+		err = codec.NewEncoderBytes(&buf, &mh).Encode(v)
+		contentType = "application/msgpack"
+	} else {
+		buf, err = json.Marshal(v)
+		contentType = "application/json; charset=utf-8"
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+	w.WriteHeader(status)
+
+	if _, err = w.Write(buf); err != nil {
+		log.Error(err.Error())
+	}
+}
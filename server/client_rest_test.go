@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/ugorji/go/codec"
+)
+
+func TestREST(t *testing.T) {
+	startTestApp()
+
+	base := fmt.Sprintf("http://%s/v1/1", testApp.cfg.HttpAddr)
+
+	do := func(method string, path string, contentType string, body []byte) (int, []byte) {
+		var rd io.Reader
+		if body != nil {
+			rd = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequest(method, base+path, rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(contentType) > 0 {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	// the key has a slash and the value is binary
+	key := "/kv/" + url.PathEscape("rest/a")
+	value := []byte{0, 1, '/', 0xff}
+
+	if code, _ := do("PUT", key, "", value); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, b := do("GET", key, "", nil); code != http.StatusOK || !bytes.Equal(b, value) {
+		t.Fatal(code, b)
+	}
+
+	c, err := goredis.Connect(testApp.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.Do("select", 1); err != nil {
+		t.Fatal(err)
+	} else if b, _ := goredis.Bytes(c.Do("get", "rest/a")); !bytes.Equal(b, value) {
+		t.Fatal(b)
+	}
+
+	if code, _ := do("PUT", key+"?ttl=100", "application/json", []byte(`"hello"`)); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, b := do("GET", key+"?type=json", "", nil); code != http.StatusOK || string(b) != `"hello"` {
+		t.Fatal(code, string(b))
+	}
+
+	var mh codec.MsgpackHandle
+	var buf []byte
+	codec.NewEncoderBytes(&buf, &mh).Encode(12)
+	if code, _ := do("PUT", key, "application/msgpack", buf); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, b := do("GET", key, "", nil); code != http.StatusOK || string(b) != "12" {
+		t.Fatal(code, string(b))
+	}
+
+	if code, _ := do("PUT", key, "application/json", []byte(`[1]`)); code != http.StatusBadRequest {
+		t.Fatal(code)
+	} else if code, _ := do("DELETE", key, "", nil); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, b := do("GET", key, "", nil); code != http.StatusNotFound || string(b) != `{"error":"not found"}` {
+		t.Fatal(code, string(b))
+	}
+
+	// the keys are not cleaned like the paths
+	for _, k := range []string{"a//b", "a/b", ".", "x/../y", "y"} {
+		if code, _ := do("PUT", "/kv/"+url.PathEscape(k), "", []byte(k)); code != http.StatusNoContent {
+			t.Fatal(k, code)
+		}
+	}
+	for _, k := range []string{"a//b", "a/b", ".", "x/../y", "y"} {
+		if code, b := do("GET", "/kv/"+url.PathEscape(k), "", nil); code != http.StatusOK || string(b) != k {
+			t.Fatal(k, code, string(b))
+		} else if b, _ := goredis.Bytes(c.Do("get", k)); string(b) != k {
+			t.Fatal(k, string(b))
+		}
+		do("DELETE", "/kv/"+url.PathEscape(k), "", nil)
+	}
+
+	// hash
+	if code, _ := do("PUT", "/hash/rest_h/f1", "", []byte("v1")); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, _ := do("PUT", "/hash/rest_h/f2", "", []byte("v2")); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, b := do("GET", "/hash/rest_h/f1", "", nil); code != http.StatusOK || string(b) != "v1" {
+		t.Fatal(code, string(b))
+	}
+
+	var m map[string]string
+	if code, b := do("GET", "/hash/rest_h", "", nil); code != http.StatusOK {
+		t.Fatal(code)
+	} else if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	} else if len(m) != 2 || m["f2"] != "v2" {
+		t.Fatal(m)
+	}
+
+	if code, _ := do("DELETE", "/hash/rest_h/f1", "", nil); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, _ := do("GET", "/hash/rest_h/f1", "", nil); code != http.StatusNotFound {
+		t.Fatal(code)
+	} else if code, _ := do("DELETE", "/hash/rest_h", "", nil); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, _ := do("GET", "/hash/rest_h", "", nil); code != http.StatusNotFound {
+		t.Fatal(code)
+	}
+
+	// zset
+	for i, member := range []string{"a", "b", "c"} {
+		if code, _ := do("PUT", "/zset/rest_z/"+member, "application/json", []byte(fmt.Sprint(i+1))); code != http.StatusNoContent {
+			t.Fatal(code)
+		}
+	}
+
+	if code, b := do("GET", "/zset/rest_z/b", "", nil); code != http.StatusOK || string(b) != "2" {
+		t.Fatal(code, string(b))
+	} else if code, _ := do("PUT", "/zset/rest_z/b", "", []byte("x")); code != http.StatusBadRequest {
+		t.Fatal(code)
+	}
+
+	var lst []struct {
+		Member string `json:"member"`
+		Score  string `json:"score"`
+	}
+	if code, b := do("GET", "/zset/rest_z?min=2&count=1", "", nil); code != http.StatusOK {
+		t.Fatal(code)
+	} else if err := json.Unmarshal(b, &lst); err != nil {
+		t.Fatal(err)
+	} else if len(lst) != 1 || lst[0].Member != "b" || lst[0].Score != "2" {
+		t.Fatal(lst)
+	}
+
+	if code, _ := do("GET", "/zset/rest_z?min=x", "", nil); code != http.StatusBadRequest {
+		t.Fatal(code)
+	} else if code, _ := do("DELETE", "/zset/rest_z", "", nil); code != http.StatusNoContent {
+		t.Fatal(code)
+	}
+
+	// the binary data is in base64 for JSON, and kept by msgpack
+	bin := []byte{0, 0xff, 'a'}
+	binPath := url.PathEscape(string(bin))
+	if code, _ := do("PUT", "/hash/rest_bin/"+binPath, "", bin); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, _ := do("PUT", "/hash/rest_bin/f", "", []byte("v")); code != http.StatusNoContent {
+		t.Fatal(code)
+	} else if code, _ := do("PUT", "/zset/rest_bin/"+binPath, "", []byte("1")); code != http.StatusNoContent {
+		t.Fatal(code)
+	}
+
+	getJSON := func(path string, v interface{}) {
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.Header.Get("Ledis-Encoding") != "base64" {
+			t.Fatal(path, resp.Header)
+		} else if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enc := base64.StdEncoding.EncodeToString
+	m = nil
+	if getJSON("/hash/rest_bin", &m); len(m) != 2 || m[enc(bin)] != enc(bin) || m[enc([]byte("f"))] != enc([]byte("v")) {
+		t.Fatal(m)
+	}
+
+	var s string
+	if getJSON("/hash/rest_bin/"+binPath+"?type=json", &s); s != enc(bin) {
+		t.Fatal(s)
+	}
+
+	lst = nil
+	if getJSON("/zset/rest_bin", &lst); len(lst) != 1 || lst[0].Member != enc(bin) {
+		t.Fatal(lst)
+	}
+
+	var mm map[string][]byte
+	if code, b := do("GET", "/hash/rest_bin?type=msgpack", "", nil); code != http.StatusOK {
+		t.Fatal(code)
+	} else if err := codec.NewDecoderBytes(b, &mh).Decode(&mm); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(mm[string(bin)], bin) {
+		t.Fatal(mm)
+	}
+
+	do("DELETE", "/hash/rest_bin", "", nil)
+	do("DELETE", "/zset/rest_bin", "", nil)
+
+	// routes and methods
+	if code, _ := do("POST", "/hash/rest_h", "", nil); code != http.StatusMethodNotAllowed {
+		t.Fatal(code)
+	} else if code, _ := do("GET", "/list/rest_l", "", nil); code != http.StatusNotFound {
+		t.Fatal(code)
+	} else if code, _ := do("GET", "/kv/", "", nil); code != http.StatusBadRequest {
+		t.Fatal(code)
+	} else if code, _ := do("GET", "/kv/a?type=xml", "", nil); code != http.StatusNotAcceptable {
+		t.Fatal(code)
+	}
+}
+
+func TestRESTAuth(t *testing.T) {
+	startTestAppAuth("password")
+
+	u := fmt.Sprintf("http://%s/v1/0/kv/rest_auth", testAppAuth.cfg.HttpAddr)
+
+	r, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusUnauthorized {
+		t.Fatal(r.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", u, nil)
+	req.Header.Set("Authorization", "Bearer password")
+	if r, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusNotFound {
+		t.Fatal(r.StatusCode)
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+// ACL SETUSER username [rule ...]
+func aclSetUserCommand(c *client) error {
+	args := c.args[1:]
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	rules := make([]string, 0, len(args)-1)
+	for _, rule := range args[1:] {
+		rules = append(rules, string(rule))
+	}
+
+	if err := c.app.acl.setUser(string(args[0]), rules); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// ACL GETUSER username
+func aclGetUserCommand(c *client) error {
+	args := c.args[1:]
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	u := c.app.acl.user(string(args[0]))
+	if u == nil {
+		c.resp.writeBulk(nil)
+		return nil
+	}
+
+	flags := []interface{}{}
+	if u.enabled {
+		flags = append(flags, []byte("on"))
+	} else {
+		flags = append(flags, []byte("off"))
+	}
+	if u.nopass {
+		flags = append(flags, []byte("nopass"))
+	}
+
+	passwords := []interface{}{}
+	for _, h := range u.passwordHashes() {
+		passwords = append(passwords, []byte(h))
+	}
+
+	var categories []string
+	for _, rule := range u.rules() {
+		if strings.HasPrefix(rule, "+@") || strings.HasPrefix(rule, "-@") {
+			categories = append(categories, rule)
+		}
+	}
+
+	keys := []interface{}{}
+	for _, p := range u.patterns {
+		keys = append(keys, []byte(p))
+	}
+
+	dbs := []interface{}{}
+	if u.allDBs {
+		dbs = append(dbs, []byte("*"))
+	}
+	for _, index := range u.dbIndexes() {
+		dbs = append(dbs, []byte(strconv.Itoa(index)))
+	}
+
+	reply := []interface{}{
+		[]byte("flags"), flags,
+		[]byte("passwords"), passwords,
+		[]byte("categories"), []byte(strings.Join(categories, " ")),
+		[]byte("keys"), keys,
+		[]byte("dbs"), dbs,
+	}
+
+	if w, ok := c.resp.(*resp3Writer); ok {
+		w.writeMap(reply)
+	} else {
+		c.resp.writeArray(reply)
+	}
+	return nil
+}
+
+// ACL DELUSER username [username ...]
+func aclDelUserCommand(c *client) error {
+	args := c.args[1:]
+	if len(args) < 1 {
+		return ErrCmdParams
+	}
+
+	names := make([]string, 0, len(args))
+	for _, name := range args {
+		names = append(names, string(name))
+	}
+
+	n, err := c.app.acl.delUser(names)
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+func writeStrings(c *client, ss []string) {
+	ay := make([]interface{}, 0, len(ss))
+	for _, s := range ss {
+		ay = append(ay, []byte(s))
+	}
+	c.resp.writeArray(ay)
+}
+
+func aclCommand(c *client) error {
+	if len(c.args) < 1 {
+		return ErrCmdParams
+	}
+
+	sub := strings.ToLower(hack.String(c.args[0]))
+	switch sub {
+	case "setuser":
+		return aclSetUserCommand(c)
+	case "getuser":
+		return aclGetUserCommand(c)
+	case "deluser":
+		return aclDelUserCommand(c)
+	}
+
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	switch sub {
+	case "list":
+		writeStrings(c, c.app.acl.list())
+	case "users":
+		writeStrings(c, c.app.acl.userNames())
+	case "whoami":
+		c.resp.writeBulk([]byte(c.user))
+	case "cat":
+		writeStrings(c, aclCategories)
+	case "load":
+		if err := c.app.acl.load(); err != nil {
+			return err
+		}
+		c.resp.writeStatus(OK)
+	case "save":
+		if err := c.app.acl.save(); err != nil {
+			return err
+		}
+		c.resp.writeStatus(OK)
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+func init() {
+	register("acl", aclCommand)
+}
@@ -0,0 +1,429 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go/hack"
+)
+
+var (
+	errNoSuchClient = errors.New("No such client")
+	errClientName   = errors.New("Client names cannot contain spaces, newlines or special characters.")
+)
+
+// clientStat is the statistics of the client shown in CLIENT LIST.
+type clientStat struct {
+	name string
+
+	db       int
+	cmd      string
+	lastTime time.Time
+
+	sub     int
+	psub    int
+	multi   int
+	slave   bool
+	monitor bool
+
+	// the buffered bytes of the input and output
+	qbuf     int
+	qbufFree int
+	obl      int
+	omem     int
+}
+
+func (c *client) updateStat() {
+	c.statLock.Lock()
+	defer c.statLock.Unlock()
+
+	c.stat.db = c.db.Index()
+	c.stat.cmd = c.cmd
+	c.stat.lastTime = time.Now()
+
+	c.stat.sub = len(c.subChannels)
+	c.stat.psub = len(c.subPatterns)
+
+	c.stat.multi = -1
+	if c.multi != nil {
+		c.stat.multi = len(c.multi.cmds)
+	}
+
+	c.stat.slave = len(c.slaveListeningAddr) > 0
+	c.stat.monitor = c.monitoring
+}
+
+func (c *respClient) updateBufferStat() {
+	c.statLock.Lock()
+	c.stat.qbuf = c.br.Buffered()
+	c.stat.qbufFree = c.app.cfg.ConnReadBufferSize - c.stat.qbuf
+	c.stat.obl = c.rw.buff.Buffered()
+	c.stat.omem = c.rw.ob.len()
+	c.statLock.Unlock()
+}
+
+func (c *client) getStat() clientStat {
+	c.statLock.Lock()
+	s := c.stat
+	c.statLock.Unlock()
+	return s
+}
+
+func checkClientName(name []byte) error {
+	for _, b := range name {
+		if b < '!' || b > '~' {
+			return errClientName
+		}
+	}
+	return nil
+}
+
+func (c *client) setName(name string) {
+	c.statLock.Lock()
+	c.stat.name = name
+	c.statLock.Unlock()
+}
+
+// format formats the client like Redis CLIENT LIST.
+func (c *respClient) format(buf *bytes.Buffer, now time.Time) {
+	s := c.getStat()
+
+	flags := ""
+	if s.slave {
+		flags += "S"
+	}
+	if s.monitor {
+		flags += "O"
+	}
+	if s.sub > 0 || s.psub > 0 {
+		flags += "P"
+	}
+	if s.multi >= 0 {
+		flags += "x"
+	}
+	if len(flags) == 0 {
+		flags = "N"
+	}
+
+	fmt.Fprintf(buf, "id=%d addr=%s name=%s age=%d idle=%d flags=%s db=%d sub=%d psub=%d multi=%d qbuf=%d qbuf-free=%d obl=%d omem=%d cmd=%s\n",
+		c.id, c.remoteAddr, s.name,
+		int64(now.Sub(c.createTime).Seconds()), int64(now.Sub(s.lastTime).Seconds()),
+		flags, s.db, s.sub, s.psub, s.multi, s.qbuf, s.qbufFree, s.obl, s.omem, s.cmd)
+}
+
+// respClients returns all the RESP clients ordered by id.
+func (app *App) respClients() []*respClient {
+	app.rcm.Lock()
+	cs := make([]*respClient, 0, len(app.rcs))
+	for c := range app.rcs {
+		cs = append(cs, c)
+	}
+	app.rcm.Unlock()
+
+	sort.Slice(cs, func(i, j int) bool { return cs[i].id < cs[j].id })
+	return cs
+}
+
+// pauseExemptCommands are not paused by CLIENT PAUSE ALL, so the pause can
+// be checked and stopped, and the slaves can catch up during failover.
+var pauseExemptCommands = map[string]struct{}{
+	"client":   {},
+	"sync":     {},
+	"fullsync": {},
+	"replconf": {},
+}
+
+// clientPause pauses the clients for CLIENT PAUSE. The writes are paused by
+// holding the write lock of Ledis, and all the commands are paused in ALL mode.
+type clientPause struct {
+	sync.Mutex
+
+	app *App
+
+	end   time.Time
+	all   bool
+	timer *time.Timer
+
+	// resumes the writes, nil if not paused
+	resume func()
+
+	// closed when the pause ends
+	ch chan struct{}
+}
+
+func newClientPause(app *App) *clientPause {
+	p := new(clientPause)
+	p.app = app
+	return p
+}
+
+// pause pauses the clients for d, a longer pause or the ALL mode
+// extends the current pause.
+func (p *clientPause) pause(d time.Duration, all bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	end := time.Now().Add(d)
+
+	if p.resume == nil {
+		// wait for the running writes
+		p.resume = p.app.ldb.PauseWrites()
+		p.ch = make(chan struct{})
+		p.end = end
+		p.all = all
+		p.timer = time.AfterFunc(d, p.expire)
+		return
+	}
+
+	p.all = p.all || all
+	if end.After(p.end) {
+		p.end = end
+		p.timer.Reset(d)
+	}
+}
+
+func (p *clientPause) expire() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.resume != nil && !time.Now().Before(p.end) {
+		p.stop()
+	}
+}
+
+func (p *clientPause) unpause() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.resume != nil {
+		p.stop()
+	}
+}
+
+func (p *clientPause) stop() {
+	p.timer.Stop()
+	p.resume()
+	p.resume = nil
+	p.all = false
+	close(p.ch)
+}
+
+// wait waits until the pause ends if all the commands are paused.
+func (p *clientPause) wait(cmd string) {
+	if _, ok := pauseExemptCommands[cmd]; ok {
+		return
+	}
+
+	p.Lock()
+	all, ch := p.all, p.ch
+	p.Unlock()
+
+	if all {
+		select {
+		case <-ch:
+		case <-p.app.quit:
+		}
+	}
+}
+
+func clientListCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	var buf bytes.Buffer
+	now := time.Now()
+	for _, rc := range c.app.respClients() {
+		rc.format(&buf, now)
+	}
+
+	c.resp.writeBulk(buf.Bytes())
+	return nil
+}
+
+// CLIENT KILL addr
+// CLIENT KILL [ID id] [ADDR addr] [SKIPME yes/no]
+func clientKillCommand(c *client) error {
+	args := c.args[1:]
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	// the old format, kill the client with the address
+	oldFormat := len(args) == 1
+
+	var addr string
+	id := int64(-1)
+	skipMe := true
+
+	if oldFormat {
+		addr = string(args[0])
+	} else if len(args)%2 != 0 {
+		return ErrSyntax
+	}
+
+	for i := 0; !oldFormat && i < len(args); i += 2 {
+		switch strings.ToLower(hack.String(args[i])) {
+		case "id":
+			n, err := strconv.ParseInt(hack.String(args[i+1]), 10, 64)
+			if err != nil {
+				return ErrValue
+			}
+			id = n
+		case "addr":
+			addr = string(args[i+1])
+		case "skipme":
+			switch strings.ToLower(hack.String(args[i+1])) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				return ErrSyntax
+			}
+		default:
+			return ErrSyntax
+		}
+	}
+
+	var n int64
+	for _, rc := range c.app.respClients() {
+		if len(addr) > 0 && rc.remoteAddr != addr {
+			continue
+		} else if id >= 0 && rc.id != id {
+			continue
+		}
+
+		if rc.client == c {
+			if !oldFormat && skipMe {
+				continue
+			}
+			// close after replying
+			c.killed = true
+		} else {
+			rc.conn.Close()
+		}
+		n++
+	}
+
+	if oldFormat {
+		if n == 0 {
+			return errNoSuchClient
+		}
+		c.resp.writeStatus(OK)
+	} else {
+		c.resp.writeInteger(n)
+	}
+	return nil
+}
+
+func clientSetNameCommand(c *client) error {
+	if len(c.args) != 2 {
+		return ErrCmdParams
+	}
+
+	if err := checkClientName(c.args[1]); err != nil {
+		return err
+	}
+
+	c.setName(string(c.args[1]))
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func clientGetNameCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	if name := c.getStat().name; len(name) == 0 {
+		c.resp.writeBulk(nil)
+	} else {
+		c.resp.writeBulk([]byte(name))
+	}
+	return nil
+}
+
+func clientIDCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	c.resp.writeInteger(c.id)
+	return nil
+}
+
+// CLIENT PAUSE timeout [WRITE|ALL]
+func clientPauseCommand(c *client) error {
+	args := c.args[1:]
+	if len(args) != 1 && len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	timeout, err := strconv.ParseInt(hack.String(args[0]), 10, 64)
+	if err != nil || timeout < 0 {
+		return errors.New("timeout is not an integer or out of range")
+	}
+
+	all := true
+	if len(args) == 2 {
+		switch strings.ToLower(hack.String(args[1])) {
+		case "write":
+			all = false
+		case "all":
+		default:
+			return ErrSyntax
+		}
+	}
+
+	c.app.pause.pause(time.Duration(timeout)*time.Millisecond, all)
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func clientUnpauseCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	c.app.pause.unpause()
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func clientCommand(c *client) error {
+	if len(c.args) < 1 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(hack.String(c.args[0])) {
+	case "list":
+		return clientListCommand(c)
+	case "kill":
+		return clientKillCommand(c)
+	case "setname":
+		return clientSetNameCommand(c)
+	case "getname":
+		return clientGetNameCommand(c)
+	case "id":
+		return clientIDCommand(c)
+	case "pause":
+		return clientPauseCommand(c)
+	case "unpause":
+		return clientUnpauseCommand(c)
+	default:
+		return ErrCmdParams
+	}
+}
+
+func init() {
+	register("client", clientCommand)
+}
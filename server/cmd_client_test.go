@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestClientCommand(t *testing.T) {
+	startTestApp()
+
+	c1, err := goredis.Connect("127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+
+	c2, err := goredis.Connect("127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	id1, err := goredis.Int64(c1.Do("client", "id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id2, _ := goredis.Int64(c2.Do("client", "id"))
+	if id2 <= id1 {
+		t.Fatal(id1, id2)
+	}
+
+	if _, err := goredis.String(c1.Do("client", "getname")); err != goredis.ErrNil {
+		t.Fatal(err)
+	}
+
+	if _, err := c1.Do("client", "setname", "bad name"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c1.Do("client", "setname", "client_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, _ := goredis.String(c1.Do("client", "getname")); name != "client_1" {
+		t.Fatal(name)
+	}
+
+	c1.Do("select", 2)
+	c1.Do("select", 2)
+
+	list, err := goredis.String(c2.Do("client", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := ""
+	for _, l := range strings.Split(list, "\n") {
+		if strings.HasPrefix(l, fmt.Sprintf("id=%d ", id1)) {
+			line = l
+		}
+	}
+
+	if !strings.Contains(line, " name=client_1 ") || !strings.Contains(line, " db=2 ") ||
+		!strings.Contains(line, " flags=N ") || !strings.HasSuffix(line, " cmd=select") {
+		t.Fatal(list)
+	}
+
+	if _, err := c2.Do("client", "kill", "127.0.0.1:1"); err == nil {
+		t.Fatal("must error for no such client")
+	}
+
+	if n, err := goredis.Int64(c2.Do("client", "kill", "id", id1)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if _, err := c1.Do("ping"); err == nil {
+		t.Fatal("must be killed")
+	}
+
+	// skip itself by default
+	if n, _ := goredis.Int64(c2.Do("client", "kill", "id", id2)); n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, _ := goredis.Int64(c2.Do("client", "kill", "id", id2, "skipme", "no")); n != 1 {
+		t.Fatal(n)
+	}
+
+	if _, err := c2.Do("ping"); err == nil {
+		t.Fatal("must be killed")
+	}
+}
+
+func TestClientPause(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c2 := getTestConn()
+	defer c2.Close()
+
+	if _, err := c.Do("client", "pause", 300, "write"); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := c2.Do("get", "pause_key"); err != nil {
+		t.Fatal(err)
+	} else if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatal("read must not be paused", d)
+	}
+
+	if _, err := c2.Do("set", "pause_key", "1"); err != nil {
+		t.Fatal(err)
+	} else if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatal("write must be paused", d)
+	}
+
+	c.Do("client", "pause", 10000, "all")
+
+	done := make(chan struct{})
+	go func() {
+		c2.Do("get", "pause_key")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("all commands must be paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if _, err := c.Do("client", "unpause"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("must be unpaused")
+	}
+
+	if _, err := c2.Do("set", "pause_key", "2"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+var (
+	errInvalidCommand = errors.New("invalid command specified")
+	errInvalidArity   = errors.New("invalid number of arguments specified for command")
+	errNoKeyArgs      = errors.New("the command has no key arguments")
+)
+
+// commandReply is the same as Redis, the name, arity, flags, first key,
+// last key, step and the ACL categories.
+func commandReply(cmd *command) []interface{} {
+	flags := []interface{}{}
+	for _, name := range cmd.flagNames() {
+		flags = append(flags, name)
+	}
+
+	categories := []interface{}{}
+	if cmd.category != "" {
+		categories = append(categories, "@"+cmd.category)
+	}
+
+	return []interface{}{
+		[]byte(cmd.name),
+		int64(cmd.arity),
+		flags,
+		int64(cmd.keys.first),
+		int64(cmd.keys.last),
+		int64(cmd.keys.step),
+		categories,
+	}
+}
+
+// COMMAND GETKEYS command [arg ...]
+func commandGetKeysCommand(c *client) error {
+	if len(c.args) < 2 {
+		return ErrCmdParams
+	}
+
+	cmd, ok := regCmds[strings.ToLower(hack.String(c.args[1]))]
+	if !ok {
+		return errInvalidCommand
+	}
+
+	args := c.args[2:]
+	if !cmd.checkArity(args) {
+		return errInvalidArity
+	}
+
+	keys := cmd.commandKeys(args)
+	if len(keys) == 0 {
+		return errNoKeyArgs
+	}
+
+	c.resp.writeSliceArray(keys)
+	return nil
+}
+
+func commandCommand(c *client) error {
+	if len(c.args) == 0 {
+		names := make([]string, 0, len(regCmds))
+		for name := range regCmds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		ay := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			ay = append(ay, commandReply(regCmds[name]))
+		}
+		c.resp.writeArray(ay)
+		return nil
+	}
+
+	switch strings.ToLower(hack.String(c.args[0])) {
+	case "count":
+		if len(c.args) != 1 {
+			return ErrCmdParams
+		}
+		c.resp.writeInteger(int64(len(regCmds)))
+	case "info":
+		ay := make([]interface{}, 0, len(c.args)-1)
+		for _, name := range c.args[1:] {
+			if cmd, ok := regCmds[strings.ToLower(hack.String(name))]; ok {
+				ay = append(ay, commandReply(cmd))
+			} else {
+				ay = append(ay, nil)
+			}
+		}
+		c.resp.writeArray(ay)
+	case "getkeys":
+		return commandGetKeysCommand(c)
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+func init() {
+	register("command", commandCommand)
+}
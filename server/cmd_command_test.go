@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+func TestCommandTable(t *testing.T) {
+	for name, info := range commandTable {
+		if _, ok := regCmds[name]; !ok {
+			t.Fatalf("%s is not registered", name)
+		} else if info.arity == 0 {
+			t.Fatalf("%s has no arity", name)
+		} else if info.flags&cmdWrite != 0 && info.flags&cmdReadonly != 0 {
+			t.Fatalf("%s can not be write and readonly", name)
+		}
+	}
+
+	if !regCmds["get"].checkArity([][]byte{[]byte("a")}) || regCmds["get"].checkArity(nil) {
+		t.Fatal("invalid get arity")
+	} else if !regCmds["del"].checkArity([][]byte{[]byte("a"), []byte("b")}) || regCmds["del"].checkArity(nil) {
+		t.Fatal("invalid del arity")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if n, err := goredis.Int(c.Do("command", "count")); err != nil {
+		t.Fatal(err)
+	} else if n != len(regCmds) {
+		t.Fatal(n)
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("command")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != len(regCmds) {
+		t.Fatal(len(v))
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("command", "info", "mset", "nocommand")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[1] != nil {
+		t.Fatal(v)
+	} else if info, ok := v[0].([]interface{}); !ok || len(info) != 7 {
+		t.Fatal(v[0])
+	} else if name, _ := goredis.String(info[0], nil); name != "mset" {
+		t.Fatal(name)
+	} else if n, _ := goredis.Int(info[1], nil); n != -3 {
+		t.Fatal(n)
+	} else if flags := fmt.Sprint(info[2]); flags != "[write]" {
+		t.Fatal(flags)
+	} else if step, _ := goredis.Int(info[5], nil); step != 2 {
+		t.Fatal(step)
+	} else if categories := fmt.Sprint(info[6]); categories != "[@write]" {
+		t.Fatal(categories)
+	}
+
+	if keys, err := goredis.Strings(c.Do("command", "getkeys", "zunionstore", "d", "2", "a", "b", "weights", "1", "2")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(keys, []string{"d", "a", "b"}) {
+		t.Fatal(keys)
+	}
+
+	for _, args := range [][]interface{}{
+		{"getkeys", "nocommand", "a"},
+		{"getkeys", "get"},
+		{"getkeys", "ping"},
+	} {
+		if _, err := c.Do("command", args...); err == nil {
+			t.Fatalf("%v must fail", args)
+		}
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	// arity
+	if _, err := c.Do("get", "a", "b"); err == nil || err.Error() != ErrCmdParams.Error() {
+		t.Fatal(err)
+	}
+
+	// the write commands are rejected in the readonly mode
+	if _, err := c.Do("config", "set", "readonly", "yes"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Do("set", "command_flags_key", "1")
+	c.Do("config", "set", "readonly", "no")
+	if err == nil || err.Error() != ledis.ErrWriteInROnly.Error() {
+		t.Fatal(err)
+	}
+
+	// the noscript commands
+	if _, err := c.Do("eval", "return ledis.call('multi')", "0"); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatal(err)
+	}
+}
@@ -277,6 +277,61 @@ func httlCommand(c *client) error {
 	return nil
 }
 
+func hpexpireCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	duration, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.HPExpire(args[0], duration); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func hpexpireAtCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	when, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.HPExpireAt(args[0], when); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func hpttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.HPTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
 func hpersistCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
@@ -325,6 +380,9 @@ func init() {
 	register("hexpire", hexpireCommand)
 	register("hexpireat", hexpireAtCommand)
 	register("httl", httlCommand)
+	register("hpexpire", hpexpireCommand)
+	register("hpexpireat", hpexpireAtCommand)
+	register("hpttl", hpttlCommand)
 	register("hpersist", hpersistCommand)
 	register("hkeyexists", hkeyexistsCommand)
 }
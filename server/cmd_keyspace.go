@@ -0,0 +1,272 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// keyspaceType holds the per data type functions used by the
+// unified keyspace commands, so that del, exists, expire and ttl
+// can act on all data types like Redis.
+type keyspaceType struct {
+	// name is the type name replied by the type command, same as Redis
+	name string
+
+	exists   func(db *ledis.DB, key []byte) (int64, error)
+	clear    func(db *ledis.DB, key []byte) (int64, error)
+	expire   func(db *ledis.DB, key []byte, duration int64) (int64, error)
+	expireAt func(db *ledis.DB, key []byte, when int64) (int64, error)
+	ttl      func(db *ledis.DB, key []byte) (int64, error)
+	persist  func(db *ledis.DB, key []byte) (int64, error)
+
+	// the same as above but in milliseconds
+	pexpire   func(db *ledis.DB, key []byte, duration int64) (int64, error)
+	pexpireAt func(db *ledis.DB, key []byte, when int64) (int64, error)
+	pttl      func(db *ledis.DB, key []byte) (int64, error)
+}
+
+var keyspaceTypes = []keyspaceType{
+	{
+		name:   "string",
+		exists: (*ledis.DB).Exists,
+		clear: func(db *ledis.DB, key []byte) (int64, error) {
+			return db.Del(key)
+		},
+		expire:   (*ledis.DB).Expire,
+		expireAt: (*ledis.DB).ExpireAt,
+		ttl:      (*ledis.DB).TTL,
+		persist:  (*ledis.DB).Persist,
+
+		pexpire:   (*ledis.DB).PExpire,
+		pexpireAt: (*ledis.DB).PExpireAt,
+		pttl:      (*ledis.DB).PTTL,
+	},
+	{
+		name:     "list",
+		exists:   (*ledis.DB).LKeyExists,
+		clear:    (*ledis.DB).LClear,
+		expire:   (*ledis.DB).LExpire,
+		expireAt: (*ledis.DB).LExpireAt,
+		ttl:      (*ledis.DB).LTTL,
+		persist:  (*ledis.DB).LPersist,
+
+		pexpire:   (*ledis.DB).LPExpire,
+		pexpireAt: (*ledis.DB).LPExpireAt,
+		pttl:      (*ledis.DB).LPTTL,
+	},
+	{
+		name:     "hash",
+		exists:   (*ledis.DB).HKeyExists,
+		clear:    (*ledis.DB).HClear,
+		expire:   (*ledis.DB).HExpire,
+		expireAt: (*ledis.DB).HExpireAt,
+		ttl:      (*ledis.DB).HTTL,
+		persist:  (*ledis.DB).HPersist,
+
+		pexpire:   (*ledis.DB).HPExpire,
+		pexpireAt: (*ledis.DB).HPExpireAt,
+		pttl:      (*ledis.DB).HPTTL,
+	},
+	{
+		name:     "set",
+		exists:   (*ledis.DB).SKeyExists,
+		clear:    (*ledis.DB).SClear,
+		expire:   (*ledis.DB).SExpire,
+		expireAt: (*ledis.DB).SExpireAt,
+		ttl:      (*ledis.DB).STTL,
+		persist:  (*ledis.DB).SPersist,
+
+		pexpire:   (*ledis.DB).SPExpire,
+		pexpireAt: (*ledis.DB).SPExpireAt,
+		pttl:      (*ledis.DB).SPTTL,
+	},
+	{
+		name:     "zset",
+		exists:   (*ledis.DB).ZKeyExists,
+		clear:    (*ledis.DB).ZClear,
+		expire:   (*ledis.DB).ZExpire,
+		expireAt: (*ledis.DB).ZExpireAt,
+		ttl:      (*ledis.DB).ZTTL,
+		persist:  (*ledis.DB).ZPersist,
+
+		pexpire:   (*ledis.DB).ZPExpire,
+		pexpireAt: (*ledis.DB).ZPExpireAt,
+		pttl:      (*ledis.DB).ZPTTL,
+	},
+}
+
+// keyTypes returns all the data types which the key exists in.
+// Unlike Redis, the same key may be used by different data types in LedisDB.
+func keyTypes(db *ledis.DB, key []byte) ([]*keyspaceType, error) {
+	var tps []*keyspaceType
+	for i := range keyspaceTypes {
+		tp := &keyspaceTypes[i]
+		if n, err := tp.exists(db, key); err != nil {
+			return nil, err
+		} else if n == 1 {
+			tps = append(tps, tp)
+		}
+	}
+
+	return tps, nil
+}
+
+func xdelCommand(c *client) error {
+	var n int64
+	for _, key := range c.args {
+		tps, err := keyTypes(c.db, key)
+		if err != nil {
+			return err
+		}
+
+		for _, tp := range tps {
+			if _, err := tp.clear(c.db, key); err != nil {
+				return err
+			}
+		}
+
+		if len(tps) > 0 {
+			n++
+		}
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+func xexistsCommand(c *client) error {
+	args := c.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	var n int64
+	for _, key := range args {
+		tps, err := keyTypes(c.db, key)
+		if err != nil {
+			return err
+		} else if len(tps) > 0 {
+			n++
+		}
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+func xexpireGenericCommand(c *client, expire func(tp *keyspaceType, key []byte, v int64) (int64, error)) error {
+	v, err := ledis.StrInt64(c.args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	key := c.args[0]
+	tps, err := keyTypes(c.db, key)
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	for _, tp := range tps {
+		if n, err = expire(tp, key, v); err != nil {
+			return err
+		}
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+func xexpireCommand(c *client) error {
+	return xexpireGenericCommand(c, func(tp *keyspaceType, key []byte, duration int64) (int64, error) {
+		return tp.expire(c.db, key, duration)
+	})
+}
+
+func xexpireAtCommand(c *client) error {
+	return xexpireGenericCommand(c, func(tp *keyspaceType, key []byte, when int64) (int64, error) {
+		return tp.expireAt(c.db, key, when)
+	})
+}
+
+func xpexpireCommand(c *client) error {
+	return xexpireGenericCommand(c, func(tp *keyspaceType, key []byte, duration int64) (int64, error) {
+		return tp.pexpire(c.db, key, duration)
+	})
+}
+
+func xpexpireAtCommand(c *client) error {
+	return xexpireGenericCommand(c, func(tp *keyspaceType, key []byte, when int64) (int64, error) {
+		return tp.pexpireAt(c.db, key, when)
+	})
+}
+
+func xttlCommand(c *client) error {
+	return xttlGenericCommand(c, func(tp *keyspaceType, key []byte) (int64, error) {
+		return tp.ttl(c.db, key)
+	})
+}
+
+func xpttlCommand(c *client) error {
+	return xttlGenericCommand(c, func(tp *keyspaceType, key []byte) (int64, error) {
+		return tp.pttl(c.db, key)
+	})
+}
+
+func xttlGenericCommand(c *client, ttl func(tp *keyspaceType, key []byte) (int64, error)) error {
+	key := c.args[0]
+	tps, err := keyTypes(c.db, key)
+	if err != nil {
+		return err
+	}
+
+	// same as Redis, -2 means the key does not exist
+	// and -1 means the key exists but has no associated expire.
+	var v int64 = -2
+	if len(tps) > 0 {
+		if v, err = ttl(tps[0], key); err != nil {
+			return err
+		}
+	}
+
+	c.resp.writeInteger(v)
+	return nil
+}
+
+func xpersistCommand(c *client) error {
+	key := c.args[0]
+	tps, err := keyTypes(c.db, key)
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	for _, tp := range tps {
+		if m, err := tp.persist(c.db, key); err != nil {
+			return err
+		} else if m == 1 {
+			n = 1
+		}
+	}
+
+	c.resp.writeInteger(n)
+	return nil
+}
+
+func typeCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	tps, err := keyTypes(c.db, c.args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(tps) == 0 {
+		c.resp.writeStatus("none")
+	} else {
+		c.resp.writeStatus(tps[0].name)
+	}
+
+	return nil
+}
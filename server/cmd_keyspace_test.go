@@ -0,0 +1,118 @@
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestUnifiedKeyspace(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_unified_keyspace"
+	cfg.Addr = "127.0.0.1:11190"
+	cfg.UnifiedKeyspace = true
+
+	os.RemoveAll(cfg.DataDir)
+
+	s, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Run()
+	defer s.Close()
+
+	c := goredis.NewClient(cfg.Addr, "")
+	c.SetMaxIdleConns(1)
+	defer c.Close()
+
+	c.Do("set", "k", "v")
+	c.Do("rpush", "l", "1")
+	c.Do("hset", "h", "f", "v")
+	c.Do("sadd", "s", "m")
+	c.Do("zadd", "z", 1, "m")
+
+	types := map[string]string{"k": "string", "l": "list", "h": "hash", "s": "set", "z": "zset", "none": "none"}
+	for key, tp := range types {
+		if v, err := goredis.String(c.Do("type", key)); err != nil {
+			t.Fatal(err)
+		} else if v != tp {
+			t.Fatalf("type of %s is %s, not %s", key, v, tp)
+		}
+	}
+
+	if n, err := goredis.Int(c.Do("exists", "k", "l", "h", "s", "z", "none")); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatal(n)
+	}
+
+	for _, key := range []string{"k", "l", "h", "s", "z"} {
+		if n, err := goredis.Int(c.Do("ttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n != -1 {
+			t.Fatal(key, n)
+		}
+
+		if n, err := goredis.Int(c.Do("expire", key, 100)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(key, n)
+		}
+
+		if n, err := goredis.Int(c.Do("ttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n <= 0 {
+			t.Fatal(key, n)
+		}
+
+		if n, err := goredis.Int(c.Do("persist", key)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(key, n)
+		}
+	}
+
+	if n, err := goredis.Int(c.Do("ttl", "none")); err != nil {
+		t.Fatal(err)
+	} else if n != -2 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pttl", "none")); err != nil {
+		t.Fatal(err)
+	} else if n != -2 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pexpire", "z", 100000)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("pttl", "z")); err != nil {
+		t.Fatal(err)
+	} else if n <= 0 || n > 100000 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("expire", "none", 100)); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("del", "k", "l", "h", "s", "z", "none")); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatal(n)
+	}
+
+	if n, err := goredis.Int(c.Do("exists", "k", "l", "h", "s", "z")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"strconv"
+	"strings"
 
+	"github.com/siddontang/go/hack"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
@@ -39,18 +41,41 @@ func getCommand(c *client) error {
 	return nil
 }
 
+// set key value [EX seconds|PX milliseconds]
 func setCommand(c *client) error {
 	args := c.args
-	if len(args) != 2 {
+	if len(args) != 2 && len(args) != 4 {
 		return ErrCmdParams
 	}
 
-	if err := c.db.Set(args[0], args[1]); err != nil {
-		return err
-	} else {
+	if len(args) == 2 {
+		if err := c.db.Set(args[0], args[1]); err != nil {
+			return err
+		}
+
 		c.resp.writeStatus(OK)
+		return nil
 	}
 
+	duration, err := ledis.StrInt64(args[3], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	switch strings.ToLower(hack.String(args[2])) {
+	case "ex":
+		err = c.db.SetEX(args[0], duration, args[1])
+	case "px":
+		err = c.db.PSetEX(args[0], duration, args[1])
+	default:
+		return ErrSyntax
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
 	return nil
 }
 
@@ -104,9 +129,31 @@ func setexCommand(c *client) error {
 	return nil
 }
 
+func psetexCommand(c *client) error {
+	args := c.args
+	if len(args) != 3 {
+		return ErrCmdParams
+	}
+
+	duration, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if err := c.db.PSetEX(args[0], duration, args[2]); err != nil {
+		return err
+	} else {
+		c.resp.writeStatus(OK)
+	}
+
+	return nil
+}
+
 func existsCommand(c *client) error {
 	args := c.args
-	if len(args) != 1 {
+	if c.app.cfg.UnifiedKeyspace {
+		return xexistsCommand(c)
+	} else if len(args) != 1 {
 		return ErrCmdParams
 	}
 
@@ -195,6 +242,10 @@ func delCommand(c *client) error {
 		return ErrCmdParams
 	}
 
+	if c.app.cfg.UnifiedKeyspace {
+		return xdelCommand(c)
+	}
+
 	if n, err := c.db.Del(args...); err != nil {
 		return err
 	} else {
@@ -250,6 +301,10 @@ func expireCommand(c *client) error {
 		return ErrCmdParams
 	}
 
+	if c.app.cfg.UnifiedKeyspace {
+		return xexpireCommand(c)
+	}
+
 	duration, err := ledis.StrInt64(args[1], nil)
 	if err != nil {
 		return ErrValue
@@ -270,6 +325,10 @@ func expireAtCommand(c *client) error {
 		return ErrCmdParams
 	}
 
+	if c.app.cfg.UnifiedKeyspace {
+		return xexpireAtCommand(c)
+	}
+
 	when, err := ledis.StrInt64(args[1], nil)
 	if err != nil {
 		return ErrValue
@@ -290,6 +349,10 @@ func ttlCommand(c *client) error {
 		return ErrCmdParams
 	}
 
+	if c.app.cfg.UnifiedKeyspace {
+		return xttlCommand(c)
+	}
+
 	if v, err := c.db.TTL(args[0]); err != nil {
 		return err
 	} else {
@@ -299,12 +362,83 @@ func ttlCommand(c *client) error {
 	return nil
 }
 
+func pexpireCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	if c.app.cfg.UnifiedKeyspace {
+		return xpexpireCommand(c)
+	}
+
+	duration, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.PExpire(args[0], duration); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func pexpireAtCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	if c.app.cfg.UnifiedKeyspace {
+		return xpexpireAtCommand(c)
+	}
+
+	when, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.PExpireAt(args[0], when); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func pttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if c.app.cfg.UnifiedKeyspace {
+		return xpttlCommand(c)
+	}
+
+	if v, err := c.db.PTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
 func persistCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
+	if c.app.cfg.UnifiedKeyspace {
+		return xpersistCommand(c)
+	}
+
 	if n, err := c.db.Persist(args[0]); err != nil {
 		return err
 	} else {
@@ -536,10 +670,15 @@ func init() {
 	register("setbit", setbitCommand)
 	register("setnx", setnxCommand)
 	register("setex", setexCommand)
+	register("psetex", psetexCommand)
 	register("setrange", setrangeCommand)
 	register("strlen", strlenCommand)
 	register("expire", expireCommand)
 	register("expireat", expireAtCommand)
 	register("ttl", ttlCommand)
+	register("pexpire", pexpireCommand)
+	register("pexpireat", pexpireAtCommand)
+	register("pttl", pttlCommand)
 	register("persist", persistCommand)
+	register("type", typeCommand)
 }
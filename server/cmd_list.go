@@ -218,6 +218,61 @@ func lttlCommand(c *client) error {
 	return nil
 }
 
+func lpexpireCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	duration, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.LPExpire(args[0], duration); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func lpexpireAtCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	when, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.LPExpireAt(args[0], when); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func lpttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.LPTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
 func lpersistCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
@@ -491,6 +546,9 @@ func init() {
 	register("lexpire", lexpireCommand)
 	register("lexpireat", lexpireAtCommand)
 	register("lttl", lttlCommand)
+	register("lpexpire", lpexpireCommand)
+	register("lpexpireat", lpexpireAtCommand)
+	register("lpttl", lpttlCommand)
 	register("lpersist", lpersistCommand)
 	register("lkeyexists", lkeyexistsCommand)
 
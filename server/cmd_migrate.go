@@ -176,15 +176,15 @@ func xdel(db *ledis.DB, tp string, key []byte) error {
 func xttl(db *ledis.DB, tp string, key []byte) (int64, error) {
 	switch strings.ToUpper(tp) {
 	case KVName:
-		return db.TTL(key)
+		return db.PTTL(key)
 	case HashName:
-		return db.HTTL(key)
+		return db.HPTTL(key)
 	case ListName:
-		return db.LTTL(key)
+		return db.LPTTL(key)
 	case SetName:
-		return db.STTL(key)
+		return db.SPTTL(key)
 	case ZSetName:
-		return db.ZTTL(key)
+		return db.ZPTTL(key)
 	default:
 		return 0, fmt.Errorf("invalid key type %s", tp)
 	}
@@ -493,8 +493,8 @@ func migrateKey(c *client, conn *goredis.PoolConn, tp string, key []byte, timeou
 
 	conn.SetReadDeadline(time.Now().Add(t))
 
-	//ttl is second, but restore need millisecond
-	if _, err = conn.Do("restore", key, ttl*1e3, data); err != nil {
+	//ttl is millisecond
+	if _, err = conn.Do("restore", key, ttl, data); err != nil {
 		return err
 	}
 
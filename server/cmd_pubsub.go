@@ -0,0 +1,246 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+var errSubscribedMode = errors.New("only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+
+// subscribeCommands change the subscriptions of the client,
+// they can only be used in the RESP connection.
+var subscribeCommands = map[string]struct{}{
+	"subscribe":    {},
+	"unsubscribe":  {},
+	"psubscribe":   {},
+	"punsubscribe": {},
+}
+
+func isSubscribeCommand(cmd string) bool {
+	_, ok := subscribeCommands[cmd]
+	return ok
+}
+
+// subscribed returns whether the client is in the subscribed mode,
+// only subscribe commands, PING and QUIT can be used in this mode with RESP2.
+func (c *client) subscribed() bool {
+	return len(c.subChannels) > 0 || len(c.subPatterns) > 0
+}
+
+func (c *client) subscriptionNum() int64 {
+	return int64(len(c.subChannels) + len(c.subPatterns))
+}
+
+// deliver appends the published message to the output buffer of the client
+// if it still subscribes the channel or pattern, it does not wait for the
+// command the client is running.
+func (c *client) deliver(isPattern bool, key string, msg []interface{}) bool {
+	if c.out == nil {
+		return false
+	}
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	subs := c.subChannels
+	if isPattern {
+		subs = c.subPatterns
+	}
+
+	if _, ok := subs[key]; !ok {
+		return false
+	}
+
+	c.out.push(msg)
+	return true
+}
+
+// unsubscribeAll removes all the subscriptions of the client without replying.
+func (c *client) unsubscribeAll() {
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	for ch := range c.subChannels {
+		c.app.pubsub.unsubscribe(c, ch)
+	}
+	c.subChannels = nil
+
+	for pattern := range c.subPatterns {
+		c.app.pubsub.punsubscribe(c, pattern)
+	}
+	c.subPatterns = nil
+}
+
+func (c *client) writeSubscribeReply(kind string, key []byte) {
+	c.resp.writePush([]interface{}{[]byte(kind), key, c.subscriptionNum()})
+}
+
+func subscribeCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	if c.subChannels == nil {
+		c.subChannels = make(map[string]struct{})
+	}
+
+	for _, arg := range c.args {
+		ch := string(arg)
+		if _, ok := c.subChannels[ch]; !ok {
+			c.subChannels[ch] = struct{}{}
+			c.app.pubsub.subscribe(c, ch)
+		}
+
+		c.writeSubscribeReply("subscribe", arg)
+	}
+
+	return nil
+}
+
+func unsubscribeCommand(c *client) error {
+	args := c.args
+	if len(args) == 0 {
+		if len(c.subChannels) == 0 {
+			c.writeSubscribeReply("unsubscribe", nil)
+			return nil
+		}
+
+		for ch := range c.subChannels {
+			args = append(args, []byte(ch))
+		}
+	}
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	for _, arg := range args {
+		ch := string(arg)
+		if _, ok := c.subChannels[ch]; ok {
+			delete(c.subChannels, ch)
+			c.app.pubsub.unsubscribe(c, ch)
+		}
+
+		c.writeSubscribeReply("unsubscribe", arg)
+	}
+
+	return nil
+}
+
+func psubscribeCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	if c.subPatterns == nil {
+		c.subPatterns = make(map[string]struct{})
+	}
+
+	for _, arg := range c.args {
+		pattern := string(arg)
+		if _, ok := c.subPatterns[pattern]; !ok {
+			c.subPatterns[pattern] = struct{}{}
+			c.app.pubsub.psubscribe(c, pattern)
+		}
+
+		c.writeSubscribeReply("psubscribe", arg)
+	}
+
+	return nil
+}
+
+func punsubscribeCommand(c *client) error {
+	args := c.args
+	if len(args) == 0 {
+		if len(c.subPatterns) == 0 {
+			c.writeSubscribeReply("punsubscribe", nil)
+			return nil
+		}
+
+		for pattern := range c.subPatterns {
+			args = append(args, []byte(pattern))
+		}
+	}
+
+	c.subLock.Lock()
+	defer c.subLock.Unlock()
+
+	for _, arg := range args {
+		pattern := string(arg)
+		if _, ok := c.subPatterns[pattern]; ok {
+			delete(c.subPatterns, pattern)
+			c.app.pubsub.punsubscribe(c, pattern)
+		}
+
+		c.writeSubscribeReply("punsubscribe", arg)
+	}
+
+	return nil
+}
+
+func publishCommand(c *client) error {
+	if len(c.args) != 2 {
+		return ErrCmdParams
+	}
+
+	n := c.app.pubsub.publish(string(c.args[0]), c.args[1])
+	c.resp.writeInteger(n)
+	return nil
+}
+
+// PUBSUB CHANNELS [pattern]
+// PUBSUB NUMSUB [channel ...]
+// PUBSUB NUMPAT
+func pubsubCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(hack.String(c.args[0])) {
+	case "channels":
+		var pattern string
+		if len(c.args) == 2 {
+			pattern = string(c.args[1])
+		} else if len(c.args) > 2 {
+			return ErrCmdParams
+		}
+
+		chs := c.app.pubsub.activeChannels(pattern)
+		ay := make([][]byte, len(chs))
+		for i, ch := range chs {
+			ay[i] = []byte(ch)
+		}
+		c.resp.writeSliceArray(ay)
+	case "numsub":
+		ay := make([]interface{}, 0, 2*(len(c.args)-1))
+		for _, ch := range c.args[1:] {
+			ay = append(ay, ch, c.app.pubsub.numSub(string(ch)))
+		}
+		c.resp.writeArray(ay)
+	case "numpat":
+		if len(c.args) != 1 {
+			return ErrCmdParams
+		}
+		c.resp.writeInteger(c.app.pubsub.numPat())
+	default:
+		return ErrCmdParams
+	}
+
+	return nil
+}
+
+func init() {
+	register("subscribe", subscribeCommand)
+	register("unsubscribe", unsubscribeCommand)
+	register("psubscribe", psubscribeCommand)
+	register("punsubscribe", punsubscribeCommand)
+	register("publish", publishCommand)
+	register("pubsub", pubsubCommand)
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+)
+
+func receiveStrings(t *testing.T, c *goredis.Conn) []interface{} {
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	v, err := goredis.Values(c.Receive())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range v {
+		if b, ok := v[i].([]byte); ok {
+			v[i] = string(b)
+		}
+	}
+	return v
+}
+
+func TestPubSub(t *testing.T) {
+	startTestApp()
+
+	sub, err := goredis.Connect("127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	c := getTestConn()
+	defer c.Close()
+
+	sub.Send("subscribe", "ch1", "ch2")
+	if v := receiveStrings(t, sub); v[0] != "subscribe" || v[1] != "ch1" || v[2] != int64(1) {
+		t.Fatal(v)
+	}
+	if v := receiveStrings(t, sub); v[0] != "subscribe" || v[1] != "ch2" || v[2] != int64(2) {
+		t.Fatal(v)
+	}
+
+	sub.Send("psubscribe", "news.*")
+	if v := receiveStrings(t, sub); v[0] != "psubscribe" || v[1] != "news.*" || v[2] != int64(3) {
+		t.Fatal(v)
+	}
+
+	// subscribed mode
+	sub.Send("get", "a")
+	if _, err := sub.Receive(); err == nil {
+		t.Fatal("must error in subscribed mode")
+	}
+
+	sub.Send("ping")
+	if v := receiveStrings(t, sub); v[0] != "pong" {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("publish", "ch1", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if v := receiveStrings(t, sub); v[0] != "message" || v[1] != "ch1" || v[2] != "hello" {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("publish", "news.it", "world")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if v := receiveStrings(t, sub); v[0] != "pmessage" || v[1] != "news.*" || v[2] != "news.it" || v[3] != "world" {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("publish", "ch3", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if v, err := goredis.Strings(c.Do("pubsub", "channels")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 || v[0] != "ch1" || v[1] != "ch2" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("pubsub", "channels", "*2")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 || v[0] != "ch2" {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Values(c.Do("pubsub", "numsub", "ch1", "ch3")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 4 || v[1] != int64(1) || v[3] != int64(0) {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("pubsub", "numpat")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	sub.Send("unsubscribe")
+	receiveStrings(t, sub)
+	if v := receiveStrings(t, sub); v[0] != "unsubscribe" || v[2] != int64(1) {
+		t.Fatal(v)
+	}
+
+	sub.Send("punsubscribe", "news.*")
+	if v := receiveStrings(t, sub); v[0] != "punsubscribe" || v[2] != int64(0) {
+		t.Fatal(v)
+	}
+
+	// leave the subscribed mode
+	if v, err := goredis.String(sub.Do("ping")); err != nil {
+		t.Fatal(err)
+	} else if v != PONG {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("publish", "ch1", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tbl := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "abc", true},
+		{"a*c", "abc", true},
+		{"a*d", "abc", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a[bc]c", "acc", true},
+		{"a[^bc]c", "abc", false},
+		{"a[a-c]c", "abc", true},
+		{"a[x-z]c", "abc", false},
+		{"a\\*c", "a*c", true},
+		{"a\\*c", "abc", false},
+		{"news.*", "news.it", true},
+		{"news.*", "new.it", false},
+	}
+
+	for _, v := range tbl {
+		if globMatch(v.pattern, v.s) != v.match {
+			t.Fatalf("%s %s must be %v", v.pattern, v.s, v.match)
+		}
+	}
+}
+
+func TestPubSubBlockedSubscriber(t *testing.T) {
+	startTestApp()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	doResp3(t, conn, r, "hello", "3")
+	if v, ok := doResp3(t, conn, r, "subscribe", "blocked_ch").(resp3Push); !ok || v[0] != "subscribe" {
+		t.Fatal(v)
+	}
+
+	// the subscriber is blocked in BLPOP, the publisher must not wait for it
+	conn.Write([]byte("*3\r\n$5\r\nblpop\r\n$11\r\nblocked_key\r\n$1\r\n0\r\n"))
+	time.Sleep(100 * time.Millisecond)
+
+	c, err := goredis.Connect("127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if n, err := goredis.Int(c.Do("publish", "blocked_ch", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if v, ok := doResp3(t, conn, r).(resp3Push); !ok || v[0] != "message" || v[2] != "hello" {
+		t.Fatal(v)
+	}
+
+	if _, err := c.Do("rpush", "blocked_key", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := doResp3(t, conn, r).([]interface{}); !ok || len(v) != 2 || v[0] != "blocked_key" {
+		t.Fatal(v)
+	}
+}
+
+func TestPubSubPublishSubscribed(t *testing.T) {
+	startTestApp()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	doResp3(t, conn, r, "hello", "3")
+	doResp3(t, conn, r, "subscribe", "self_ch")
+
+	// the message to the client itself comes before the reply of PUBLISH
+	if v, ok := doResp3(t, conn, r, "publish", "self_ch", "hi").(resp3Push); !ok || v[0] != "message" || v[2] != "hi" {
+		t.Fatal(v)
+	} else if n := doResp3(t, conn, r); n != int64(1) {
+		t.Fatal(n)
+	}
+}
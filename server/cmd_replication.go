@@ -175,6 +175,7 @@ func replconfCommand(c *client) error {
 				return err
 			} else {
 				c.slaveListeningAddr = net.JoinHostPort(host, hack.String(args[i+1]))
+				c.isSlave.Set(true)
 			}
 
 			c.app.addSlave(c)
@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/siddontang/go/hack"
-	"github.com/siddontang/go/num"
 	"github.com/siddontang/ledisdb/ledis"
 )
 
@@ -249,7 +248,7 @@ func (scg scanCommandGroup) xzscanCommand(c *client) error {
 	vv := make([][]byte, 0, len(ay)*2)
 
 	for _, v := range ay {
-		vv = append(vv, v.Member, num.FormatInt64ToSlice(v.Score))
+		vv = append(vv, v.Member, zformatScore(v.Score))
 	}
 
 	data[1] = vv
@@ -46,14 +46,19 @@ func evalGenericCommand(c *client, evalSha1 bool) (err error) {
 
 	defer func() {
 		luaClient.db = nil
+		luaClient.tx = nil
 		// luaClient.script = nil
 
 		s.Unlock()
 	}()
 
 	luaClient.db = c.db
+	luaClient.tx = c.tx
 	// luaClient.script = m
 	luaClient.remoteAddr = c.remoteAddr
+	// the commands called by the script are checked as the caller
+	luaClient.isAuthed = c.isAuthed
+	luaClient.user = c.user
 
 	if err := parseEvalArgs(l, c); err != nil {
 		return err
@@ -1,44 +1,157 @@
 package server
 
 import (
+	"errors"
+
 	"github.com/siddontang/go/hack"
 	"github.com/siddontang/go/num"
 
 	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var errNoProto = errors.New("NOPROTO unsupported protocol version")
+
 func pingCommand(c *client) error {
+	if c.subscribed() && c.proto != 3 {
+		// same as Redis, reply in the format of the published messages
+		c.resp.writeArray([]interface{}{[]byte("pong"), []byte{}})
+		return nil
+	}
+
 	c.resp.writeStatus(PONG)
 	return nil
 }
 
 func defaultAuth(c *config.Config, password string) bool {
-	return c.AuthPassword == password
+	return c.GetAuthPassword() == password
 }
 
-func authCommand(c *client) error {
-	if len(c.args) != 1 {
-		return ErrCmdParams
+// auth authenticates the client as the ACL user, the password of the
+// default user is checked by AuthMethod or auth_password.
+func (c *client) auth(user string, password string) bool {
+	u := c.app.acl.user(user)
+	if u == nil || !u.enabled {
+		c.isAuthed = false
+	} else if user == defaultUser {
+		method := defaultAuth
+		if c.app.cfg.AuthMethod != nil {
+			method = c.app.cfg.AuthMethod
+		}
+		c.isAuthed = method(c.app.cfg, password)
+	} else {
+		c.isAuthed = u.checkPassword(password)
 	}
 
-	method := defaultAuth
-	if c.app.cfg.AuthMethod != nil {
-		method = c.app.cfg.AuthMethod
+	if c.isAuthed {
+		c.user = user
 	}
+	return c.isAuthed
+}
 
-	if method(c.app.cfg, string(c.args[0])) {
-		c.isAuthed = true
+// AUTH [username] password
+func authCommand(c *client) error {
+	user := defaultUser
+	switch len(c.args) {
+	case 1:
+	case 2:
+		user = string(c.args[0])
+	default:
+		return ErrCmdParams
+	}
+
+	if c.auth(user, string(c.args[len(c.args)-1])) {
 		c.resp.writeStatus(OK)
 		return nil
 	} else {
-		c.isAuthed = false
 		return ErrAuthenticationFailure
 	}
 }
 
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func helloCommand(c *client) error {
+	args := c.args
+
+	proto := c.proto
+	if len(args) > 0 {
+		v, err := strconv.Atoi(hack.String(args[0]))
+		if err != nil {
+			return ErrValue
+		} else if v != 2 && v != 3 {
+			return errNoProto
+		}
+		proto = v
+		args = args[1:]
+	}
+
+	var user, password []byte
+	var name []byte
+	for len(args) > 0 {
+		switch strings.ToLower(hack.String(args[0])) {
+		case "auth":
+			if len(args) < 3 {
+				return ErrSyntax
+			}
+			user, password = args[1], args[2]
+			args = args[3:]
+		case "setname":
+			if len(args) < 2 {
+				return ErrSyntax
+			}
+			if err := checkClientName(args[1]); err != nil {
+				return err
+			}
+			name = args[1]
+			args = args[2:]
+		default:
+			return ErrSyntax
+		}
+	}
+
+	if password != nil {
+		if !c.auth(string(user), string(password)) {
+			return ErrAuthenticationFailure
+		}
+	} else if c.authEnabled() && !c.isAuthed {
+		return ErrNotAuthenticated
+	}
+
+	if err := c.setProto(proto); err != nil {
+		return err
+	}
+
+	if name != nil {
+		c.setName(string(name))
+	}
+
+	c.app.m.Lock()
+	role := "master"
+	if len(c.app.cfg.SlaveOf) > 0 {
+		role = "slave"
+	}
+	c.app.m.Unlock()
+
+	reply := []interface{}{
+		[]byte("server"), []byte("ledis"),
+		[]byte("version"), []byte(ledis.Version),
+		[]byte("proto"), int64(proto),
+		[]byte("id"), c.id,
+		[]byte("mode"), []byte("standalone"),
+		[]byte("role"), []byte(role),
+		[]byte("modules"), []interface{}{},
+	}
+
+	if w, ok := c.resp.(*resp3Writer); ok {
+		w.writeMap(reply)
+	} else {
+		c.resp.writeArray(reply)
+	}
+	return nil
+}
+
 func echoCommand(c *client) error {
 	if len(c.args) != 1 {
 		return ErrCmdParams
@@ -56,21 +169,12 @@ func selectCommand(c *client) error {
 	if index, err := strconv.Atoi(hack.String(c.args[0])); err != nil {
 		return err
 	} else {
-		// if c.db.IsInMulti() {
-		// 	if err := c.script.Select(index); err != nil {
-		// 		return err
-		// 	} else {
-		// 		c.db = c.script.DB
-		// 	}
-		// } else {
-		// 	if db, err := c.ldb.Select(index); err != nil {
-		// 		return err
-		// 	} else {
-		// 		c.db = db
-		// 	}
-		// }
-
-		if db, err := c.ldb.Select(index); err != nil {
+		if c.tx != nil {
+			if err := c.tx.Select(index); err != nil {
+				return err
+			}
+			c.db = c.tx.DB
+		} else if db, err := c.ldb.Select(index); err != nil {
 			return err
 		} else {
 			c.db = db
@@ -143,23 +247,50 @@ func timeCommand(c *client) error {
 	return nil
 }
 
+// CONFIG GET pattern
 func configGetCommand(c *client) error {
 	args := c.args
 	if len(args) != 2 {
 		return ErrCmdParams
 	}
 
-	ay := make([][]byte, 0, 2)
-	key := hack.String(args[1])
-	switch key {
-	case "databases":
-		ay = append(ay, []byte("databases"), num.FormatIntToSlice(c.app.cfg.Databases))
+	params := c.app.cfg.Get(hack.String(args[1]))
+
+	ay := make([][]byte, 0, 2*len(params))
+	for _, p := range params {
+		ay = append(ay, []byte(p.Name), []byte(p.Value))
 	}
 
 	c.resp.writeSliceArray(ay)
 	return nil
 }
 
+// CONFIG SET parameter value
+func configSetCommand(c *client) error {
+	args := c.args
+	if len(args) != 3 {
+		return ErrCmdParams
+	}
+
+	if err := c.app.setConfig(hack.String(args[1]), string(args[2])); err != nil {
+		return err
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func configResetStatCommand(c *client) error {
+	if len(c.args) != 1 {
+		return ErrCmdParams
+	}
+
+	c.app.resetStat()
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
 func configCommand(c *client) error {
 	if len(c.args) < 1 {
 		return ErrCmdParams
@@ -175,13 +306,33 @@ func configCommand(c *client) error {
 		}
 	case "get":
 		return configGetCommand(c)
+	case "set":
+		return configSetCommand(c)
+	case "resetstat":
+		return configResetStatCommand(c)
 	default:
 		return ErrCmdParams
 	}
 }
 
+// MONITOR streams the processed commands to the client until it quits.
+func monitorCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	if !c.monitoring {
+		c.monitoring = true
+		c.app.monitors.add(c)
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
 func init() {
 	register("auth", authCommand)
+	register("hello", helloCommand)
 	register("ping", pingCommand)
 	register("echo", echoCommand)
 	register("select", selectCommand)
@@ -190,4 +341,5 @@ func init() {
 	register("flushdb", flushdbCommand)
 	register("time", timeCommand)
 	register("config", configCommand)
+	register("monitor", monitorCommand)
 }
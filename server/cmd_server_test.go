@@ -1,9 +1,22 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
 )
 
 func TestAuth(t *testing.T) {
@@ -87,3 +100,325 @@ func TestXSelect(t *testing.T) {
 	c2.Do("SELECT", 0)
 
 }
+
+type resp3Push []interface{}
+
+// readResp3 reads a RESP3 reply, the map is map[string]interface{} and
+// the push message is resp3Push.
+func readResp3(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return errors.New(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case ',':
+		return strconv.ParseFloat(line[1:], 64)
+	case '#':
+		return line[1:] == "t", nil
+	case '_':
+		return nil, nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*', '>':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil, nil
+		}
+		ay := make([]interface{}, n)
+		for i := range ay {
+			if ay[i], err = readResp3(r); err != nil {
+				return nil, err
+			}
+		}
+		if line[0] == '>' {
+			return resp3Push(ay), nil
+		}
+		return ay, nil
+	case '%':
+		n, _ := strconv.Atoi(line[1:])
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k, err := readResp3(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[k.(string)], err = readResp3(r); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("invalid reply %q", line)
+	}
+}
+
+func doResp3(t *testing.T, conn net.Conn, r *bufio.Reader, args ...string) interface{} {
+	if args != nil {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+		if _, err := conn.Write(b.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	v, err := readResp3(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestHello(t *testing.T) {
+	startTestApp()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if v, ok := doResp3(t, conn, r, "hello", "4").(error); !ok || !strings.HasPrefix(v.Error(), "NOPROTO") {
+		t.Fatal(v)
+	}
+
+	m, ok := doResp3(t, conn, r, "hello", "3", "setname", "resp3_client").(map[string]interface{})
+	if !ok || m["server"] != "ledis" || m["proto"] != int64(3) || m["role"] != "master" {
+		t.Fatal(m)
+	}
+
+	doResp3(t, conn, r, "del", "resp3_kv")
+	doResp3(t, conn, r, "hclear", "resp3_hash")
+	doResp3(t, conn, r, "zclear", "resp3_zset")
+
+	if v := doResp3(t, conn, r, "get", "resp3_kv"); v != nil {
+		t.Fatal(v)
+	}
+
+	doResp3(t, conn, r, "hmset", "resp3_hash", "a", "1", "b", "2")
+	if v := doResp3(t, conn, r, "hgetall", "resp3_hash").(map[string]interface{}); len(v) != 2 || v["a"] != "1" || v["b"] != "2" {
+		t.Fatal(v)
+	}
+
+	doResp3(t, conn, r, "zadd", "resp3_zset", "1.5", "a", "2", "b")
+	if v := doResp3(t, conn, r, "zscore", "resp3_zset", "a"); v != 1.5 {
+		t.Fatal(v)
+	}
+
+	v := doResp3(t, conn, r, "zrange", "resp3_zset", "0", "-1", "withscores").([]interface{})
+	if len(v) != 2 {
+		t.Fatal(v)
+	} else if p := v[1].([]interface{}); p[0] != "b" || p[1] != float64(2) {
+		t.Fatal(v)
+	}
+
+	// the replies of EXEC are in RESP3 too
+	doResp3(t, conn, r, "multi")
+	doResp3(t, conn, r, "hgetall", "resp3_hash")
+	doResp3(t, conn, r, "zincrby", "resp3_zset", "1", "a")
+	if v := doResp3(t, conn, r, "exec").([]interface{}); len(v[0].(map[string]interface{})) != 2 || v[1] != 2.5 {
+		t.Fatal(v)
+	}
+
+	// the published messages are push messages, and other commands can be
+	// used in the subscribed mode
+	if v, ok := doResp3(t, conn, r, "subscribe", "resp3_ch").(resp3Push); !ok || v[0] != "subscribe" {
+		t.Fatal(v)
+	}
+
+	if v := doResp3(t, conn, r, "get", "resp3_kv"); v != nil {
+		t.Fatal(v)
+	}
+
+	c := getTestConn()
+	defer c.Close()
+	c.Do("publish", "resp3_ch", "hi")
+
+	if v, ok := doResp3(t, conn, r).(resp3Push); !ok || v[0] != "message" || v[2] != "hi" {
+		t.Fatal(v)
+	}
+
+	doResp3(t, conn, r, "unsubscribe")
+
+	if v, ok := doResp3(t, conn, r, "hello", "2").([]interface{}); !ok || len(v) != 14 {
+		t.Fatal(v)
+	}
+
+	if v, ok := doResp3(t, conn, r, "hgetall", "resp3_hash").([]interface{}); !ok || len(v) != 4 {
+		t.Fatal(v)
+	}
+}
+
+func TestHelloAuth(t *testing.T) {
+	startTestAppAuth("password")
+
+	conn, err := net.Dial("tcp", "127.0.0.1:20000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	if v, ok := doResp3(t, conn, r, "hello", "3").(error); !ok || v.Error() != ErrNotAuthenticated.Error() {
+		t.Fatal(v)
+	}
+
+	if v, ok := doResp3(t, conn, r, "hello", "3", "auth", "default", "wrong").(error); !ok || v.Error() != ErrAuthenticationFailure.Error() {
+		t.Fatal(v)
+	}
+
+	if _, ok := doResp3(t, conn, r, "hello", "3", "auth", "default", "password").(map[string]interface{}); !ok {
+		t.Fatal("must be authenticated")
+	}
+
+	if v := doResp3(t, conn, r, "get", "tmp_hello_key"); v != nil {
+		t.Fatal(v)
+	}
+}
+
+func TestInfoSections(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("select", 9)
+	defer c.Do("select", 0)
+
+	c.Do("flushdb")
+	c.Do("set", "info_key", "1")
+	c.Do("hset", "info_hash", "f", "1")
+	c.Do("get")
+
+	s, err := goredis.String(c.Do("info", "commandstats"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(s, "# Commandstats\r\n") || !strings.Contains(s, "\r\ncmdstat_hset:calls=") ||
+		!regexp.MustCompile(`cmdstat_get:calls=\d+,usec=\d+,usec_per_call=[\d.]+,errors=[1-9]`).MatchString(s) {
+		t.Fatal(s)
+	}
+
+	if s, err = goredis.String(c.Do("info", "keyspace")); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(s, "\r\ndb9:keys=2,kv=1,list=0,hash=1,set=0,zset=0\r\n") {
+		t.Fatal(s)
+	}
+
+	c.Do("del", "info_key")
+
+	if s, _ = goredis.String(c.Do("info")); !strings.Contains(s, "\r\ndb9:keys=1,kv=0,list=0,hash=1,set=0,zset=0\r\n") {
+		t.Fatal(s)
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if ay, err := goredis.Strings(c.Do("config", "get", "databases")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 || ay[0] != "databases" || ay[1] != "16" {
+		t.Fatal(ay)
+	}
+
+	if ay, _ := goredis.Strings(c.Do("config", "get", "slowlog-*")); len(ay) != 4 {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("config", "set", "replication.wait-sync-time", "800"); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Do("config", "set", "replication.wait-sync-time", "500")
+
+	if ay, _ := goredis.Strings(c.Do("config", "get", "replication.wait-sync-time")); len(ay) != 2 || ay[1] != "800" {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("config", "set", "replication.wait-sync-time", "-1"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("config", "set", "data-dir", "/tmp"); err == nil {
+		t.Fatal("must error")
+	}
+
+	// the access log can be enabled at runtime
+	if _, err := c.Do("config", "set", "access-log", "config_access.log"); err != nil {
+		t.Fatal(err)
+	}
+	c.Do("get", "config_access_key")
+	if _, err := c.Do("config", "set", "access-log", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf, err := ioutil.ReadFile("/tmp/testdb/config_access.log"); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Contains(buf, []byte("config_access_key")) {
+		t.Fatal(string(buf))
+	}
+
+	c.Do("get", "config_key")
+	if _, err := c.Do("config", "resetstat"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := goredis.String(c.Do("info", "commandstats")); strings.Contains(s, "cmdstat_get:") {
+		t.Fatal(s)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_reload_config"
+	cfg.Addr = "127.0.0.1:11192"
+	cfg.FileName = "/tmp/test_reload_config.toml"
+
+	os.RemoveAll(cfg.DataDir)
+	defer os.Remove(cfg.FileName)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	fileCfg := config.NewConfigDefault()
+	fileCfg.AuthPassword = "reload"
+	fileCfg.SlowlogMaxLen = 10
+	fileCfg.Databases = 32
+	if err := fileCfg.DumpFile(cfg.FileName); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := cfg.GetAuthPassword(); p != "reload" {
+		t.Fatal(p)
+	} else if _, n := cfg.GetSlowlog(); n != 10 {
+		t.Fatal(n)
+	} else if cfg.Databases != 16 {
+		t.Fatal("databases can not be reloaded")
+	}
+}
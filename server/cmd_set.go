@@ -247,6 +247,62 @@ func sttlCommand(c *client) error {
 
 }
 
+func spexpireCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	duration, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.SPExpire(args[0], duration); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func spexpireAtCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	when, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	if v, err := c.db.SPExpireAt(args[0], when); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
+func spttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.SPTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+
+}
+
 func spersistCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
@@ -293,6 +349,9 @@ func init() {
 	register("sexpire", sexpireCommand)
 	register("sexpireat", sexpireAtCommand)
 	register("sttl", sttlCommand)
+	register("spexpire", spexpireCommand)
+	register("spexpireat", spexpireAtCommand)
+	register("spttl", spttlCommand)
 	register("spersist", spersistCommand)
 	register("skeyexists", skeyexistsCommand)
 
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/siddontang/go/hack"
+)
+
+// SLOWLOG GET [count]
+func slowlogGetCommand(c *client) error {
+	args := c.args[1:]
+	if len(args) > 1 {
+		return ErrCmdParams
+	}
+
+	count := 10
+	if len(args) == 1 {
+		n, err := strconv.Atoi(hack.String(args[0]))
+		if err != nil {
+			return ErrValue
+		}
+		count = n
+	}
+
+	if count < 0 {
+		count = int(^uint(0) >> 1)
+	}
+
+	entries := c.app.slowlog.get(count)
+
+	ay := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		ay = append(ay, []interface{}{
+			e.id,
+			e.time,
+			e.duration,
+			e.args,
+			[]byte(e.addr),
+			[]byte(e.name),
+		})
+	}
+
+	c.resp.writeArray(ay)
+	return nil
+}
+
+func slowlogCommand(c *client) error {
+	if len(c.args) < 1 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(hack.String(c.args[0])) {
+	case "get":
+		return slowlogGetCommand(c)
+	case "len":
+		if len(c.args) != 1 {
+			return ErrCmdParams
+		}
+		c.resp.writeInteger(int64(c.app.slowlog.len()))
+		return nil
+	case "reset":
+		if len(c.args) != 1 {
+			return ErrCmdParams
+		}
+		c.app.slowlog.reset()
+		c.resp.writeStatus(OK)
+		return nil
+	default:
+		return ErrCmdParams
+	}
+}
+
+func init() {
+	register("slowlog", slowlogCommand)
+}
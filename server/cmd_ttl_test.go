@@ -127,3 +127,111 @@ func TestExpire(t *testing.T) {
 	}
 
 }
+
+func TestPExpire(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	for _, tt := range []string{"k", "l", "h", "s", "z"} {
+		var key string
+		prefix := tt
+		switch tt {
+		case "k":
+			prefix = ""
+			key = "kv_pttl"
+			c.Do("set", key, "123")
+		case "l":
+			key = "list_pttl"
+			c.Do("rpush", key, "123")
+		case "h":
+			key = "hash_pttl"
+			c.Do("hset", key, "a", "123")
+		case "s":
+			key = "set_pttl"
+			c.Do("sadd", key, "123")
+		case "z":
+			key = "zset_pttl"
+			c.Do("zadd", key, 123, "a")
+		}
+
+		if n, err := goredis.Int(c.Do(prefix+"pexpire", key, 10000)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(n)
+		}
+
+		if n, err := goredis.Int64(c.Do(prefix+"pttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n <= 9000 || n > 10000 {
+			t.Fatal(tt, n)
+		}
+
+		tm := time.Now().UnixNano()/int64(time.Millisecond) + 3000
+		if n, err := goredis.Int(c.Do(prefix+"pexpireat", key, tm)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(n)
+		}
+
+		if n, err := goredis.Int64(c.Do(prefix+"pttl", key)); err != nil {
+			t.Fatal(err)
+		} else if n <= 2000 || n > 3000 {
+			t.Fatal(tt, n)
+		}
+
+		if n, err := goredis.Int(c.Do(prefix+"pttl", "not_exist_pttl")); err != nil || n != -1 {
+			t.Fatal(false)
+		}
+
+		if n, err := goredis.Int(c.Do(prefix+"persist", key)); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatal(n)
+		}
+	}
+
+	key := "kv_set_px"
+	if ok, err := goredis.String(c.Do("set", key, "123", "px", 5000)); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if n, err := goredis.Int64(c.Do("pttl", key)); err != nil {
+		t.Fatal(err)
+	} else if n <= 4000 || n > 5000 {
+		t.Fatal(n)
+	}
+
+	if ok, err := goredis.String(c.Do("set", key, "123", "ex", 10)); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if n, err := goredis.Int64(c.Do("ttl", key)); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatal(n)
+	}
+
+	if ok, err := goredis.String(c.Do("psetex", key, 5000, "123")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if n, err := goredis.Int64(c.Do("pttl", key)); err != nil {
+		t.Fatal(err)
+	} else if n <= 4000 || n > 5000 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("set", key, "123", "xx", 10); err == nil {
+		t.Fatal("invalid set option must fail")
+	}
+
+	if _, err := c.Do("set", key, "123", "px"); err == nil {
+		t.Fatal("invalid set param must fail")
+	}
+}
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+var (
+	errNestMulti      = errors.New("MULTI calls can not be nested")
+	errExecNoMulti    = errors.New("EXEC without MULTI")
+	errDiscardNoMulti = errors.New("DISCARD without MULTI")
+	errWatchInMulti   = errors.New("WATCH inside MULTI is not allowed")
+	errExecAbort      = errors.New("EXECABORT Transaction discarded because of previous errors.")
+)
+
+// txCommands are executed directly, not queued in MULTI.
+var txCommands = map[string]struct{}{
+	"multi":   {},
+	"exec":    {},
+	"discard": {},
+	"watch":   {},
+	"unwatch": {},
+}
+
+// txUnsupportedCommands can not be queued in MULTI, they need the write lock
+// which is held by the transaction, or can not be rolled back.
+var txUnsupportedCommands = map[string]struct{}{
+	"flushall": {},
+	"slaveof":  {},
+	"fullsync": {},
+	"sync":     {},
+	"hello":    {},
+
+	// CLIENT PAUSE waits for the write lock held by the transaction
+	"client": {},
+
+	"monitor": {},
+
+	"subscribe":    {},
+	"unsubscribe":  {},
+	"psubscribe":   {},
+	"punsubscribe": {},
+}
+
+type queuedCommand struct {
+	cmd  string
+	args [][]byte
+	f    CommandFunc
+}
+
+// multiState is the state of MULTI, the commands are queued until EXEC.
+type multiState struct {
+	cmds []queuedCommand
+
+	// some commands can not be queued, EXEC will fail
+	aborted bool
+}
+
+func isTxCommand(cmd string) bool {
+	_, ok := txCommands[cmd]
+	return ok
+}
+
+func (c *client) queueCommand(f CommandFunc) error {
+	if _, ok := txUnsupportedCommands[c.cmd]; ok {
+		return fmt.Errorf("%s is not supported in transaction", c.cmd)
+	}
+
+	c.multi.cmds = append(c.multi.cmds, queuedCommand{c.cmd, c.args, f})
+	c.resp.writeStatus(QUEUED)
+	return nil
+}
+
+func (c *client) unwatch() {
+	if c.watcher != nil {
+		c.watcher.Close()
+	}
+}
+
+func multiCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	if c.multi != nil {
+		return errNestMulti
+	}
+
+	c.multi = new(multiState)
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func discardCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	if c.multi == nil {
+		return errDiscardNoMulti
+	}
+
+	c.multi = nil
+	c.unwatch()
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func execCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	if c.multi == nil {
+		return errExecNoMulti
+	}
+
+	m := c.multi
+	c.multi = nil
+
+	defer c.unwatch()
+
+	if m.aborted {
+		return errExecAbort
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// check after begin, no one can write the watched keys now
+	if c.watcher != nil && c.watcher.Changed() {
+		tx.Rollback()
+		c.resp.writeArray(nil)
+		return nil
+	}
+
+	resp := c.resp
+	rw := new(replyWriter)
+
+	c.tx = tx
+	c.db = tx.DB
+	c.resp = rw
+
+	for _, cmd := range m.cmds {
+		c.cmd = cmd.cmd
+		c.args = cmd.args
+
+		c.app.monitors.feed(c)
+
+		// same as Redis, the error of one command does not stop others
+		if err := cmd.f(c); err != nil {
+			rw.writeError(err)
+		}
+	}
+
+	c.cmd = "exec"
+	c.args = nil
+	c.resp = resp
+	c.tx = nil
+
+	index := tx.Index()
+	err = tx.Commit()
+
+	// keep the database selected in the transaction, same as Redis
+	c.db, _ = c.ldb.Select(index)
+
+	if err != nil {
+		return err
+	}
+
+	c.resp.writeArray(rw.replies)
+	return nil
+}
+
+func watchCommand(c *client) error {
+	if len(c.args) == 0 {
+		return ErrCmdParams
+	}
+
+	if c.multi != nil {
+		return errWatchInMulti
+	}
+
+	if c.watcher == nil {
+		c.watcher = c.ldb.NewWatcher()
+	}
+
+	// the same key may be used by different data types,
+	// so watch the key of all data types
+	for _, key := range c.args {
+		for _, dataType := range []ledis.DataType{ledis.KV, ledis.LIST, ledis.HASH, ledis.SET, ledis.ZSET} {
+			if err := c.watcher.Watch(c.db, dataType, key); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+func unwatchCommand(c *client) error {
+	if len(c.args) != 0 {
+		return ErrCmdParams
+	}
+
+	c.unwatch()
+
+	c.resp.writeStatus(OK)
+	return nil
+}
+
+// replyWriter saves the replies of the commands in EXEC, and they are
+// replied as one array later.
+type replyWriter struct {
+	replies []interface{}
+}
+
+func (w *replyWriter) writeError(err error) {
+	w.replies = append(w.replies, err)
+}
+
+func (w *replyWriter) writeStatus(status string) {
+	w.replies = append(w.replies, status)
+}
+
+func (w *replyWriter) writeInteger(n int64) {
+	w.replies = append(w.replies, n)
+}
+
+func (w *replyWriter) writeBulk(b []byte) {
+	if b == nil {
+		w.replies = append(w.replies, nil)
+	} else {
+		w.replies = append(w.replies, b)
+	}
+}
+
+func (w *replyWriter) writeArray(lst []interface{}) {
+	w.replies = append(w.replies, lst)
+}
+
+func (w *replyWriter) writeSliceArray(lst [][]byte) {
+	w.replies = append(w.replies, lst)
+}
+
+func (w *replyWriter) writeFVPairArray(lst []ledis.FVPair) {
+	if lst == nil {
+		w.replies = append(w.replies, [][]byte(nil))
+		return
+	}
+
+	w.replies = append(w.replies, lst)
+}
+
+// scorePairArray keeps the score pairs in the replies, so they can be
+// written in the format of the protocol later.
+type scorePairArray struct {
+	lst        []ledis.ScorePair
+	withScores bool
+}
+
+func (w *replyWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool) {
+	if lst == nil {
+		w.replies = append(w.replies, [][]byte(nil))
+		return
+	}
+
+	w.replies = append(w.replies, scorePairArray{lst, withScores})
+}
+
+func (w *replyWriter) writeBulkFrom(n int64, r io.Reader) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		w.writeError(err)
+	} else {
+		w.writeBulk(b)
+	}
+}
+
+func (w *replyWriter) writeDouble(f float64) {
+	w.replies = append(w.replies, f)
+}
+
+func (w *replyWriter) writeBool(b bool) {
+	w.replies = append(w.replies, b)
+}
+
+func (w *replyWriter) writePush(lst []interface{}) {
+	w.writeArray(lst)
+}
+
+func (w *replyWriter) flush() {
+}
+
+func init() {
+	register("multi", multiCommand)
+	register("exec", execCommand)
+	register("discard", discardCommand)
+	register("watch", watchCommand)
+	register("unwatch", unwatchCommand)
+}
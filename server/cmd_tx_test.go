@@ -0,0 +1,177 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/siddontang/goredis"
+)
+
+func TestMulti(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("del", "tx_a", "tx_b")
+
+	if _, err := c.Do("exec"); err == nil {
+		t.Fatal("exec without multi must error")
+	}
+
+	if _, err := c.Do("discard"); err == nil {
+		t.Fatal("discard without multi must error")
+	}
+
+	if ok, err := goredis.String(c.Do("multi")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if _, err := c.Do("multi"); err == nil {
+		t.Fatal("nested multi must error")
+	}
+
+	if s, err := goredis.String(c.Do("incr", "tx_a")); err != nil {
+		t.Fatal(err)
+	} else if s != QUEUED {
+		t.Fatal(s)
+	}
+
+	c.Do("incr", "tx_a")
+	c.Do("rpush", "tx_b", "1", "2")
+	c.Do("lrange", "tx_b", 0, -1)
+	c.Do("hget", "tx_a", "f")
+
+	if v, err := goredis.Values(c.Do("exec")); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 5 {
+		t.Fatal(v)
+	} else if n, _ := goredis.Int(v[1], nil); n != 2 {
+		t.Fatal(v[1])
+	} else if n, _ := goredis.Int(v[2], nil); n != 2 {
+		t.Fatal(v[2])
+	} else if l, _ := goredis.Strings(v[3], nil); len(l) != 2 || l[1] != "2" {
+		t.Fatal(v[3])
+	} else if v[4] != nil {
+		t.Fatal(v[4])
+	}
+
+	// discard
+	c.Do("multi")
+	c.Do("incr", "tx_a")
+	if ok, err := goredis.String(c.Do("discard")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	if n, err := goredis.Int(c.Do("get", "tx_a")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	// abort with invalid command
+	c.Do("multi")
+	c.Do("incr", "tx_a")
+	if _, err := c.Do("unknown_cmd"); err == nil {
+		t.Fatal("must error")
+	}
+	if _, err := c.Do("exec"); err == nil {
+		t.Fatal("exec must abort")
+	}
+
+	if n, err := goredis.Int(c.Do("get", "tx_a")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	// select in multi
+	c.Do("multi")
+	c.Do("select", 1)
+	c.Do("set", "tx_a", "db1")
+	if _, err := c.Do("exec"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := goredis.String(c.Do("get", "tx_a")); err != nil {
+		t.Fatal(err)
+	} else if v != "db1" {
+		t.Fatal(v)
+	}
+
+	c.Do("del", "tx_a")
+	c.Do("select", 0)
+}
+
+func TestWatch(t *testing.T) {
+	c1 := getTestConn()
+	defer c1.Close()
+
+	c2 := getTestConn()
+	defer c2.Close()
+
+	c1.Do("set", "tx_w", "1")
+
+	if ok, err := goredis.String(c1.Do("watch", "tx_w")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	c1.Do("multi")
+	if _, err := c1.Do("watch", "tx_w"); err == nil {
+		t.Fatal("watch in multi must error")
+	}
+	c1.Do("incr", "tx_w")
+
+	c2.Do("set", "tx_w", "10")
+
+	if v, err := c1.Do("exec"); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c1.Do("get", "tx_w")); err != nil {
+		t.Fatal(err)
+	} else if n != 10 {
+		t.Fatal(n)
+	}
+
+	// exec unwatches all keys
+	c1.Do("multi")
+	c1.Do("incr", "tx_w")
+	c2.Do("set", "tx_w", "20")
+	if v, err := goredis.Values(c1.Do("exec")); err != nil {
+		t.Fatal(err)
+	} else if n, _ := goredis.Int(v[0], nil); n != 21 {
+		t.Fatal(v)
+	}
+
+	// modify other data type of the key
+	c1.Do("watch", "tx_w")
+	c2.Do("hset", "tx_w", "f", "v")
+	c1.Do("multi")
+	c1.Do("incr", "tx_w")
+	if v, err := c1.Do("exec"); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	// unwatch
+	c1.Do("watch", "tx_w")
+	c1.Do("unwatch")
+	c2.Do("set", "tx_w", "1")
+	c1.Do("multi")
+	c1.Do("incr", "tx_w")
+	if v, err := goredis.Values(c1.Do("exec")); err != nil {
+		t.Fatal(err)
+	} else if n, _ := goredis.Int(v[0], nil); n != 2 {
+		t.Fatal(v)
+	}
+
+	c1.Do("del", "tx_w")
+	c1.Do("hclear", "tx_w")
+}
@@ -1,21 +1,15 @@
 package server
 
 import (
-	"errors"
 	"math"
 	"strconv"
 	"strings"
 
 	"github.com/siddontang/go/hack"
-	"github.com/siddontang/go/num"
 	"github.com/siddontang/ledisdb/ledis"
 	"github.com/siddontang/ledisdb/store"
 )
 
-//for simple implementation, we only support int64 score
-
-var errScoreOverflow = errors.New("zset score overflow")
-
 func zaddCommand(c *client) error {
 	args := c.args
 	if len(args) < 3 {
@@ -31,9 +25,9 @@ func zaddCommand(c *client) error {
 
 	params := make([]ledis.ScorePair, len(args)>>1)
 	for i := 0; i < len(params); i++ {
-		score, err := ledis.StrInt64(args[2*i], nil)
+		score, err := zparseScore(args[2*i])
 		if err != nil {
-			return ErrValue
+			return err
 		}
 
 		params[i].Score = score
@@ -77,7 +71,7 @@ func zscoreCommand(c *client) error {
 			return err
 		}
 	} else {
-		c.resp.writeBulk(num.FormatInt64ToSlice(s))
+		c.resp.writeDouble(s)
 	}
 
 	return nil
@@ -106,85 +100,77 @@ func zincrbyCommand(c *client) error {
 
 	key := args[0]
 
-	delta, err := ledis.StrInt64(args[1], nil)
+	delta, err := zparseScore(args[1])
 	if err != nil {
-		return ErrValue
+		return err
 	}
 
 	v, err := c.db.ZIncrBy(key, delta, args[2])
 
 	if err == nil {
-		c.resp.writeBulk(num.FormatInt64ToSlice(v))
+		c.resp.writeDouble(v)
 	}
 
 	return err
 }
 
-func zparseScoreRange(minBuf []byte, maxBuf []byte) (min int64, max int64, err error) {
-	if strings.ToLower(hack.String(minBuf)) == "-inf" {
-		min = math.MinInt64
-	} else {
-
-		if len(minBuf) == 0 {
-			err = ErrCmdParams
-			return
-		}
-
-		var lopen bool = false
-		if minBuf[0] == '(' {
-			lopen = true
-			minBuf = minBuf[1:]
-		}
-
-		min, err = ledis.StrInt64(minBuf, nil)
-		if err != nil {
-			err = ErrValue
-			return
-		}
+// zparseScore parses the score like Redis, -inf, +inf and inf are supported
+// but NaN is not.
+func zparseScore(buf []byte) (float64, error) {
+	score, err := ledis.StrFloat64(buf, nil)
+	if err != nil || math.IsNaN(score) {
+		return 0, ErrValue
+	}
+	return score, nil
+}
 
-		if min <= ledis.MinScore || min >= ledis.MaxScore {
-			err = errScoreOverflow
-			return
-		}
+// zformatScore formats the score like Redis, and uses inf and -inf for the infinities.
+func zformatScore(score float64) []byte {
+	return ledis.AppendScore(nil, score)
+}
 
-		if lopen {
-			min++
-		}
+// zparseScoreRange parses the min and max score, an exclusive bound with
+// the ( prefix is changed to the next float64 inside the range, so the
+// returned range is always inclusive. If min > max, the range is empty.
+func zparseScoreRange(minBuf []byte, maxBuf []byte) (min float64, max float64, err error) {
+	if len(minBuf) == 0 || len(maxBuf) == 0 {
+		err = ErrCmdParams
+		return
 	}
 
-	if strings.ToLower(hack.String(maxBuf)) == "+inf" {
-		max = math.MaxInt64
-	} else {
-		var ropen = false
+	var lopen, ropen bool
+	if minBuf[0] == '(' {
+		lopen = true
+		minBuf = minBuf[1:]
+	}
 
-		if len(maxBuf) == 0 {
-			err = ErrCmdParams
-			return
-		}
-		if maxBuf[0] == '(' {
-			ropen = true
-			maxBuf = maxBuf[1:]
-		}
+	if maxBuf[0] == '(' {
+		ropen = true
+		maxBuf = maxBuf[1:]
+	}
 
-		if maxBuf[0] == '(' {
-			ropen = true
-			maxBuf = maxBuf[1:]
-		}
+	if min, err = zparseScore(minBuf); err != nil {
+		return
+	}
 
-		max, err = ledis.StrInt64(maxBuf, nil)
-		if err != nil {
-			err = ErrValue
-			return
-		}
+	if max, err = zparseScore(maxBuf); err != nil {
+		return
+	}
 
-		if max <= ledis.MinScore || max >= ledis.MaxScore {
-			err = errScoreOverflow
-			return
+	if lopen {
+		if math.IsInf(min, 1) {
+			// nothing is greater than +inf
+			return ledis.MaxScore, ledis.MinScore, nil
 		}
+		min = math.Nextafter(min, ledis.MaxScore)
+	}
 
-		if ropen {
-			max--
+	if ropen {
+		if math.IsInf(max, -1) {
+			// nothing is less than -inf
+			return ledis.MaxScore, ledis.MinScore, nil
 		}
+		max = math.Nextafter(max, ledis.MinScore)
 	}
 
 	return
@@ -510,6 +496,61 @@ func zttlCommand(c *client) error {
 	return nil
 }
 
+func zpexpireCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	duration, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	v, err := c.db.ZPExpire(args[0], duration)
+
+	if err == nil {
+		c.resp.writeInteger(v)
+	}
+
+	return err
+}
+
+func zpexpireAtCommand(c *client) error {
+	args := c.args
+	if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	when, err := ledis.StrInt64(args[1], nil)
+	if err != nil {
+		return ErrValue
+	}
+
+	v, err := c.db.ZPExpireAt(args[0], when)
+
+	if err == nil {
+		c.resp.writeInteger(v)
+	}
+
+	return err
+}
+
+func zpttlCommand(c *client) error {
+	args := c.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	if v, err := c.db.ZPTTL(args[0]); err != nil {
+		return err
+	} else {
+		c.resp.writeInteger(v)
+	}
+
+	return nil
+}
+
 func zpersistCommand(c *client) error {
 	args := c.args
 	if len(args) != 1 {
@@ -525,7 +566,7 @@ func zpersistCommand(c *client) error {
 	return err
 }
 
-func zparseZsetoptStore(args [][]byte) (destKey []byte, srcKeys [][]byte, weights []int64, aggregate byte, err error) {
+func zparseZsetoptStore(args [][]byte) (destKey []byte, srcKeys [][]byte, weights []float64, aggregate byte, err error) {
 	destKey = args[0]
 	nKeys, err := strconv.Atoi(hack.String(args[1]))
 	if err != nil {
@@ -558,10 +599,9 @@ func zparseZsetoptStore(args [][]byte) (destKey []byte, srcKeys [][]byte, weight
 				return
 			}
 
-			weights = make([]int64, nKeys)
+			weights = make([]float64, nKeys)
 			for i, arg := range args[:nKeys] {
-				if weights[i], err = ledis.StrInt64(arg, nil); err != nil {
-					err = ErrValue
+				if weights[i], err = zparseScore(arg); err != nil {
 					return
 				}
 			}
@@ -807,6 +847,9 @@ func init() {
 	register("zexpire", zexpireCommand)
 	register("zexpireat", zexpireAtCommand)
 	register("zttl", zttlCommand)
+	register("zpexpire", zpexpireCommand)
+	register("zpexpireat", zpexpireAtCommand)
+	register("zpttl", zpttlCommand)
 	register("zpersist", zpersistCommand)
 	register("zkeyexists", zkeyexistsCommand)
 }
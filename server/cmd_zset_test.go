@@ -462,7 +462,7 @@ func TestZsetErrorParams(t *testing.T) {
 		t.Fatalf("invalid err of %v", err)
 	}
 
-	if _, err := c.Do("zadd", "test_zad", "0.1", "a"); err == nil {
+	if _, err := c.Do("zadd", "test_zad", "nan", "a"); err == nil {
 		t.Fatalf("invalid err of %v", err)
 	}
 
@@ -486,7 +486,7 @@ func TestZsetErrorParams(t *testing.T) {
 		t.Fatalf("invalid err of %v", err)
 	}
 
-	if _, err := c.Do("zincrby", "test_zincrby", 0.1, "a"); err == nil {
+	if _, err := c.Do("zincrby", "test_zincrby", "nan", "a"); err == nil {
 		t.Fatalf("invalid err of %v", err)
 	}
 
@@ -499,7 +499,7 @@ func TestZsetErrorParams(t *testing.T) {
 		t.Fatalf("invalid err of %v", err)
 	}
 
-	if _, err := c.Do("zcount", "test_zcount", 0.1, 0.1); err == nil {
+	if _, err := c.Do("zcount", "test_zcount", "nan", "nan"); err == nil {
 		t.Fatalf("invalid err of %v", err)
 	}
 
@@ -800,3 +800,78 @@ func TestZSetLex(t *testing.T) {
 	}
 
 }
+
+func TestZSetFloatScore(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	key := []byte("myzset_float")
+	c.Do("zclear", key)
+
+	if n, err := goredis.Int(c.Do("zadd", key, "1.5", "a", "-0.5", "b", "inf", "c", "-inf", "d", "2", "e")); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatal(n)
+	}
+
+	if s, err := goredis.String(c.Do("zscore", key, "a")); err != nil {
+		t.Fatal(err)
+	} else if s != "1.5" {
+		t.Fatal(s)
+	}
+
+	if s, err := goredis.String(c.Do("zscore", key, "c")); err != nil {
+		t.Fatal(err)
+	} else if s != "inf" {
+		t.Fatal(s)
+	}
+
+	if s, err := goredis.String(c.Do("zincrby", key, "0.25", "b")); err != nil {
+		t.Fatal(err)
+	} else if s != "-0.25" {
+		t.Fatal(s)
+	}
+
+	if v, err := goredis.Strings(c.Do("zrangebyscore", key, "(-inf", "(2")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"b", "a"}) {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("zrangebyscore", key, "(1.5", "+inf", "withscores")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"e", "2", "c", "inf"}) {
+		t.Fatal(v)
+	}
+
+	if v, err := goredis.Strings(c.Do("zrevrangebyscore", key, "(inf", "-inf")); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(v, []string{"e", "a", "b", "d"}) {
+		t.Fatal(v)
+	}
+
+	if n, err := goredis.Int(c.Do("zcount", key, "(inf", "+inf")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	dest := []byte("myzset_float_dest")
+	if n, err := goredis.Int(c.Do("zunionstore", dest, 2, key, key, "weights", "0.5", "1.5", "aggregate", "max")); err != nil {
+		t.Fatal(err)
+	} else if n != 5 {
+		t.Fatal(n)
+	}
+
+	if s, err := goredis.String(c.Do("zscore", dest, "a")); err != nil {
+		t.Fatal(err)
+	} else if s != "2.25" {
+		t.Fatal(s)
+	}
+
+	if s, err := goredis.String(c.Do("zscore", dest, "b")); err != nil {
+		t.Fatal(err)
+	} else if s != "-0.125" {
+		t.Fatal(s)
+	}
+}
@@ -1,18 +1,384 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/siddontang/go/hack"
 )
 
 type CommandFunc func(c *client) error
 
-var regCmds = map[string]CommandFunc{}
+// the command flags
+const (
+	// cmdWrite commands change the data, they are rejected in the readonly mode
+	cmdWrite = 1 << iota
+	// cmdReadonly commands only read the data
+	cmdReadonly
+	// cmdAdmin commands manage the server
+	cmdAdmin
+	// cmdBlocking commands may block the client
+	cmdBlocking
+	// cmdNoScript commands use the state of the connection, they can not be
+	// called by the scripts and the HTTP clients
+	cmdNoScript
+)
+
+var commandFlagNames = []struct {
+	flag int
+	name string
+}{
+	{cmdWrite, "write"},
+	{cmdReadonly, "readonly"},
+	{cmdAdmin, "admin"},
+	{cmdBlocking, "blocking"},
+	{cmdNoScript, "noscript"},
+}
+
+func commandFlag(name string) int {
+	for _, f := range commandFlagNames {
+		if f.name == name {
+			return f.flag
+		}
+	}
+	return 0
+}
+
+// keySpec is the positions of the keys in the command args like Redis,
+// 1 is the first arg after the command name, and a negative last position
+// counts from the end.
+type keySpec struct {
+	first int
+	last  int
+	step  int
+}
+
+var (
+	noKeys    = keySpec{}
+	oneKey    = keySpec{1, 1, 1}
+	secondKey = keySpec{2, 2, 1}
+	allKeys   = keySpec{1, -1, 1}
+)
+
+func (s keySpec) keys(args [][]byte) [][]byte {
+	if s.first <= 0 {
+		return nil
+	}
+
+	last := s.last
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+
+	var keys [][]byte
+	for i := s.first; i <= last && i <= len(args); i += s.step {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+// numKeys returns the keys after the number of keys at the position pos,
+// like EVAL script numkeys key... and ZUNIONSTORE dest numkeys key...
+func numKeys(pos int, prefix keySpec) func(args [][]byte) [][]byte {
+	return func(args [][]byte) [][]byte {
+		keys := prefix.keys(args)
+		if len(args) < pos {
+			return keys
+		}
+
+		n, err := strconv.Atoi(hack.String(args[pos-1]))
+		if err != nil || n < 0 || pos+n > len(args) {
+			return keys
+		}
+		return append(keys, args[pos:pos+n]...)
+	}
+}
+
+// sortKeys returns the key and the STORE destination of XLSORT, XSSORT and XZSORT.
+func sortKeys(args [][]byte) [][]byte {
+	keys := oneKey.keys(args)
+	for i := 1; i < len(args)-1; i++ {
+		if bytes.EqualFold(args[i], storeArg) {
+			keys = append(keys, args[i+1])
+		}
+	}
+	return keys
+}
+
+// commandInfo is the metadata of a command.
+type commandInfo struct {
+	// arity is the number of the args including the command name like Redis,
+	// -n means at least n args.
+	arity int
+
+	flags int
+
+	// the ACL category, the commands without category can be used by all the users
+	category string
+
+	keys keySpec
+
+	// getKeys returns the keys which can not be described by keySpec
+	getKeys func(args [][]byte) [][]byte
+}
+
+type command struct {
+	name string
+	commandInfo
+	f CommandFunc
+}
+
+func (cmd *command) checkArity(args [][]byte) bool {
+	n := len(args) + 1
+	if cmd.arity < 0 {
+		return n >= -cmd.arity
+	}
+	return n == cmd.arity
+}
+
+func (cmd *command) flagNames() []string {
+	var names []string
+	for _, f := range commandFlagNames {
+		if cmd.flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	if cmd.getKeys != nil {
+		names = append(names, "movablekeys")
+	}
+	return names
+}
+
+// commandKeys returns the keys accessed by the command.
+func (cmd *command) commandKeys(args [][]byte) [][]byte {
+	if cmd.getKeys != nil {
+		return cmd.getKeys(args)
+	}
+	return cmd.keys.keys(args)
+}
+
+var regCmds = map[string]*command{}
 
 func register(name string, f CommandFunc) {
 	if _, ok := regCmds[strings.ToLower(name)]; ok {
 		panic(fmt.Sprintf("%s has been registered", name))
 	}
 
-	regCmds[name] = f
+	info, ok := commandTable[name]
+	if !ok {
+		panic(fmt.Sprintf("%s has no command info", name))
+	}
+
+	regCmds[name] = &command{name, info, f}
+}
+
+// commandTable is the metadata of all the commands.
+var commandTable = map[string]commandInfo{
+	// kv
+	"append":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"bitcount":  {-2, cmdReadonly, aclRead, oneKey, nil},
+	"bitop":     {-4, cmdWrite, aclWrite, keySpec{2, -1, 1}, nil},
+	"bitpos":    {-3, cmdReadonly, aclRead, oneKey, nil},
+	"decr":      {2, cmdWrite, aclWrite, oneKey, nil},
+	"decrby":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"del":       {-2, cmdWrite, aclWrite, allKeys, nil},
+	"exists":    {-2, cmdReadonly, aclRead, allKeys, nil},
+	"get":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"getbit":    {3, cmdReadonly, aclRead, oneKey, nil},
+	"getrange":  {4, cmdReadonly, aclRead, oneKey, nil},
+	"getset":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"incr":      {2, cmdWrite, aclWrite, oneKey, nil},
+	"incrby":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"mget":      {-2, cmdReadonly, aclRead, allKeys, nil},
+	"mset":      {-3, cmdWrite, aclWrite, keySpec{1, -1, 2}, nil},
+	"set":       {-3, cmdWrite, aclWrite, oneKey, nil},
+	"setbit":    {4, cmdWrite, aclWrite, oneKey, nil},
+	"setnx":     {3, cmdWrite, aclWrite, oneKey, nil},
+	"setex":     {4, cmdWrite, aclWrite, oneKey, nil},
+	"psetex":    {4, cmdWrite, aclWrite, oneKey, nil},
+	"setrange":  {4, cmdWrite, aclWrite, oneKey, nil},
+	"strlen":    {2, cmdReadonly, aclRead, oneKey, nil},
+	"expire":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"expireat":  {3, cmdWrite, aclWrite, oneKey, nil},
+	"ttl":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"pexpire":   {3, cmdWrite, aclWrite, oneKey, nil},
+	"pexpireat": {3, cmdWrite, aclWrite, oneKey, nil},
+	"pttl":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"persist":   {2, cmdWrite, aclWrite, oneKey, nil},
+	"type":      {2, cmdReadonly, aclRead, oneKey, nil},
+
+	// hash
+	"hdel":       {-3, cmdWrite, aclWrite, oneKey, nil},
+	"hexists":    {3, cmdReadonly, aclRead, oneKey, nil},
+	"hget":       {3, cmdReadonly, aclRead, oneKey, nil},
+	"hgetall":    {2, cmdReadonly, aclRead, oneKey, nil},
+	"hincrby":    {4, cmdWrite, aclWrite, oneKey, nil},
+	"hkeys":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"hlen":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"hmget":      {-3, cmdReadonly, aclRead, oneKey, nil},
+	"hmset":      {-4, cmdWrite, aclWrite, oneKey, nil},
+	"hset":       {4, cmdWrite, aclWrite, oneKey, nil},
+	"hvals":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"hclear":     {2, cmdWrite, aclWrite, oneKey, nil},
+	"hmclear":    {-2, cmdWrite, aclWrite, allKeys, nil},
+	"hexpire":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"hexpireat":  {3, cmdWrite, aclWrite, oneKey, nil},
+	"httl":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"hpexpire":   {3, cmdWrite, aclWrite, oneKey, nil},
+	"hpexpireat": {3, cmdWrite, aclWrite, oneKey, nil},
+	"hpttl":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"hpersist":   {2, cmdWrite, aclWrite, oneKey, nil},
+	"hkeyexists": {2, cmdReadonly, aclRead, oneKey, nil},
+
+	// list
+	"blpop":       {-3, cmdWrite | cmdBlocking, aclWrite, keySpec{1, -2, 1}, nil},
+	"brpop":       {-3, cmdWrite | cmdBlocking, aclWrite, keySpec{1, -2, 1}, nil},
+	"brpoplpush":  {4, cmdWrite | cmdBlocking, aclWrite, keySpec{1, 2, 1}, nil},
+	"rpoplpush":   {3, cmdWrite, aclWrite, keySpec{1, 2, 1}, nil},
+	"lindex":      {3, cmdReadonly, aclRead, oneKey, nil},
+	"llen":        {2, cmdReadonly, aclRead, oneKey, nil},
+	"lpop":        {2, cmdWrite, aclWrite, oneKey, nil},
+	"lrange":      {4, cmdReadonly, aclRead, oneKey, nil},
+	"lpush":       {-3, cmdWrite, aclWrite, oneKey, nil},
+	"rpop":        {2, cmdWrite, aclWrite, oneKey, nil},
+	"rpush":       {-3, cmdWrite, aclWrite, oneKey, nil},
+	"lclear":      {2, cmdWrite, aclWrite, oneKey, nil},
+	"lmclear":     {-2, cmdWrite, aclWrite, allKeys, nil},
+	"lexpire":     {3, cmdWrite, aclWrite, oneKey, nil},
+	"lexpireat":   {3, cmdWrite, aclWrite, oneKey, nil},
+	"lttl":        {2, cmdReadonly, aclRead, oneKey, nil},
+	"lpexpire":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"lpexpireat":  {3, cmdWrite, aclWrite, oneKey, nil},
+	"lpttl":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"lpersist":    {2, cmdWrite, aclWrite, oneKey, nil},
+	"lkeyexists":  {2, cmdReadonly, aclRead, oneKey, nil},
+	"ltrim_front": {3, cmdWrite, aclWrite, oneKey, nil},
+	"ltrim_back":  {3, cmdWrite, aclWrite, oneKey, nil},
+	"ltrim":       {4, cmdWrite, aclWrite, oneKey, nil},
+
+	// set
+	"sadd":        {-3, cmdWrite, aclWrite, oneKey, nil},
+	"scard":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"sdiff":       {-2, cmdReadonly, aclRead, allKeys, nil},
+	"sdiffstore":  {-3, cmdWrite, aclWrite, allKeys, nil},
+	"sinter":      {-2, cmdReadonly, aclRead, allKeys, nil},
+	"sinterstore": {-3, cmdWrite, aclWrite, allKeys, nil},
+	"sismember":   {3, cmdReadonly, aclRead, oneKey, nil},
+	"smembers":    {2, cmdReadonly, aclRead, oneKey, nil},
+	"srem":        {-3, cmdWrite, aclWrite, oneKey, nil},
+	"sunion":      {-2, cmdReadonly, aclRead, allKeys, nil},
+	"sunionstore": {-3, cmdWrite, aclWrite, allKeys, nil},
+	"sclear":      {2, cmdWrite, aclWrite, oneKey, nil},
+	"smclear":     {-2, cmdWrite, aclWrite, allKeys, nil},
+	"sexpire":     {3, cmdWrite, aclWrite, oneKey, nil},
+	"sexpireat":   {3, cmdWrite, aclWrite, oneKey, nil},
+	"sttl":        {2, cmdReadonly, aclRead, oneKey, nil},
+	"spexpire":    {3, cmdWrite, aclWrite, oneKey, nil},
+	"spexpireat":  {3, cmdWrite, aclWrite, oneKey, nil},
+	"spttl":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"spersist":    {2, cmdWrite, aclWrite, oneKey, nil},
+	"skeyexists":  {2, cmdReadonly, aclRead, oneKey, nil},
+
+	// zset
+	"zadd":             {-4, cmdWrite, aclWrite, oneKey, nil},
+	"zcard":            {2, cmdReadonly, aclRead, oneKey, nil},
+	"zcount":           {4, cmdReadonly, aclRead, oneKey, nil},
+	"zincrby":          {4, cmdWrite, aclWrite, oneKey, nil},
+	"zrange":           {-4, cmdReadonly, aclRead, oneKey, nil},
+	"zrangebyscore":    {-4, cmdReadonly, aclRead, oneKey, nil},
+	"zrank":            {3, cmdReadonly, aclRead, oneKey, nil},
+	"zrem":             {-3, cmdWrite, aclWrite, oneKey, nil},
+	"zremrangebyrank":  {4, cmdWrite, aclWrite, oneKey, nil},
+	"zremrangebyscore": {4, cmdWrite, aclWrite, oneKey, nil},
+	"zrevrange":        {-4, cmdReadonly, aclRead, oneKey, nil},
+	"zrevrank":         {3, cmdReadonly, aclRead, oneKey, nil},
+	"zrevrangebyscore": {-4, cmdReadonly, aclRead, oneKey, nil},
+	"zscore":           {3, cmdReadonly, aclRead, oneKey, nil},
+	"zunionstore":      {-3, cmdWrite, aclWrite, noKeys, numKeys(2, oneKey)},
+	"zinterstore":      {-3, cmdWrite, aclWrite, noKeys, numKeys(2, oneKey)},
+	"zrangebylex":      {-4, cmdReadonly, aclRead, oneKey, nil},
+	"zremrangebylex":   {4, cmdWrite, aclWrite, oneKey, nil},
+	"zlexcount":        {4, cmdReadonly, aclRead, oneKey, nil},
+	"zclear":           {2, cmdWrite, aclWrite, oneKey, nil},
+	"zmclear":          {-2, cmdWrite, aclWrite, allKeys, nil},
+	"zexpire":          {3, cmdWrite, aclWrite, oneKey, nil},
+	"zexpireat":        {3, cmdWrite, aclWrite, oneKey, nil},
+	"zttl":             {2, cmdReadonly, aclRead, oneKey, nil},
+	"zpexpire":         {3, cmdWrite, aclWrite, oneKey, nil},
+	"zpexpireat":       {3, cmdWrite, aclWrite, oneKey, nil},
+	"zpttl":            {2, cmdReadonly, aclRead, oneKey, nil},
+	"zpersist":         {2, cmdWrite, aclWrite, oneKey, nil},
+	"zkeyexists":       {2, cmdReadonly, aclRead, oneKey, nil},
+
+	// scan
+	"hscan":  {-3, cmdReadonly, aclRead, oneKey, nil},
+	"sscan":  {-3, cmdReadonly, aclRead, oneKey, nil},
+	"zscan":  {-3, cmdReadonly, aclRead, oneKey, nil},
+	"xscan":  {-3, cmdReadonly, aclRead, noKeys, nil},
+	"xhscan": {-3, cmdReadonly, aclRead, oneKey, nil},
+	"xsscan": {-3, cmdReadonly, aclRead, oneKey, nil},
+	"xzscan": {-3, cmdReadonly, aclRead, oneKey, nil},
+
+	// sort, only reads the data without STORE
+	"xlsort": {-2, 0, aclRead, noKeys, sortKeys},
+	"xssort": {-2, 0, aclRead, noKeys, sortKeys},
+	"xzsort": {-2, 0, aclRead, noKeys, sortKeys},
+
+	// migrate
+	"dump":       {2, cmdReadonly, aclRead, oneKey, nil},
+	"ldump":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"hdump":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"sdump":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"zdump":      {2, cmdReadonly, aclRead, oneKey, nil},
+	"xdump":      {3, cmdReadonly, aclRead, secondKey, nil},
+	"restore":    {4, cmdWrite, aclWrite, oneKey, nil},
+	"xrestore":   {5, cmdWrite, aclWrite, secondKey, nil},
+	"xmigrate":   {7, cmdWrite | cmdAdmin, aclAdmin, keySpec{4, 4, 1}, nil},
+	"xmigratedb": {7, cmdWrite | cmdAdmin, aclAdmin, noKeys, nil},
+
+	// pubsub, PUBLISH can be used in the readonly mode like Redis
+	"subscribe":    {-2, cmdNoScript, aclRead, noKeys, nil},
+	"unsubscribe":  {-1, cmdNoScript, aclRead, noKeys, nil},
+	"psubscribe":   {-2, cmdNoScript, aclRead, noKeys, nil},
+	"punsubscribe": {-1, cmdNoScript, aclRead, noKeys, nil},
+	"publish":      {3, 0, aclWrite, noKeys, nil},
+	"pubsub":       {-2, 0, aclRead, noKeys, nil},
+
+	// transaction
+	"multi":   {1, cmdNoScript, "", noKeys, nil},
+	"exec":    {1, cmdNoScript, "", noKeys, nil},
+	"discard": {1, cmdNoScript, "", noKeys, nil},
+	"watch":   {-2, cmdNoScript, aclRead, allKeys, nil},
+	"unwatch": {1, cmdNoScript, "", noKeys, nil},
+
+	// script
+	"eval":    {-3, 0, aclScripting, noKeys, numKeys(2, noKeys)},
+	"evalsha": {-3, 0, aclScripting, noKeys, numKeys(2, noKeys)},
+	"script":  {-2, 0, aclScripting, noKeys, nil},
+
+	// replication
+	"slaveof":  {-3, cmdAdmin | cmdNoScript, aclReplication, noKeys, nil},
+	"fullsync": {-1, cmdAdmin | cmdNoScript, aclReplication, noKeys, nil},
+	"sync":     {2, cmdAdmin | cmdNoScript, aclReplication, noKeys, nil},
+	"replconf": {-1, cmdAdmin, aclReplication, noKeys, nil},
+	"role":     {1, 0, aclReplication, noKeys, nil},
+
+	// server, FLUSHALL is not a write command, it can be used in the readonly mode
+	"auth":     {-2, 0, "", noKeys, nil},
+	"hello":    {-1, cmdNoScript, "", noKeys, nil},
+	"ping":     {-1, 0, "", noKeys, nil},
+	"echo":     {2, 0, "", noKeys, nil},
+	"select":   {2, 0, "", noKeys, nil},
+	"command":  {-1, 0, "", noKeys, nil},
+	"info":     {-1, 0, aclAdmin, noKeys, nil},
+	"time":     {1, 0, aclRead, noKeys, nil},
+	"flushall": {-1, cmdAdmin, aclAdmin, noKeys, nil},
+	"flushdb":  {-1, cmdWrite | cmdAdmin, aclAdmin, noKeys, nil},
+	"config":   {-2, cmdAdmin, aclAdmin, noKeys, nil},
+	"monitor":  {1, cmdAdmin | cmdNoScript, aclAdmin, noKeys, nil},
+	"slowlog":  {-2, cmdAdmin, aclAdmin, noKeys, nil},
+	"client":   {-2, cmdAdmin, aclAdmin, noKeys, nil},
+	"acl":      {-2, cmdAdmin, aclAdmin, noKeys, nil},
 }
@@ -27,6 +27,8 @@ var (
 	PONG  = "PONG"
 	OK    = "OK"
 	NOKEY = "NOKEY"
+
+	QUEUED = "QUEUED"
 )
 
 const (
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+var (
+	errCommandName     = errors.New("invalid command name")
+	errCommandArity    = errors.New("invalid command arity")
+	errCommandCategory = errors.New("invalid command category")
+	errCommandKeys     = errors.New("invalid command key positions")
+)
+
+// ResponseWriter writes the reply of the command in the protocol of the
+// client, RESP or HTTP.
+type ResponseWriter interface {
+	WriteError(error)
+	WriteStatus(string)
+	WriteInteger(int64)
+	WriteBulk([]byte)
+	// WriteArray writes the array, the elements can be []byte, string as
+	// the status, int64, float64, bool, error, nil and []interface{}.
+	WriteArray([]interface{})
+	WriteSliceArray([][]byte)
+}
+
+// clientWriter writes to the current writer of the client, which is
+// changed by HELLO and in EXEC.
+type clientWriter struct {
+	c *client
+}
+
+func (w clientWriter) WriteError(err error)        { w.c.resp.writeError(err) }
+func (w clientWriter) WriteStatus(s string)        { w.c.resp.writeStatus(s) }
+func (w clientWriter) WriteInteger(n int64)        { w.c.resp.writeInteger(n) }
+func (w clientWriter) WriteBulk(b []byte)          { w.c.resp.writeBulk(b) }
+func (w clientWriter) WriteArray(ay []interface{}) { w.c.resp.writeArray(ay) }
+func (w clientWriter) WriteSliceArray(ay [][]byte) { w.c.resp.writeSliceArray(ay) }
+
+// ClientInfo is the information of the client running the command.
+type ClientInfo struct {
+	ID   int64
+	Addr string
+	Name string
+	// User is the ACL user, default if not authenticated as other users
+	User   string
+	Authed bool
+}
+
+// CommandContext is the command being performed by a client, it is only
+// valid until the command returns.
+type CommandContext struct {
+	c *client
+}
+
+func (ctx *CommandContext) App() *App {
+	return ctx.c.app
+}
+
+// Name returns the command name in lowercase.
+func (ctx *CommandContext) Name() string {
+	return ctx.c.cmd
+}
+
+// Args returns the args after the command name.
+func (ctx *CommandContext) Args() [][]byte {
+	return ctx.c.args
+}
+
+// Rewrite changes the command name and the args, an interceptor can call
+// it before the next one.
+func (ctx *CommandContext) Rewrite(name string, args [][]byte) {
+	ctx.c.cmd = strings.ToLower(name)
+	ctx.c.args = args
+}
+
+// DB returns the selected database, the writes are in the transaction in EXEC.
+func (ctx *CommandContext) DB() *ledis.DB {
+	return ctx.c.db
+}
+
+func (ctx *CommandContext) Writer() ResponseWriter {
+	return clientWriter{ctx.c}
+}
+
+func (ctx *CommandContext) Client() ClientInfo {
+	return ClientInfo{
+		ID:     ctx.c.id,
+		Addr:   ctx.c.remoteAddr,
+		Name:   ctx.c.getStat().name,
+		User:   ctx.c.user,
+		Authed: ctx.c.isAuthed,
+	}
+}
+
+// CommandHandler executes the command registered by RegisterCommand, the
+// returned error is the reply.
+type CommandHandler func(ctx *CommandContext) error
+
+// CommandInfo is the metadata of the command registered by RegisterCommand,
+// same as COMMAND INFO.
+type CommandInfo struct {
+	// Arity is the number of the args including the command name,
+	// -n means at least n args.
+	Arity int
+
+	// Flags are write, readonly, admin, blocking and noscript.
+	Flags []string
+
+	// Category is the ACL category, the command can be used by all the
+	// users if empty.
+	Category string
+
+	// FirstKey, LastKey and KeyStep are the positions of the keys,
+	// 1 is the first arg after the command name, 0 if no keys.
+	FirstKey int
+	LastKey  int
+	KeyStep  int
+
+	// Keys returns the keys which are not at the fixed positions, optional.
+	Keys func(args [][]byte) [][]byte
+}
+
+// RegisterCommand adds a command to all the apps, it must be called before
+// NewApp. The existing commands can not be replaced, use an interceptor to
+// wrap them.
+func RegisterCommand(name string, info CommandInfo, h CommandHandler) error {
+	if len(name) == 0 || strings.ContainsAny(name, " \r\n") {
+		return errCommandName
+	}
+
+	name = strings.ToLower(name)
+	if _, ok := regCmds[name]; ok {
+		return fmt.Errorf("%s has been registered", name)
+	}
+
+	if info.Arity == 0 {
+		return errCommandArity
+	} else if len(info.Category) > 0 && !isCategory(info.Category) {
+		return errCommandCategory
+	} else if info.FirstKey < 0 || (info.FirstKey > 0 && info.KeyStep <= 0) {
+		return errCommandKeys
+	}
+
+	var flags int
+	for _, s := range info.Flags {
+		f := commandFlag(strings.ToLower(s))
+		if f == 0 {
+			return fmt.Errorf("invalid command flag %s", s)
+		}
+		flags |= f
+	}
+
+	regCmds[name] = &command{
+		name: name,
+		commandInfo: commandInfo{
+			arity:    info.Arity,
+			flags:    flags,
+			category: info.Category,
+			keys:     keySpec{info.FirstKey, info.LastKey, info.KeyStep},
+			getKeys:  info.Keys,
+		},
+		f: func(c *client) error {
+			return h(&CommandContext{c})
+		},
+	}
+	return nil
+}
+
+// Interceptor wraps the commands performed by the clients, including the
+// unknown commands and the commands called by the scripts. It calls next to
+// run the following interceptors and the command, or returns an error as
+// the reply without calling next. The commands in a transaction are
+// intercepted when queued.
+type Interceptor func(ctx *CommandContext, next func() error) error
+
+// AddInterceptor appends an interceptor, the first added runs first.
+// It must be called before Run.
+func (app *App) AddInterceptor(i Interceptor) {
+	app.interceptors = append(app.interceptors, i)
+}
+
+// intercept runs the interceptors from the i-th, and performs the command
+// after all.
+func (c *client) intercept(i int) error {
+	if i == len(c.app.interceptors) {
+		return c.execute()
+	}
+
+	return c.app.interceptors[i](&CommandContext{c}, func() error {
+		return c.intercept(i + 1)
+	})
+}
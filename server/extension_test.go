@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func init() {
+	// XGETSET key value, returns the database index, the old value and the user
+	err := RegisterCommand("xgetset", CommandInfo{
+		Arity:    3,
+		Flags:    []string{"write"},
+		Category: aclWrite,
+		FirstKey: 1,
+		LastKey:  1,
+		KeyStep:  1,
+	}, func(ctx *CommandContext) error {
+		args := ctx.Args()
+		v, err := ctx.DB().GetSet(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		ctx.Writer().WriteArray([]interface{}{int64(ctx.DB().Index()), v, ctx.Client().User})
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	h := func(ctx *CommandContext) error { return nil }
+
+	for _, v := range []struct {
+		name string
+		info CommandInfo
+	}{
+		{"get", CommandInfo{Arity: 2}},
+		{"", CommandInfo{Arity: 1}},
+		{"xtest", CommandInfo{}},
+		{"xtest", CommandInfo{Arity: 1, Flags: []string{"unknown"}}},
+		{"xtest", CommandInfo{Arity: 1, Category: "unknown"}},
+		{"xtest", CommandInfo{Arity: 2, FirstKey: 1}},
+	} {
+		if err := RegisterCommand(v.name, v.info, h); err == nil {
+			t.Fatalf("%q %v must fail", v.name, v.info)
+		}
+	}
+
+	if cmd := regCmds["xgetset"]; cmd == nil {
+		t.Fatal("xgetset is not registered")
+	} else if !reflect.DeepEqual(cmd.flagNames(), []string{"write"}) {
+		t.Fatal(cmd.flagNames())
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_interceptor"
+	cfg.Addr = "127.0.0.1:11196"
+
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	var names []string
+	app.AddInterceptor(func(ctx *CommandContext, next func() error) error {
+		names = append(names, ctx.Name())
+		return next()
+	})
+	app.AddInterceptor(func(ctx *CommandContext, next func() error) error {
+		switch ctx.Name() {
+		case "flushdb":
+			return errors.New("ERR flushdb is disabled")
+		case "getalias":
+			ctx.Rewrite("GET", ctx.Args())
+		}
+		return next()
+	})
+
+	go app.Run()
+
+	c, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = c.Do("select", 1); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("xgetset", "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := goredis.MultiBulk(c.Do("xgetset", "a", "2")); err != nil {
+		t.Fatal(err)
+	} else if n, _ := goredis.Int(v[0], nil); n != 1 {
+		t.Fatal(n)
+	} else if s, _ := goredis.String(v[1], nil); s != "1" {
+		t.Fatal(s)
+	} else if s, _ := goredis.String(v[2], nil); s != defaultUser {
+		t.Fatal(s)
+	}
+
+	if _, err = c.Do("xgetset", "a"); err == nil {
+		t.Fatal("must fail for arity")
+	} else if s, _ := goredis.String(c.Do("getalias", "a")); s != "2" {
+		t.Fatal(s)
+	} else if _, err = c.Do("flushdb"); err == nil || err.Error() != "ERR flushdb is disabled" {
+		t.Fatal(err)
+	}
+
+	exp := []string{"select", "xgetset", "xgetset", "xgetset", "getalias", "flushdb"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Fatal(names)
+	}
+}
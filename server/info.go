@@ -24,6 +24,12 @@ type info struct {
 		ProceessId int
 	}
 
+	Clients struct {
+		RejectedNum          sync2.AtomicInt64
+		TimeoutNum           sync2.AtomicInt64
+		OutputBufferLimitNum sync2.AtomicInt64
+	}
+
 	Replication struct {
 		PubLogNum          sync2.AtomicInt64
 		PubLogAckNum       sync2.AtomicInt64
@@ -44,6 +50,16 @@ func newInfo(app *App) (i *info, err error) {
 	return i, nil
 }
 
+func (i *info) resetStat() {
+	i.Clients.RejectedNum.Set(0)
+	i.Clients.TimeoutNum.Set(0)
+	i.Clients.OutputBufferLimitNum.Set(0)
+
+	i.Replication.PubLogNum.Set(0)
+	i.Replication.PubLogAckNum.Set(0)
+	i.Replication.PubLogTotalAckTime.Set(0)
+}
+
 func (i *info) Close() {
 
 }
@@ -67,6 +83,8 @@ func (i *info) Dump(section string) []byte {
 		i.dumpAll(buf)
 	case "server":
 		i.dumpServer(buf)
+	case "clients":
+		i.dumpClients(buf)
 	case "mem":
 		i.dumpMem(buf)
 	case "gc":
@@ -75,6 +93,10 @@ func (i *info) Dump(section string) []byte {
 		i.dumpStore(buf)
 	case "replication":
 		i.dumpReplication(buf)
+	case "commandstats":
+		i.dumpCommandStats(buf)
+	case "keyspace":
+		i.dumpKeyspace(buf)
 	default:
 		buf.WriteString(fmt.Sprintf("# %s\r\n", section))
 	}
@@ -90,6 +112,8 @@ type infoPair struct {
 func (i *info) dumpAll(buf *bytes.Buffer) {
 	i.dumpServer(buf)
 	buf.Write(Delims)
+	i.dumpClients(buf)
+	buf.Write(Delims)
 	i.dumpStore(buf)
 	buf.Write(Delims)
 	i.dumpMem(buf)
@@ -97,6 +121,10 @@ func (i *info) dumpAll(buf *bytes.Buffer) {
 	i.dumpGC(buf)
 	buf.Write(Delims)
 	i.dumpReplication(buf)
+	buf.Write(Delims)
+	i.dumpCommandStats(buf)
+	buf.Write(Delims)
+	i.dumpKeyspace(buf)
 }
 
 func (i *info) dumpServer(buf *bytes.Buffer) {
@@ -114,6 +142,21 @@ func (i *info) dumpServer(buf *bytes.Buffer) {
 	)
 }
 
+func (i *info) dumpClients(buf *bytes.Buffer) {
+	buf.WriteString("# Clients\r\n")
+
+	maxClients, timeout := i.app.cfg.GetClientLimits()
+
+	i.dumpPairs(buf, infoPair{"connected_clients", i.app.respClientNum()},
+		infoPair{"maxclients", maxClients},
+		infoPair{"timeout", timeout},
+		infoPair{"rejected_connections", i.Clients.RejectedNum.Get()},
+		infoPair{"timeout_closed_clients", i.Clients.TimeoutNum.Get()},
+		infoPair{"output_buffer_limit_closed_clients", i.Clients.OutputBufferLimitNum.Get()},
+		infoPair{"memcache_connected_clients", i.app.memcacheClientNum()},
+	)
+}
+
 func (i *info) dumpMem(buf *bytes.Buffer) {
 	buf.WriteString("# Mem\r\n")
 
@@ -275,6 +318,32 @@ func (i *info) dumpReplication(buf *bytes.Buffer) {
 	i.dumpPairs(buf, p...)
 }
 
+func (i *info) dumpCommandStats(buf *bytes.Buffer) {
+	buf.WriteString("# Commandstats\r\n")
+
+	s := i.app.cmdStats
+	for _, name := range s.names() {
+		st := s[name]
+		calls := st.calls.Get()
+		usec := st.usec.Get()
+
+		i.dumpPairs(buf, infoPair{"cmdstat_" + name,
+			fmt.Sprintf("calls=%d,usec=%d,usec_per_call=%.2f,errors=%d",
+				calls, usec, float64(usec)/float64(calls), st.errors.Get())})
+	}
+}
+
+func (i *info) dumpKeyspace(buf *bytes.Buffer) {
+	buf.WriteString("# Keyspace\r\n")
+
+	for _, n := range i.app.ldb.KeyNums() {
+		i.dumpPairs(buf, infoPair{fmt.Sprintf("db%d", n.Index),
+			fmt.Sprintf("keys=%d,kv=%d,list=%d,hash=%d,set=%d,zset=%d",
+				n.Total(), n.Num[ledis.KV], n.Num[ledis.LIST], n.Num[ledis.HASH],
+				n.Num[ledis.SET], n.Num[ledis.ZSET])})
+	}
+}
+
 func (i *info) dumpPairs(buf *bytes.Buffer, pairs ...infoPair) {
 	for _, v := range pairs {
 		buf.WriteString(fmt.Sprintf("%s:%v\r\n", v.Key, v.Value))
@@ -0,0 +1,671 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/go/log"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// the limits of memcached
+const (
+	memcacheMaxKeySize   = 250
+	memcacheMaxValueSize = 1024 * 1024
+
+	// the exptime larger than 30 days is the unix time
+	memcacheMaxRelativeExptime = 60 * 60 * 24 * 30
+)
+
+var (
+	errMemcacheCommand  = errors.New("ERROR")
+	errMemcacheFormat   = errors.New("CLIENT_ERROR bad command line format")
+	errMemcacheChunk    = errors.New("CLIENT_ERROR bad data chunk")
+	errMemcacheDelta    = errors.New("CLIENT_ERROR invalid numeric delta argument")
+	errMemcacheNumeric  = errors.New("CLIENT_ERROR cannot increment or decrement non-numeric value")
+	errMemcacheTooLarge = errors.New("SERVER_ERROR object too large for cache")
+	errMemcacheAuth     = errors.New("SERVER_ERROR only the local clients are allowed when auth is enabled")
+)
+
+// memcacheClient serves the memcached text protocol on the KV data of the
+// memcache_db database. The protocol has no authentication, so only the local
+// clients are allowed if auth is enabled, and the ACL is not checked.
+type memcacheClient struct {
+	app *App
+	db  *ledis.DB
+
+	conn       net.Conn
+	remoteAddr string
+
+	br *bufio.Reader
+	bw *bufio.Writer
+
+	noreply bool
+}
+
+type memcacheCommandFunc func(c *memcacheClient, args [][]byte) error
+
+// memcacheCommands are also in the command statistics with the prefix "memcache_".
+var memcacheCommands = map[string]memcacheCommandFunc{
+	"get":       memcacheGetCommand,
+	"gets":      memcacheGetsCommand,
+	"set":       memcacheSetCommand,
+	"add":       memcacheAddCommand,
+	"replace":   memcacheReplaceCommand,
+	"append":    memcacheAppendCommand,
+	"prepend":   memcachePrependCommand,
+	"incr":      memcacheIncrCommand,
+	"decr":      memcacheDecrCommand,
+	"delete":    memcacheDeleteCommand,
+	"touch":     memcacheTouchCommand,
+	"flush_all": memcacheFlushAllCommand,
+	"version":   memcacheVersionCommand,
+}
+
+func memcacheStatName(cmd string) string {
+	return "memcache_" + cmd
+}
+
+func isLocalAddr(addr net.Addr) bool {
+	switch a := addr.(type) {
+	case *net.UnixAddr:
+		return true
+	case *net.TCPAddr:
+		return a.IP.IsLoopback()
+	}
+	return false
+}
+
+func (app *App) addMemcacheClient(c *memcacheClient) {
+	app.mcm.Lock()
+	app.mcs[c] = struct{}{}
+	app.mcm.Unlock()
+}
+
+func (app *App) delMemcacheClient(c *memcacheClient) {
+	app.mcm.Lock()
+	delete(app.mcs, c)
+	app.mcm.Unlock()
+}
+
+func (app *App) closeAllMemcacheClients() {
+	app.mcm.Lock()
+	for c := range app.mcs {
+		c.conn.Close()
+	}
+	app.mcm.Unlock()
+}
+
+func (app *App) memcacheClientNum() int {
+	app.mcm.Lock()
+	n := len(app.mcs)
+	app.mcm.Unlock()
+	return n
+}
+
+func (app *App) memcacheServe() {
+	if app.memcacheListener == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-app.quit:
+			return
+		default:
+			conn, err := app.memcacheListener.Accept()
+			if err != nil {
+				continue
+			}
+
+			newMemcacheClient(conn, app)
+		}
+	}
+}
+
+func newMemcacheClient(conn net.Conn, app *App) {
+	if app.authEnabled() && !isLocalAddr(conn.RemoteAddr()) {
+		conn.Write([]byte(errMemcacheAuth.Error() + "\r\n"))
+		conn.Close()
+		return
+	}
+
+	c := new(memcacheClient)
+	c.app = app
+	c.db, _ = app.ldb.Select(app.cfg.MemcacheDB)
+	c.conn = conn
+	c.remoteAddr = conn.RemoteAddr().String()
+	c.br = bufio.NewReaderSize(conn, app.cfg.ConnReadBufferSize)
+	c.bw = bufio.NewWriterSize(conn, app.cfg.ConnWriteBufferSize)
+
+	app.connWait.Add(1)
+
+	app.addMemcacheClient(c)
+
+	go c.run()
+}
+
+func (c *memcacheClient) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			buf := make([]byte, 4096)
+			n := runtime.Stack(buf, false)
+			buf = buf[0:n]
+
+			log.Fatalf("memcache client run panic %s:%v", buf, e)
+		}
+
+		c.conn.Close()
+
+		c.app.delMemcacheClient(c)
+
+		c.app.connWait.Done()
+	}()
+
+	for {
+		line, err := c.br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			c.writeLine("CLIENT_ERROR line too long")
+			c.bw.Flush()
+			return
+		} else if err != nil {
+			return
+		}
+
+		args := bytes.Fields(line)
+		if len(args) == 0 {
+			c.writeError(errMemcacheCommand)
+			c.bw.Flush()
+			continue
+		}
+
+		cmd := strings.ToLower(string(args[0]))
+		if cmd == "quit" {
+			return
+		}
+
+		// the args are copied, the line is overwritten by reading the data
+		args = copyArgs(args[1:])
+
+		c.noreply = false
+		c.perform(cmd, args)
+
+		if err = c.bw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func copyArgs(args [][]byte) [][]byte {
+	ay := make([][]byte, len(args))
+	for i, arg := range args {
+		ay[i] = append([]byte(nil), arg...)
+	}
+	return ay
+}
+
+func (c *memcacheClient) perform(cmd string, args [][]byte) {
+	start := time.Now()
+
+	f, ok := memcacheCommands[cmd]
+	if !ok {
+		c.writeError(errMemcacheCommand)
+		return
+	}
+
+	err := f(c, args)
+
+	duration := time.Since(start)
+	c.app.cmdStats.record(memcacheStatName(cmd), duration, err)
+
+	if c.app.access.enabled() {
+		fullCmd := append([]byte(cmd), ' ')
+		fullCmd = append(fullCmd, bytes.Join(args, []byte(" "))...)
+		c.app.access.Log(c.remoteAddr, duration.Nanoseconds()/1000000, fullCmd, err)
+	}
+
+	if err != nil {
+		c.writeError(err)
+	}
+}
+
+// writeLine writes the reply line, nothing if noreply.
+func (c *memcacheClient) writeLine(s string) {
+	if c.noreply {
+		return
+	}
+	c.bw.WriteString(s)
+	c.bw.WriteString("\r\n")
+}
+
+func (c *memcacheClient) writeError(err error) {
+	s := err.Error()
+	if !strings.HasPrefix(s, "ERROR") && !strings.HasPrefix(s, "CLIENT_ERROR") &&
+		!strings.HasPrefix(s, "SERVER_ERROR") {
+		s = "SERVER_ERROR " + s
+	}
+
+	// the errors are always replied like memcached
+	c.bw.WriteString(s)
+	c.bw.WriteString("\r\n")
+}
+
+// parseNoreply removes the optional noreply at the end of the args.
+func (c *memcacheClient) parseNoreply(args [][]byte, n int) ([][]byte, error) {
+	if len(args) == n+1 && string(args[n]) == "noreply" {
+		c.noreply = true
+		return args[:n], nil
+	} else if len(args) != n {
+		return nil, errMemcacheFormat
+	}
+	return args, nil
+}
+
+func checkMemcacheKey(key []byte) error {
+	if len(key) == 0 || len(key) > memcacheMaxKeySize {
+		return errMemcacheFormat
+	}
+	for _, b := range key {
+		if b <= ' ' || b == 0x7f {
+			return errMemcacheFormat
+		}
+	}
+	return nil
+}
+
+// memcacheTTL returns the TTL in seconds of the exptime, 0 for no TTL,
+// and negative if expired.
+func memcacheTTL(exptime int64) int64 {
+	if exptime <= memcacheMaxRelativeExptime {
+		if exptime < 0 {
+			return -1
+		}
+		return exptime
+	}
+
+	ttl := exptime - time.Now().Unix()
+	if ttl <= 0 {
+		return -1
+	}
+	return ttl
+}
+
+func memcacheCAS(value []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(value)
+	return h.Sum64()
+}
+
+func memcacheGetGeneric(c *memcacheClient, args [][]byte, withCAS bool) error {
+	if len(args) == 0 {
+		return errMemcacheFormat
+	}
+
+	for _, key := range args {
+		if err := checkMemcacheKey(key); err != nil {
+			return err
+		}
+	}
+
+	values, err := c.db.MGet(args...)
+	if err != nil {
+		return err
+	}
+
+	// the flags are not stored, always 0
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+
+		if withCAS {
+			fmt.Fprintf(c.bw, "VALUE %s 0 %d %d\r\n", args[i], len(v), memcacheCAS(v))
+		} else {
+			fmt.Fprintf(c.bw, "VALUE %s 0 %d\r\n", args[i], len(v))
+		}
+		c.bw.Write(v)
+		c.bw.WriteString("\r\n")
+	}
+
+	c.writeLine("END")
+	return nil
+}
+
+// get key [key ...]
+func memcacheGetCommand(c *memcacheClient, args [][]byte) error {
+	return memcacheGetGeneric(c, args, false)
+}
+
+// gets key [key ...], the cas unique is the hash of the value
+func memcacheGetsCommand(c *memcacheClient, args [][]byte) error {
+	return memcacheGetGeneric(c, args, true)
+}
+
+// readData parses the args of the storage commands,
+// "key flags exptime bytes [noreply]", and reads the data block.
+func (c *memcacheClient) readData(args [][]byte) (key []byte, ttl int64, value []byte, err error) {
+	if args, err = c.parseNoreply(args, 4); err != nil {
+		return
+	}
+
+	key = args[0]
+
+	var exptime, n int64
+	if _, err = strconv.ParseUint(hack.String(args[1]), 10, 32); err != nil {
+		err = errMemcacheFormat
+		return
+	} else if exptime, err = strconv.ParseInt(hack.String(args[2]), 10, 64); err != nil {
+		err = errMemcacheFormat
+		return
+	} else if n, err = strconv.ParseInt(hack.String(args[3]), 10, 64); err != nil || n < 0 {
+		err = errMemcacheFormat
+		return
+	}
+
+	if n > memcacheMaxValueSize {
+		// skip the data like memcached
+		io.CopyN(ioutil.Discard, c.br, n+2)
+		err = errMemcacheTooLarge
+		return
+	}
+
+	value = make([]byte, n+2)
+	if _, err = io.ReadFull(c.br, value); err != nil {
+		return
+	} else if !bytes.HasSuffix(value, []byte("\r\n")) {
+		// skip the rest of the line
+		if value[len(value)-1] != '\n' {
+			c.br.ReadSlice('\n')
+		}
+		err = errMemcacheChunk
+		return
+	}
+	value = value[:n]
+
+	if err = checkMemcacheKey(key); err != nil {
+		return
+	}
+
+	ttl = memcacheTTL(exptime)
+	return
+}
+
+// memcacheStore sets the value with the TTL, the expired value is deleted.
+func memcacheStore(tx *ledis.Tx, key []byte, ttl int64, value []byte) error {
+	if ttl < 0 {
+		_, err := tx.Del(key)
+		return err
+	} else if ttl > 0 {
+		return tx.SetEX(key, ttl, value)
+	}
+
+	if err := tx.Set(key, value); err != nil {
+		return err
+	}
+
+	// SET keeps the TTL, but memcached not
+	_, err := tx.Persist(key)
+	return err
+}
+
+// update checks and writes the key atomically in a transaction, fn returns
+// the reply line.
+func (c *memcacheClient) update(fn func(tx *ledis.Tx) (string, error)) error {
+	var reply string
+	err := c.db.Update(func(tx *ledis.Tx) (err error) {
+		reply, err = fn(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c.writeLine(reply)
+	return nil
+}
+
+// set key flags exptime bytes [noreply]
+func memcacheSetCommand(c *memcacheClient, args [][]byte) error {
+	key, ttl, value, err := c.readData(args)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		return "STORED", memcacheStore(tx, key, ttl, value)
+	})
+}
+
+// add key flags exptime bytes [noreply], stores only if the key not exists
+func memcacheAddCommand(c *memcacheClient, args [][]byte) error {
+	key, ttl, value, err := c.readData(args)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		if n, err := tx.Exists(key); err != nil {
+			return "", err
+		} else if n == 1 {
+			return "NOT_STORED", nil
+		}
+		return "STORED", memcacheStore(tx, key, ttl, value)
+	})
+}
+
+// replace key flags exptime bytes [noreply], stores only if the key exists
+func memcacheReplaceCommand(c *memcacheClient, args [][]byte) error {
+	key, ttl, value, err := c.readData(args)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		if n, err := tx.Exists(key); err != nil {
+			return "", err
+		} else if n == 0 {
+			return "NOT_STORED", nil
+		}
+		return "STORED", memcacheStore(tx, key, ttl, value)
+	})
+}
+
+// append key flags exptime bytes [noreply], the flags and exptime are ignored
+func memcacheAppendCommand(c *memcacheClient, args [][]byte) error {
+	key, _, value, err := c.readData(args)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		if n, err := tx.Exists(key); err != nil {
+			return "", err
+		} else if n == 0 {
+			return "NOT_STORED", nil
+		}
+
+		_, err := tx.Append(key, value)
+		return "STORED", err
+	})
+}
+
+// prepend key flags exptime bytes [noreply], the flags and exptime are ignored
+func memcachePrependCommand(c *memcacheClient, args [][]byte) error {
+	key, _, value, err := c.readData(args)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		old, err := tx.Get(key)
+		if err != nil {
+			return "", err
+		} else if old == nil {
+			return "NOT_STORED", nil
+		}
+
+		// SET keeps the TTL
+		return "STORED", tx.Set(key, append(value, old...))
+	})
+}
+
+func memcacheIncrGeneric(c *memcacheClient, args [][]byte, incr bool) error {
+	args, err := c.parseNoreply(args, 2)
+	if err != nil {
+		return err
+	}
+
+	key := args[0]
+	if err := checkMemcacheKey(key); err != nil {
+		return err
+	}
+
+	delta, err := strconv.ParseUint(hack.String(args[1]), 10, 63)
+	if err != nil {
+		return errMemcacheDelta
+	}
+
+	err = c.update(func(tx *ledis.Tx) (string, error) {
+		if n, err := tx.Exists(key); err != nil {
+			return "", err
+		} else if n == 0 {
+			return "NOT_FOUND", nil
+		}
+
+		var n int64
+		if incr {
+			n, err = tx.IncrBy(key, int64(delta))
+		} else {
+			n, err = tx.DecrBy(key, int64(delta))
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if n < 0 {
+			// memcached never decreases the value below 0
+			if err = tx.Set(key, []byte("0")); err != nil {
+				return "", err
+			}
+			n = 0
+		}
+		return strconv.FormatInt(n, 10), nil
+	})
+
+	if _, ok := err.(*strconv.NumError); ok {
+		return errMemcacheNumeric
+	}
+	return err
+}
+
+// incr key value [noreply]
+func memcacheIncrCommand(c *memcacheClient, args [][]byte) error {
+	return memcacheIncrGeneric(c, args, true)
+}
+
+// decr key value [noreply]
+func memcacheDecrCommand(c *memcacheClient, args [][]byte) error {
+	return memcacheIncrGeneric(c, args, false)
+}
+
+// delete key [noreply]
+func memcacheDeleteCommand(c *memcacheClient, args [][]byte) error {
+	args, err := c.parseNoreply(args, 1)
+	if err != nil {
+		return err
+	} else if err = checkMemcacheKey(args[0]); err != nil {
+		return err
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		// Del returns the number of the keys, not the deleted
+		if n, err := tx.Exists(args[0]); err != nil {
+			return "", err
+		} else if n == 0 {
+			return "NOT_FOUND", nil
+		}
+
+		_, err := tx.Del(args[0])
+		return "DELETED", err
+	})
+}
+
+// touch key exptime [noreply]
+func memcacheTouchCommand(c *memcacheClient, args [][]byte) error {
+	args, err := c.parseNoreply(args, 2)
+	if err != nil {
+		return err
+	}
+
+	key := args[0]
+	if err := checkMemcacheKey(key); err != nil {
+		return err
+	}
+
+	exptime, err := strconv.ParseInt(hack.String(args[1]), 10, 64)
+	if err != nil {
+		return errMemcacheFormat
+	}
+
+	return c.update(func(tx *ledis.Tx) (string, error) {
+		n, err := tx.Exists(key)
+		if err != nil {
+			return "", err
+		} else if n == 0 {
+			return "NOT_FOUND", nil
+		}
+
+		switch ttl := memcacheTTL(exptime); {
+		case ttl < 0:
+			_, err = tx.Del(key)
+		case ttl > 0:
+			_, err = tx.Expire(key, ttl)
+		default:
+			_, err = tx.Persist(key)
+		}
+		return "TOUCHED", err
+	})
+}
+
+// flush_all [0] [noreply], deletes all the KV data of the database,
+// the other data types are kept, the delay is not supported.
+func memcacheFlushAllCommand(c *memcacheClient, args [][]byte) error {
+	if len(args) > 0 && string(args[len(args)-1]) == "noreply" {
+		c.noreply = true
+		args = args[:len(args)-1]
+	}
+
+	if len(args) > 1 {
+		return errMemcacheFormat
+	} else if len(args) == 1 && string(args[0]) != "0" {
+		return errors.New("CLIENT_ERROR the delay of flush_all is not supported")
+	}
+
+	if _, err := c.db.FlushKV(); err != nil {
+		return err
+	}
+
+	c.writeLine("OK")
+	return nil
+}
+
+// version
+func memcacheVersionCommand(c *memcacheClient, args [][]byte) error {
+	if len(args) != 0 {
+		return errMemcacheFormat
+	}
+
+	c.writeLine("VERSION " + ledis.Version)
+	return nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestMemcache(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_memcache"
+	cfg.Addr = "127.0.0.1:11197"
+	cfg.MemcacheAddr = "127.0.0.1:11198"
+	cfg.MemcacheDB = 1
+
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+	go app.Run()
+
+	conn, err := net.Dial("tcp", cfg.MemcacheAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+
+	// do sends the request and reads the reply lines until the last one
+	do := func(req string, last ...string) []string {
+		if _, err := conn.Write([]byte(req)); err != nil {
+			t.Fatal(err)
+		}
+
+		var lines []string
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSuffix(line, "\r\n")
+			lines = append(lines, line)
+
+			if len(last) == 0 {
+				return lines
+			}
+			for _, s := range last {
+				if line == s {
+					return lines
+				}
+			}
+		}
+	}
+
+	for _, v := range []struct {
+		req  string
+		resp string
+	}{
+		{"set a 0 0 1\r\n1\r\n", "STORED"},
+		{"add a 0 0 1\r\n2\r\n", "NOT_STORED"},
+		{"add b 0 100 2\r\nbb\r\n", "STORED"},
+		{"replace c 0 0 1\r\n3\r\n", "NOT_STORED"},
+		{"append a 0 0 1\r\n2\r\n", "STORED"},
+		{"prepend a 0 0 1\r\n0\r\n", "STORED"},
+		{"incr a 5\r\n", "17"},
+		{"decr a 100\r\n", "0"},
+		{"incr c 1\r\n", "NOT_FOUND"},
+		{"incr b 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value"},
+		{"touch a 100\r\n", "TOUCHED"},
+		{"delete a\r\n", "DELETED"},
+		{"delete a\r\n", "NOT_FOUND"},
+		{"set c 0 -1 1\r\n3\r\n", "STORED"},
+		{"set d 0 0 2\r\n123\r\n", "CLIENT_ERROR bad data chunk"},
+		{"unknown\r\n", "ERROR"},
+	} {
+		if resp := do(v.req); resp[0] != v.resp {
+			t.Fatalf("%q: %q != %q", v.req, resp[0], v.resp)
+		}
+	}
+
+	// the last request is replied only
+	if resp := do("set e 0 0 1 noreply\r\n5\r\nget e c\r\n", "END"); strings.Join(resp, "|") != "VALUE e 0 1|5|END" {
+		t.Fatal(resp)
+	}
+
+	if resp := do("gets b\r\n", "END"); len(resp) != 3 || resp[0] != fmt.Sprintf("VALUE b 0 2 %d", memcacheCAS([]byte("bb"))) {
+		t.Fatal(resp)
+	}
+
+	db, _ := app.ldb.Select(cfg.MemcacheDB)
+	if v, _ := db.Get([]byte("e")); string(v) != "5" {
+		t.Fatal(string(v))
+	} else if ttl, _ := db.TTL([]byte("b")); ttl <= 0 || ttl > 100 {
+		t.Fatal(ttl)
+	}
+
+	// the concurrent prepends are not lost
+	do("set p 0 0 1\r\n0\r\n")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			c, err := net.Dial("tcp", cfg.MemcacheAddr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer c.Close()
+
+			r := bufio.NewReader(c)
+			for j := 0; j < 20; j++ {
+				c.Write([]byte("prepend p 0 0 1\r\nx\r\n"))
+				if line, _ := r.ReadString('\n'); line != "STORED\r\n" {
+					t.Error(line)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := db.Get([]byte("p")); len(v) != 81 {
+		t.Fatal(len(v))
+	}
+
+	// flush_all deletes the KV data only
+	db.HSet([]byte("h"), []byte("f"), []byte("v"))
+
+	if resp := do("flush_all\r\n"); resp[0] != "OK" {
+		t.Fatal(resp)
+	} else if resp = do("get b e\r\n", "END"); len(resp) != 1 {
+		t.Fatal(resp)
+	} else if n, _ := db.HLen([]byte("h")); n != 1 {
+		t.Fatal(n)
+	}
+
+	if n := app.cmdStats[memcacheStatName("get")].calls.Get(); n != 2 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/siddontang/go/sync2"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// the upper bounds of the command latency histogram in seconds
+var latencyBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01,
+	0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+type commandStat struct {
+	calls  sync2.AtomicInt64
+	usec   sync2.AtomicInt64
+	errors sync2.AtomicInt64
+
+	// the number of calls in each latency bucket, the last is +Inf,
+	// they are not cumulative
+	buckets []sync2.AtomicInt64
+}
+
+// commandStats is the statistics of all the registered commands, it is
+// created after the commands are registered and never changed, so it
+// can be read without lock.
+type commandStats map[string]*commandStat
+
+func newCommandStats() commandStats {
+	s := make(commandStats, len(regCmds))
+	for name := range regCmds {
+		s[name] = &commandStat{buckets: make([]sync2.AtomicInt64, len(latencyBuckets)+1)}
+	}
+	for name := range memcacheCommands {
+		s[memcacheStatName(name)] = &commandStat{buckets: make([]sync2.AtomicInt64, len(latencyBuckets)+1)}
+	}
+	return s
+}
+
+func (s commandStats) record(cmd string, d time.Duration, err error) {
+	st, ok := s[cmd]
+	if !ok {
+		return
+	}
+
+	st.calls.Add(1)
+	if err != nil {
+		st.errors.Add(1)
+	}
+	st.usec.Add(d.Nanoseconds() / 1000)
+	st.buckets[sort.SearchFloat64s(latencyBuckets, d.Seconds())].Add(1)
+}
+
+func (s commandStats) reset() {
+	for _, st := range s {
+		st.calls.Set(0)
+		st.usec.Set(0)
+		st.errors.Set(0)
+		for i := range st.buckets {
+			st.buckets[i].Set(0)
+		}
+	}
+}
+
+// names returns the names of the called commands in order.
+func (s commandStats) names() []string {
+	names := make([]string, 0, len(s))
+	for name, st := range s {
+		if st.calls.Get() > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// metricsWriter writes the metrics in the Prometheus text format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *metricsWriter) header(name string, typ string, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample, labels are the pairs of the label name and value.
+func (w *metricsWriter) sample(name string, value interface{}, labels ...string) {
+	w.buf.WriteString(name)
+
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&w.buf, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+		}
+		w.buf.WriteByte('}')
+	}
+
+	switch v := value.(type) {
+	case float64:
+		fmt.Fprintf(&w.buf, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		if v {
+			w.buf.WriteString(" 1\n")
+		} else {
+			w.buf.WriteString(" 0\n")
+		}
+	default:
+		fmt.Fprintf(&w.buf, " %d\n", v)
+	}
+}
+
+func (w *metricsWriter) metric(name string, typ string, help string, value interface{}) {
+	w.header(name, typ, help)
+	w.sample(name, value)
+}
+
+func (app *App) dumpMetrics(w *metricsWriter) {
+	app.dumpServerMetrics(w)
+	app.dumpStoreMetrics(w)
+	app.dumpCommandMetrics(w)
+	app.dumpReplicationMetrics(w)
+	app.dumpSnapshotMetrics(w)
+}
+
+func (app *App) dumpServerMetrics(w *metricsWriter) {
+	w.header("ledis_info", "gauge", "The version of the ledis server.")
+	w.sample("ledis_info", 1, "version", ledis.Version)
+
+	w.metric("ledis_connected_clients", "gauge", "The number of the RESP clients.", app.respClientNum())
+	w.metric("ledis_memcache_connected_clients", "gauge", "The number of the memcached protocol clients.", app.memcacheClientNum())
+	w.metric("ledis_monitor_clients", "gauge", "The number of the clients in MONITOR mode.", app.monitors.n.Get())
+	w.metric("ledis_rejected_connections_total", "counter", "The number of the connections rejected for maxclients.", app.info.Clients.RejectedNum.Get())
+
+	w.header("ledis_closed_clients_total", "counter", "The number of the clients closed by the server.")
+	w.sample("ledis_closed_clients_total", app.info.Clients.TimeoutNum.Get(), "reason", "timeout")
+	w.sample("ledis_closed_clients_total", app.info.Clients.OutputBufferLimitNum.Get(), "reason", "output_buffer_limit")
+	w.metric("ledis_goroutines", "gauge", "The number of goroutines.", runtime.NumGoroutine())
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	w.metric("ledis_memory_alloc_bytes", "gauge", "The bytes of the allocated heap objects.", mem.Alloc)
+	w.metric("ledis_memory_sys_bytes", "gauge", "The bytes of memory obtained from the OS.", mem.Sys)
+	w.metric("ledis_memory_heap_objects", "gauge", "The number of the allocated heap objects.", mem.HeapObjects)
+
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	w.metric("ledis_gc_total", "counter", "The number of the completed GC cycles.", gc.NumGC)
+	w.metric("ledis_gc_pause_seconds_total", "counter", "The total pause time of GC.", gc.PauseTotal.Seconds())
+	w.metric("ledis_gc_last_time_seconds", "gauge", "The unix time of the last GC.", gc.LastGC.Unix())
+}
+
+func (app *App) dumpStoreMetrics(w *metricsWriter) {
+	s := app.ldb.StoreStat()
+
+	w.header("ledis_store_ops_total", "counter", "The number of the store operations.")
+	for _, op := range []struct {
+		name string
+		num  *sync2.AtomicInt64
+	}{
+		{"get", &s.GetNum},
+		{"get_missing", &s.GetMissingNum},
+		{"put", &s.PutNum},
+		{"delete", &s.DeleteNum},
+		{"iter", &s.IterNum},
+		{"iter_seek", &s.IterSeekNum},
+		{"iter_close", &s.IterCloseNum},
+		{"snapshot", &s.SnapshotNum},
+		{"snapshot_close", &s.SnapshotCloseNum},
+		{"batch", &s.BatchNum},
+		{"batch_commit", &s.BatchCommitNum},
+		{"tx", &s.TxNum},
+		{"tx_commit", &s.TxCommitNum},
+		{"tx_close", &s.TxCloseNum},
+		{"compact", &s.CompactNum},
+	} {
+		w.sample("ledis_store_ops_total", op.num.Get(), "op", op.name)
+	}
+
+	w.header("ledis_store_op_seconds_total", "counter", "The total time of the store operations.")
+	w.sample("ledis_store_op_seconds_total", s.GetTotalTime.Get().Seconds(), "op", "get")
+	w.sample("ledis_store_op_seconds_total", s.BatchCommitTotalTime.Get().Seconds(), "op", "batch_commit")
+	w.sample("ledis_store_op_seconds_total", s.CompactTotalTime.Get().Seconds(), "op", "compact")
+}
+
+func (app *App) dumpCommandMetrics(w *metricsWriter) {
+	names := app.cmdStats.names()
+
+	w.header("ledis_commands_total", "counter", "The number of the processed commands.")
+	for _, name := range names {
+		w.sample("ledis_commands_total", app.cmdStats[name].calls.Get(), "cmd", name)
+	}
+
+	w.header("ledis_command_errors_total", "counter", "The number of the commands returning errors.")
+	for _, name := range names {
+		w.sample("ledis_command_errors_total", app.cmdStats[name].errors.Get(), "cmd", name)
+	}
+
+	w.header("ledis_command_duration_seconds", "histogram", "The latency of the processed commands.")
+	for _, name := range names {
+		st := app.cmdStats[name]
+
+		var n int64
+		for i, le := range latencyBuckets {
+			n += st.buckets[i].Get()
+			w.sample("ledis_command_duration_seconds_bucket", n, "cmd", name, "le", strconv.FormatFloat(le, 'g', -1, 64))
+		}
+		n += st.buckets[len(latencyBuckets)].Get()
+		w.sample("ledis_command_duration_seconds_bucket", n, "cmd", name, "le", "+Inf")
+
+		w.sample("ledis_command_duration_seconds_sum", float64(st.usec.Get())/1e6, "cmd", name)
+		w.sample("ledis_command_duration_seconds_count", n, "cmd", name)
+	}
+}
+
+func (app *App) dumpReplicationMetrics(w *metricsWriter) {
+	app.m.Lock()
+	isSlave := len(app.cfg.SlaveOf) > 0
+	app.m.Unlock()
+
+	w.metric("ledis_replication_is_slave", "gauge", "Whether the server is a slave.", isSlave)
+
+	if isSlave {
+		state := app.m.state.Get()
+		w.metric("ledis_replication_master_link_up", "gauge", "Whether the slave is connected to the master.",
+			state == replSyncState || state == replConnectedState)
+	}
+
+	w.metric("ledis_replication_master_last_log_id", "gauge", "The last log id synced from the master.",
+		app.info.Replication.MasterLastLogID.Get())
+
+	s, _ := app.ldb.ReplicationStat()
+	if s == nil {
+		return
+	}
+
+	w.metric("ledis_replication_first_log_id", "gauge", "The first replication log id.", s.FirstID)
+	w.metric("ledis_replication_last_log_id", "gauge", "The last replication log id.", s.LastID)
+	w.metric("ledis_replication_commit_log_id", "gauge", "The committed replication log id.", s.CommitID)
+
+	app.slock.Lock()
+	slaves := make(map[string]uint64, len(app.slaves))
+	for _, c := range app.slaves {
+		slaves[c.slaveListeningAddr] = c.lastLogID.Get()
+	}
+	app.slock.Unlock()
+
+	addrs := make([]string, 0, len(slaves))
+	for addr := range slaves {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	w.header("ledis_replication_slave_last_log_id", "gauge", "The last log id synced by the slave.")
+	for _, addr := range addrs {
+		w.sample("ledis_replication_slave_last_log_id", slaves[addr], "slave", addr)
+	}
+
+	w.header("ledis_replication_slave_lag", "gauge", "The number of logs not synced by the slave.")
+	for _, addr := range addrs {
+		var lag uint64
+		if s.LastID > slaves[addr] {
+			lag = s.LastID - slaves[addr]
+		}
+		w.sample("ledis_replication_slave_lag", lag, "slave", addr)
+	}
+}
+
+func (app *App) dumpSnapshotMetrics(w *metricsWriter) {
+	w.metric("ledis_snapshots", "gauge", "The number of the snapshots.", app.snap.num.Get())
+	w.metric("ledis_snapshot_last_create_time_seconds", "gauge", "The unix time of the latest snapshot, 0 if no snapshot.", app.snap.lastTime.Get())
+	w.metric("ledis_snapshot_in_progress", "gauge", "Whether a snapshot is being created.", app.snap.creating.Get())
+}
+
+func (app *App) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	mw := new(metricsWriter)
+	app.dumpMetrics(mw)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(mw.buf.Bytes())
+}
+
+func (app *App) metricsServe() {
+	if app.metricsListener == nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", app.serveMetrics)
+
+	svr := http.Server{Handler: mux}
+	svr.Serve(app.metricsListener)
+}
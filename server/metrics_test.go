@@ -0,0 +1,87 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	w := new(metricsWriter)
+	w.header("test_total", "counter", "The test.")
+	w.sample("test_total", int64(10), "cmd", "get", "le", "+Inf")
+	w.metric("test_up", "gauge", "The test is up.", true)
+	w.sample("test_seconds", 0.25)
+
+	expected := "# HELP test_total The test.\n# TYPE test_total counter\n" +
+		"test_total{cmd=\"get\",le=\"+Inf\"} 10\n" +
+		"# HELP test_up The test is up.\n# TYPE test_up gauge\ntest_up 1\n" +
+		"test_seconds 0.25\n"
+	if s := w.buf.String(); s != expected {
+		t.Fatal(s)
+	}
+}
+
+func TestCommandStats(t *testing.T) {
+	s := newCommandStats()
+
+	s.record("get", 50*time.Microsecond, nil)
+	s.record("get", 2*time.Millisecond, nil)
+	s.record("get", time.Minute, ErrCmdParams)
+	s.record("no_such_command", time.Millisecond, nil)
+
+	st := s["get"]
+	if st.calls.Get() != 3 || st.errors.Get() != 1 {
+		t.Fatal(st.calls.Get(), st.errors.Get())
+	} else if st.buckets[0].Get() != 1 || st.buckets[4].Get() != 1 || st.buckets[len(latencyBuckets)].Get() != 1 {
+		t.Fatal("invalid buckets")
+	}
+
+	if names := s.names(); len(names) != 1 || names[0] != "get" {
+		t.Fatal(names)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("set", "metrics_key", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.Get("http://127.0.0.1:21181/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatal(ct)
+	}
+
+	buf, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(buf)
+
+	for _, m := range []string{
+		"# TYPE ledis_commands_total counter\n",
+		"ledis_commands_total{cmd=\"set\"} ",
+		"# TYPE ledis_command_duration_seconds histogram\n",
+		"ledis_command_duration_seconds_bucket{cmd=\"set\",le=\"+Inf\"} ",
+		"ledis_command_duration_seconds_count{cmd=\"set\"} ",
+		"ledis_store_ops_total{op=\"put\"} ",
+		"ledis_connected_clients ",
+		"ledis_gc_total ",
+		"ledis_replication_is_slave 0\n",
+		"ledis_snapshot_in_progress 0\n",
+	} {
+		if !strings.Contains(s, m) {
+			t.Fatalf("%q not found in\n%s", m, s)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go/hack"
+	"github.com/siddontang/go/sync2"
+)
+
+// the max number of the buffered commands for a monitor, the commands
+// are dropped if the monitor is too slow
+const monitorBufferSize = 1024
+
+// monitorSkipCommands are not sent to the monitors, they have the password.
+var monitorSkipCommands = map[string]struct{}{
+	"auth":  {},
+	"hello": {},
+	"acl":   {},
+}
+
+type monitor struct {
+	c *client
+
+	ch chan []byte
+
+	// the number of the dropped commands since the last sent
+	dropped sync2.AtomicInt64
+}
+
+// monitors sends the processed commands to the clients in MONITOR mode,
+// every monitor has a buffer and a goroutine, so the slow monitors do not
+// block the commands.
+type monitors struct {
+	sync.Mutex
+
+	// the number of monitors, to skip formatting if no monitor
+	n sync2.AtomicInt64
+
+	ms map[*client]*monitor
+}
+
+func newMonitors() *monitors {
+	m := new(monitors)
+	m.ms = make(map[*client]*monitor)
+	return m
+}
+
+func (m *monitors) add(c *client) {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.ms[c]; ok {
+		return
+	}
+
+	mo := &monitor{c: c, ch: make(chan []byte, monitorBufferSize)}
+	m.ms[c] = mo
+	m.n.Add(1)
+
+	go mo.run()
+}
+
+func (m *monitors) remove(c *client) {
+	m.Lock()
+	defer m.Unlock()
+
+	if mo, ok := m.ms[c]; ok {
+		delete(m.ms, c)
+		m.n.Add(-1)
+		close(mo.ch)
+	}
+}
+
+// feed sends the command of the client to all the monitors.
+func (m *monitors) feed(c *client) {
+	if m.n.Get() == 0 {
+		return
+	} else if _, ok := monitorSkipCommands[c.cmd]; ok {
+		return
+	}
+
+	line := formatMonitorLine(c, time.Now())
+
+	m.Lock()
+	defer m.Unlock()
+
+	for _, mo := range m.ms {
+		select {
+		case mo.ch <- line:
+		default:
+			mo.dropped.Add(1)
+		}
+	}
+}
+
+func (mo *monitor) run() {
+	for line := range mo.ch {
+		mo.c.respLock.Lock()
+		if n := mo.dropped.Get(); n > 0 {
+			mo.dropped.Add(-n)
+			mo.c.resp.writeStatus(fmt.Sprintf("... (%d commands dropped)", n))
+		}
+		mo.c.resp.writeStatus(hack.String(line))
+		mo.c.resp.flush()
+		mo.c.respLock.Unlock()
+	}
+}
+
+// formatMonitorLine formats the command like Redis MONITOR,
+// e.g. 1443000000.123456 [0 127.0.0.1:52555] "set" "a" "1"
+func formatMonitorLine(c *client, now time.Time) []byte {
+	var buf bytes.Buffer
+
+	addr := c.remoteAddr
+	if c.isScript() {
+		addr = "lua"
+	}
+
+	fmt.Fprintf(&buf, "%d.%06d [%d %s] %s", now.Unix(), now.Nanosecond()/1000, c.db.Index(), addr, strconv.Quote(c.cmd))
+	for _, arg := range c.args {
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.Quote(hack.String(arg)))
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	startTestApp()
+
+	conn, err := net.Dial("tcp", "127.0.0.1:16380")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	readLine := func() string {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSuffix(line, "\r\n")
+	}
+
+	conn.Write([]byte("*1\r\n$7\r\nMONITOR\r\n"))
+	if line := readLine(); line != "+OK" {
+		t.Fatal(line)
+	}
+
+	c := getTestConn()
+	defer c.Close()
+
+	c.Do("select", 3)
+	c.Do("set", "monitor_key", "a b\"c")
+	c.Do("auth", "monitor_key")
+	c.Do("eval", "return redis.call('get', KEYS[1])", 1, "monitor_key")
+	c.Do("select", 0)
+
+	if _, err := http.Get("http://127.0.0.1:21181/GET/monitor_key"); err != nil {
+		t.Fatal(err)
+	}
+
+	// other tests may run commands too
+	var lines []string
+	for len(lines) < 4 {
+		line := readLine()
+		if !strings.HasPrefix(line, "+") {
+			t.Fatal(line)
+		} else if strings.Contains(line, "monitor_key") {
+			lines = append(lines, line[strings.Index(line, " ")+1:])
+		}
+	}
+
+	if !strings.HasPrefix(lines[0], "[3 127.0.0.1:") || !strings.HasSuffix(lines[0], `] "set" "monitor_key" "a b\"c"`) {
+		t.Fatal(lines[0])
+	}
+
+	// auth is skipped
+	if !strings.HasPrefix(lines[1], "[3 ") || !strings.Contains(lines[1], `] "eval" `) {
+		t.Fatal(lines[1])
+	}
+
+	if lines[2] != `[3 lua] "get" "monitor_key"` {
+		t.Fatal(lines[2])
+	}
+
+	if !strings.HasPrefix(lines[3], "[0 127.0.0.1:") || !strings.HasSuffix(lines[3], `] "get" "monitor_key"`) {
+		t.Fatal(lines[3])
+	}
+}
+
+func TestMonitorSlow(t *testing.T) {
+	app := testApp
+	if app == nil {
+		startTestApp()
+		app = testApp
+	}
+
+	c := newClient(app)
+	c.remoteAddr = "127.0.0.1:1"
+	c.cmd = "ping"
+
+	m := newMonitors()
+	m.ms[c] = &monitor{c: c, ch: make(chan []byte, 1)}
+	m.n.Set(1)
+
+	// no one reads the commands, the feeding must not be blocked
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			m.feed(c)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("feed is blocked")
+	}
+
+	if n := m.ms[c].dropped.Get(); n != 2 {
+		t.Fatal(n)
+	}
+
+	m.remove(c)
+	if m.n.Get() != 0 {
+		t.Fatal(m.n.Get())
+	}
+}
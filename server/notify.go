@@ -0,0 +1,123 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/siddontang/go/sync2"
+	"github.com/siddontang/ledisdb/ledis"
+)
+
+// the classes of keyspace notifications, same as Redis
+const (
+	notifyKeyspace int32 = 1 << iota
+	notifyKeyevent
+	notifyGeneric
+	notifyString
+	notifyList
+	notifyHash
+	notifySet
+	notifyZSet
+	notifyExpired
+
+	notifyAll = notifyGeneric | notifyString | notifyList | notifyHash | notifySet | notifyZSet | notifyExpired
+)
+
+// parseNotifyFlags parses the classes of notify_keyspace_events.
+func parseNotifyFlags(s string) (int32, error) {
+	var flags int32
+	for _, ch := range s {
+		switch ch {
+		case 'A':
+			flags |= notifyAll
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'l':
+			flags |= notifyList
+		case 'h':
+			flags |= notifyHash
+		case 's':
+			flags |= notifySet
+		case 'z':
+			flags |= notifyZSet
+		case 'x':
+			flags |= notifyExpired
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		default:
+			return 0, fmt.Errorf("invalid keyspace notification class '%c'", ch)
+		}
+	}
+
+	return flags, nil
+}
+
+type keyspaceNotifier struct {
+	app *App
+
+	flags sync2.AtomicInt32
+}
+
+func newKeyspaceNotifier(app *App) (*keyspaceNotifier, error) {
+	flags, err := parseNotifyFlags(app.cfg.NotifyKeyspaceEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	n := new(keyspaceNotifier)
+	n.app = app
+	n.flags.Set(flags)
+
+	return n, nil
+}
+
+// enabled returns whether any event can be published, it needs a class and
+// at least one of the keyspace and keyevent channels.
+func (n *keyspaceNotifier) enabled() bool {
+	flags := n.flags.Get()
+	return flags&notifyAll != 0 && flags&(notifyKeyspace|notifyKeyevent) != 0
+}
+
+func keyEventClass(e *ledis.KeyEvent) int32 {
+	switch e.Event {
+	case "expired":
+		return notifyExpired
+	case "del", "expire", "persist":
+		return notifyGeneric
+	}
+
+	switch e.DataType {
+	case ledis.LIST:
+		return notifyList
+	case ledis.HASH:
+		return notifyHash
+	case ledis.SET:
+		return notifySet
+	case ledis.ZSET:
+		return notifyZSet
+	default:
+		return notifyString
+	}
+}
+
+// handleKeyEvent publishes the key event to the keyspace and keyevent channels.
+func (n *keyspaceNotifier) handleKeyEvent(e *ledis.KeyEvent) {
+	flags := n.flags.Get()
+	if flags&keyEventClass(e) == 0 {
+		return
+	}
+
+	index := strconv.Itoa(e.Index)
+
+	if flags&notifyKeyspace != 0 {
+		n.app.pubsub.publish("__keyspace@"+index+"__:"+string(e.Key), []byte(e.Event))
+	}
+
+	if flags&notifyKeyevent != 0 {
+		n.app.pubsub.publish("__keyevent@"+index+"__:"+e.Event, e.Key)
+	}
+}
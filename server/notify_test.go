@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestParseNotifyFlags(t *testing.T) {
+	if flags, err := parseNotifyFlags("KEA"); err != nil {
+		t.Fatal(err)
+	} else if flags != notifyKeyspace|notifyKeyevent|notifyAll {
+		t.Fatal(flags)
+	}
+
+	if flags, err := parseNotifyFlags(""); err != nil {
+		t.Fatal(err)
+	} else if flags != 0 {
+		t.Fatal(flags)
+	}
+
+	if _, err := parseNotifyFlags("Kw"); err == nil {
+		t.Fatal("must error")
+	}
+
+	// the key events are not handled if nothing is published
+	for s, enabled := range map[string]bool{"": false, "K": false, "A": false, "KA": true, "Ex": true} {
+		flags, _ := parseNotifyFlags(s)
+
+		n := new(keyspaceNotifier)
+		n.flags.Set(flags)
+		if n.enabled() != enabled {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestKeyspaceNotify(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_keyspace_notify"
+	cfg.Addr = "127.0.0.1:11191"
+	cfg.NotifyKeyspaceEvents = "Kh$gx"
+
+	os.RemoveAll(cfg.DataDir)
+
+	s, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Run()
+	defer s.Close()
+
+	sub, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	c := goredis.NewClient(cfg.Addr, "")
+	c.SetMaxIdleConns(1)
+	defer c.Close()
+
+	sub.Send("psubscribe", "__key*@0__:*")
+	receiveStrings(t, sub)
+
+	c.Do("set", "a", "1")
+	c.Do("sadd", "a", "1")
+	c.Do("hset", "a", "f", "v")
+	c.Do("pexpire", "a", 1)
+
+	expected := [][]string{
+		{"__keyspace@0__:a", "set"},
+		{"__keyspace@0__:a", "hset"},
+		{"__keyspace@0__:a", "expire"},
+	}
+
+	for _, e := range expected {
+		if v := receiveStrings(t, sub); v[2] != e[0] || v[3] != e[1] {
+			t.Fatal(v)
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	c.Do("get", "a")
+	c.Do("set", "a", "2")
+
+	if v := receiveStrings(t, sub); v[2] != "__keyspace@0__:a" || v[3] != "expired" {
+		t.Fatal(v)
+	}
+
+	if v := receiveStrings(t, sub); v[2] != "__keyspace@0__:a" || v[3] != "set" {
+		t.Fatal(v)
+	}
+}
+
+func TestKeyspaceNotifySubscriber(t *testing.T) {
+	cfg := config.NewConfigDefault()
+	cfg.DataDir = "/tmp/test_keyspace_notify_subscriber"
+	cfg.Addr = "127.0.0.1:11199"
+	cfg.NotifyKeyspaceEvents = "KA"
+
+	os.RemoveAll(cfg.DataDir)
+
+	s, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Run()
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// the RESP3 subscriber writes the key it subscribes
+	doResp3(t, conn, r, "hello", "3")
+	doResp3(t, conn, r, "subscribe", "__keyspace@0__:k")
+
+	if v, ok := doResp3(t, conn, r, "set", "k", "v").(resp3Push); !ok || v[1] != "__keyspace@0__:k" || v[2] != "set" {
+		t.Fatal(v)
+	} else if v := doResp3(t, conn, r); v != OK {
+		t.Fatal(v)
+	}
+
+	// the writes of other clients are not blocked
+	c, err := goredis.Connect(cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := c.Do("set", "other", "v"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"sort"
+	"sync"
+)
+
+// pubsub dispatches the published messages to the subscribed clients.
+type pubsub struct {
+	sync.Mutex
+
+	channels map[string]map[*client]struct{}
+	patterns map[string]map[*client]struct{}
+}
+
+func newPubSub() *pubsub {
+	p := new(pubsub)
+	p.channels = make(map[string]map[*client]struct{})
+	p.patterns = make(map[string]map[*client]struct{})
+	return p
+}
+
+func subscribe(m map[string]map[*client]struct{}, key string, c *client) {
+	cs, ok := m[key]
+	if !ok {
+		cs = make(map[*client]struct{})
+		m[key] = cs
+	}
+	cs[c] = struct{}{}
+}
+
+func unsubscribe(m map[string]map[*client]struct{}, key string, c *client) {
+	if cs, ok := m[key]; ok {
+		delete(cs, c)
+		if len(cs) == 0 {
+			delete(m, key)
+		}
+	}
+}
+
+func (p *pubsub) subscribe(c *client, channel string) {
+	p.Lock()
+	subscribe(p.channels, channel, c)
+	p.Unlock()
+}
+
+func (p *pubsub) unsubscribe(c *client, channel string) {
+	p.Lock()
+	unsubscribe(p.channels, channel, c)
+	p.Unlock()
+}
+
+func (p *pubsub) psubscribe(c *client, pattern string) {
+	p.Lock()
+	subscribe(p.patterns, pattern, c)
+	p.Unlock()
+}
+
+func (p *pubsub) punsubscribe(c *client, pattern string) {
+	p.Lock()
+	unsubscribe(p.patterns, pattern, c)
+	p.Unlock()
+}
+
+type pmatch struct {
+	pattern string
+	c       *client
+}
+
+// publish sends the message to all the clients subscribed the channel
+// or a matching pattern, returns the number of clients received.
+func (p *pubsub) publish(channel string, message []byte) int64 {
+	p.Lock()
+	cs := make([]*client, 0, len(p.channels[channel]))
+	for c := range p.channels[channel] {
+		cs = append(cs, c)
+	}
+
+	var ps []pmatch
+	for pattern, m := range p.patterns {
+		if globMatch(pattern, channel) {
+			for c := range m {
+				ps = append(ps, pmatch{pattern, c})
+			}
+		}
+	}
+	p.Unlock()
+
+	// deliver without the lock of pubsub, the subscribers may
+	// be subscribing in the meantime
+	var n int64
+	ch := []byte(channel)
+	for _, c := range cs {
+		if c.deliver(false, channel, []interface{}{[]byte("message"), ch, message}) {
+			n++
+		}
+	}
+
+	for _, m := range ps {
+		if m.c.deliver(true, m.pattern, []interface{}{[]byte("pmessage"), []byte(m.pattern), ch, message}) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// activeChannels returns the sorted channels which have subscribers and
+// match the pattern, all channels are returned if pattern is empty.
+func (p *pubsub) activeChannels(pattern string) []string {
+	p.Lock()
+	chs := make([]string, 0, len(p.channels))
+	for ch := range p.channels {
+		if len(pattern) == 0 || globMatch(pattern, ch) {
+			chs = append(chs, ch)
+		}
+	}
+	p.Unlock()
+
+	sort.Strings(chs)
+	return chs
+}
+
+func (p *pubsub) numSub(channel string) int64 {
+	p.Lock()
+	n := len(p.channels[channel])
+	p.Unlock()
+	return int64(n)
+}
+
+func (p *pubsub) numPat() int64 {
+	p.Lock()
+	n := len(p.patterns)
+	p.Unlock()
+	return int64(n)
+}
+
+// globMatch reports whether s matches the glob-style pattern like Redis,
+// supports *, ?, [...] with ^ and ranges, and \ to escape.
+func globMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) >= 2 {
+					pattern = pattern[1:]
+					if pattern[0] == s[0] {
+						match = true
+					}
+				} else if len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']' {
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					if s[0] >= start && s[0] <= end {
+						match = true
+					}
+					pattern = pattern[2:]
+				} else if pattern[0] == s[0] {
+					match = true
+				}
+				pattern = pattern[1:]
+			}
+
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s = s[1:]
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+
+		if len(pattern) > 0 {
+			pattern = pattern[1:]
+		}
+	}
+
+	return len(s) == 0
+}
@@ -444,7 +444,8 @@ func asyncNotifyUint64(ch chan uint64, v uint64) {
 }
 
 func (app *App) publishNewLog(l *rpl.Log) {
-	if !app.cfg.Replication.Sync {
+	syncRpl, waitSyncTime, waitMaxSlaveAcks := app.cfg.GetReplicationSync()
+	if !syncRpl {
 		//no sync replication, we will do async
 		return
 	}
@@ -456,8 +457,8 @@ func (app *App) publishNewLog(l *rpl.Log) {
 	slaveNum := len(app.slaves)
 
 	total := (slaveNum + 1) / 2
-	if app.cfg.Replication.WaitMaxSlaveAcks > 0 {
-		total = num.MinInt(total, app.cfg.Replication.WaitMaxSlaveAcks)
+	if waitMaxSlaveAcks > 0 {
+		total = num.MinInt(total, waitMaxSlaveAcks)
 	}
 
 	n := 0
@@ -499,7 +500,7 @@ func (app *App) publishNewLog(l *rpl.Log) {
 
 	select {
 	case <-done:
-	case <-time.After(time.Duration(app.cfg.Replication.WaitSyncTime) * time.Millisecond):
+	case <-time.After(time.Duration(waitSyncTime) * time.Millisecond):
 		log.Info("replication wait timeout")
 	}
 
@@ -9,7 +9,6 @@ import (
 	"sync"
 
 	"github.com/siddontang/go/hack"
-	"github.com/siddontang/go/num"
 	"github.com/siddontang/ledisdb/ledis"
 	"github.com/yuin/gopher-lua"
 
@@ -80,7 +79,7 @@ func (w *luaWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool)
 
 		for _, v := range lst {
 			table.Append(lua.LString(hack.String(v.Member)))
-			table.Append(lua.LString(num.FormatInt64ToSlice(v.Score)))
+			table.Append(lua.LString(zformatScore(v.Score)))
 		}
 	} else {
 		table = w.l.CreateTable(len(lst), 0)
@@ -97,6 +96,22 @@ func (w *luaWriter) writeBulkFrom(n int64, rb io.Reader) {
 	w.writeError(errors.New("unsupport"))
 }
 
+func (w *luaWriter) writeDouble(f float64) {
+	w.l.Push(lua.LString(zformatScore(f)))
+}
+
+func (w *luaWriter) writeBool(b bool) {
+	if b {
+		w.l.Push(w.toLuaInteger(1))
+	} else {
+		w.l.Push(w.toLuaInteger(0))
+	}
+}
+
+func (w *luaWriter) writePush(lst []interface{}) {
+	w.writeArray(lst)
+}
+
 func (w *luaWriter) flush() {
 }
 
@@ -212,6 +227,11 @@ func (app *App) openScript() {
 	setMapState(l, s)
 }
 
+// isScript returns whether the client runs the commands called by the scripts.
+func (c *client) isScript() bool {
+	return c.app.script != nil && c == c.app.script.c
+}
+
 func (app *App) closeScript() {
 	app.script.l.Close()
 	delMapState(app.script.l)
@@ -330,6 +350,10 @@ func luaCallGenericCommand(l *lua.LState) int {
 
 	c.cmd = l.ToString(1)
 
+	if exeCmd, ok := regCmds[strings.ToLower(c.cmd)]; ok && exeCmd.flags&cmdNoScript != 0 {
+		panic(fmt.Sprintf("%s is not allowed from scripts", strings.ToUpper(c.cmd)))
+	}
+
 	c.args = make([][]byte, argc-1)
 
 	for i := 2; i <= argc; i++ {
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// the max number of the arguments and the max length of an argument
+	// saved in the slow log, same as Redis
+	slowlogMaxArgc   = 32
+	slowlogMaxArgLen = 128
+)
+
+type slowlogEntry struct {
+	id       int64
+	time     int64
+	duration int64
+	args     [][]byte
+	addr     string
+	name     string
+}
+
+// slowlog keeps the latest slow commands in a ring buffer.
+type slowlog struct {
+	sync.Mutex
+
+	app *App
+
+	nextID int64
+
+	// entries[head] is the next position to save, n is the number of entries
+	entries []slowlogEntry
+	head    int
+	n       int
+}
+
+func newSlowlog(app *App) *slowlog {
+	s := new(slowlog)
+	s.app = app
+	return s
+}
+
+// log saves the command if it is slower than the threshold.
+func (s *slowlog) log(c *client, duration time.Duration) {
+	slowerThan, maxLen := s.app.cfg.GetSlowlog()
+	if slowerThan < 0 || duration.Nanoseconds()/1000 < slowerThan {
+		return
+	} else if _, ok := regCmds[c.cmd]; !ok {
+		return
+	}
+
+	e := slowlogEntry{
+		time:     time.Now().Unix(),
+		duration: duration.Nanoseconds() / 1000,
+		args:     slowlogArgs(c.cmd, c.args),
+		addr:     c.remoteAddr,
+		name:     c.getStat().name,
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.resize(maxLen)
+
+	e.id = s.nextID
+	s.nextID++
+
+	s.entries[s.head] = e
+	s.head = (s.head + 1) % len(s.entries)
+	if s.n < len(s.entries) {
+		s.n++
+	}
+}
+
+// resize changes the size of the ring buffer and keeps the latest entries.
+func (s *slowlog) resize(maxLen int) {
+	if len(s.entries) == maxLen {
+		return
+	}
+
+	entries := s.latest(maxLen)
+	s.entries = make([]slowlogEntry, maxLen)
+	s.n = copy(s.entries, entries)
+
+	// the latest is the first
+	for i, j := 0, s.n-1; i < j; i, j = i+1, j-1 {
+		s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	}
+	s.head = s.n % maxLen
+}
+
+// latest returns at most count latest entries, the latest is the first.
+func (s *slowlog) latest(count int) []slowlogEntry {
+	if count > s.n {
+		count = s.n
+	}
+
+	entries := make([]slowlogEntry, 0, count)
+	for i := 1; i <= count; i++ {
+		entries = append(entries, s.entries[(s.head-i+len(s.entries))%len(s.entries)])
+	}
+	return entries
+}
+
+func (s *slowlog) get(count int) []slowlogEntry {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.latest(count)
+}
+
+func (s *slowlog) len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.n
+}
+
+func (s *slowlog) reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	for i := range s.entries {
+		s.entries[i] = slowlogEntry{}
+	}
+	s.head = 0
+	s.n = 0
+}
+
+// slowlogArgs copies the command and arguments, the arguments are truncated
+// because they may be very large.
+func slowlogArgs(cmd string, args [][]byte) [][]byte {
+	argc := len(args) + 1
+	if argc > slowlogMaxArgc {
+		argc = slowlogMaxArgc
+	}
+
+	ay := make([][]byte, 0, argc)
+	ay = append(ay, []byte(cmd))
+
+	for i, arg := range args {
+		if len(ay) == argc-1 && len(args) > argc-1 {
+			ay = append(ay, []byte(fmt.Sprintf("... (%d more arguments)", len(args)-i)))
+			break
+		}
+
+		if len(arg) > slowlogMaxArgLen {
+			arg = append(arg[:slowlogMaxArgLen:slowlogMaxArgLen], fmt.Sprintf("... (%d more bytes)", len(arg)-slowlogMaxArgLen)...)
+		} else {
+			arg = append([]byte{}, arg...)
+		}
+		ay = append(ay, arg)
+	}
+
+	return ay
+}
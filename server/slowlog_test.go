@@ -0,0 +1,134 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/goredis"
+	"github.com/siddontang/ledisdb/config"
+)
+
+func TestSlowlogRing(t *testing.T) {
+	app := &App{cfg: config.NewConfigDefault()}
+	app.cfg.SetSlowlogLogSlowerThan(0)
+	app.cfg.SetSlowlogMaxLen(3)
+
+	s := newSlowlog(app)
+
+	c := &client{app: app, cmd: "get"}
+	for i := 0; i < 5; i++ {
+		c.args = [][]byte{[]byte(fmt.Sprintf("key_%d", i))}
+		s.log(c, 0)
+	}
+
+	check := func(count int, keys ...string) {
+		entries := s.get(count)
+		if len(entries) != len(keys) {
+			t.Fatal(len(entries), keys)
+		}
+		for i, e := range entries {
+			if string(e.args[1]) != keys[i] {
+				t.Fatalf("%d: %s != %s", i, e.args[1], keys[i])
+			}
+		}
+	}
+
+	if s.len() != 3 {
+		t.Fatal(s.len())
+	}
+	check(10, "key_4", "key_3", "key_2")
+	check(2, "key_4", "key_3")
+
+	if e := s.get(1)[0]; e.id != 4 {
+		t.Fatal(e.id)
+	}
+
+	// keep the latest when resized
+	app.cfg.SetSlowlogMaxLen(2)
+	c.args = [][]byte{[]byte("key_5")}
+	s.log(c, 0)
+	check(10, "key_5", "key_4")
+
+	app.cfg.SetSlowlogMaxLen(4)
+	c.args = [][]byte{[]byte("key_6")}
+	s.log(c, 0)
+	check(10, "key_6", "key_5", "key_4")
+
+	s.reset()
+	if s.len() != 0 {
+		t.Fatal(s.len())
+	}
+
+	app.cfg.SetSlowlogLogSlowerThan(-1)
+	s.log(c, 0)
+	if s.len() != 0 {
+		t.Fatal(s.len())
+	}
+}
+
+func TestSlowlogArgs(t *testing.T) {
+	args := make([][]byte, 40)
+	for i := range args {
+		args[i] = []byte("a")
+	}
+	args[0] = []byte(strings.Repeat("b", 200))
+
+	ay := slowlogArgs("mset", args)
+	if len(ay) != slowlogMaxArgc {
+		t.Fatal(len(ay))
+	}
+
+	if s := string(ay[1]); s != strings.Repeat("b", 128)+"... (72 more bytes)" {
+		t.Fatal(s)
+	}
+
+	if s := string(ay[31]); s != "... (10 more arguments)" {
+		t.Fatal(s)
+	}
+
+	if ay := slowlogArgs("get", args[1:2]); len(ay) != 2 || string(ay[1]) != "a" {
+		t.Fatal(ay)
+	}
+}
+
+func TestSlowlogCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("config", "set", "slowlog-log-slower-than", 0); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Do("config", "set", "slowlog-log-slower-than", 10000)
+
+	if v, _ := goredis.Strings(c.Do("config", "get", "slowlog-log-slower-than")); len(v) != 2 || v[1] != "0" {
+		t.Fatal(v)
+	}
+
+	c.Do("slowlog", "reset")
+	c.Do("get", "slowlog_key")
+
+	v, err := goredis.Values(c.Do("slowlog", "get", 1))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(v) != 1 {
+		t.Fatal(v)
+	}
+
+	e, _ := goredis.Values(v[0], nil)
+	if len(e) != 6 {
+		t.Fatal(e)
+	}
+
+	if args, _ := goredis.Strings(e[3], nil); len(args) != 2 || args[0] != "get" || args[1] != "slowlog_key" {
+		t.Fatal(args)
+	}
+
+	if n, _ := goredis.Int(c.Do("slowlog", "len")); n < 2 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("config", "set", "slowlog-max-len", 0); err == nil {
+		t.Fatal("must error")
+	}
+}
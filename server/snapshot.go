@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/siddontang/go/log"
+	"github.com/siddontang/go/sync2"
 	"github.com/siddontang/ledisdb/config"
 )
 
@@ -25,6 +26,11 @@ type snapshotStore struct {
 
 	names []string
 
+	// creating is true when dumping a snapshot
+	creating sync2.AtomicBool
+	num      sync2.AtomicInt64
+	lastTime sync2.AtomicInt64
+
 	quit chan struct{}
 }
 
@@ -148,6 +154,8 @@ func (s *snapshotStore) purge(create bool) {
 			log.Errorf("purge snapshot %s error %s", name, err.Error())
 		}
 	}
+
+	s.updateStat()
 }
 
 func (s *snapshotStore) snapshotPath(name string) string {
@@ -183,6 +191,9 @@ func (s *snapshotStore) Create(d snapshotDumper) (*snapshot, time.Time, error) {
 
 	s.purge(true)
 
+	s.creating.Set(true)
+	defer s.creating.Set(false)
+
 	now := time.Now()
 	name := snapshotName(now)
 
@@ -216,10 +227,24 @@ func (s *snapshotStore) Create(d snapshotDumper) (*snapshot, time.Time, error) {
 		return nil, time.Time{}, err
 	}
 	s.names = append(s.names, name)
+	s.updateStat()
 
 	return &snapshot{f: f}, now, nil
 }
 
+// updateStat updates the number of snapshots and the create time of the
+// latest one, they are read without the lock which is held when dumping.
+func (s *snapshotStore) updateStat() {
+	s.num.Set(int64(len(s.names)))
+
+	if len(s.names) == 0 {
+		s.lastTime.Set(0)
+	} else {
+		t, _ := parseSnapshotName(s.names[len(s.names)-1])
+		s.lastTime.Set(t.Unix())
+	}
+}
+
 func (s *snapshotStore) OpenLatest() (*snapshot, time.Time, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"bytes"
+
+	"github.com/siddontang/ledisdb/store/driver"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
+)
+
+const (
+	batchViewDelete byte = 0
+	batchViewPut    byte = 1
+)
+
+// BatchView collects the puts and deletes into one write batch, and reads
+// the db as if the write batch was already committed, so a transaction
+// can see its own writes before committing them all at once.
+//
+// BatchView is not thread safe.
+type BatchView struct {
+	db *DB
+
+	wb *WriteBatch
+
+	// mem saves the uncommitted data, the first byte of the value
+	// is batchViewPut or batchViewDelete.
+	mem *memdb.DB
+}
+
+// NewBatchView creates a BatchView, it must be closed after use.
+func (db *DB) NewBatchView() *BatchView {
+	v := new(BatchView)
+	v.db = db
+	v.wb = db.NewWriteBatch()
+	v.mem = memdb.New(comparer.DefaultComparer, 0)
+	return v
+}
+
+func (v *BatchView) Get(key []byte) ([]byte, error) {
+	if value, err := v.mem.Get(key); err == nil {
+		if value[0] == batchViewDelete {
+			return nil, nil
+		}
+		return append([]byte{}, value[1:]...), nil
+	}
+
+	return v.db.Get(key)
+}
+
+func (v *BatchView) GetSlice(key []byte) (Slice, error) {
+	value, err := v.Get(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	return driver.GoSlice(value), nil
+}
+
+func (v *BatchView) Put(key []byte, value []byte) error {
+	v.wb.Put(key, value)
+
+	buf := make([]byte, len(value)+1)
+	buf[0] = batchViewPut
+	copy(buf[1:], value)
+	return v.mem.Put(key, buf)
+}
+
+func (v *BatchView) Delete(key []byte) error {
+	v.wb.Delete(key)
+	return v.mem.Put(key, []byte{batchViewDelete})
+}
+
+// Apply puts and deletes all the data of the batch data.
+func (v *BatchView) Apply(d *BatchData) error {
+	return d.Replay(batchViewReplay{v})
+}
+
+// WriteBatch returns the write batch which has all the data put or deleted.
+func (v *BatchView) WriteBatch() *WriteBatch {
+	return v.wb
+}
+
+func (v *BatchView) NewWriteBatch() *WriteBatch {
+	return v.db.NewWriteBatch()
+}
+
+// Rollback discards all the uncommitted data.
+func (v *BatchView) Rollback() error {
+	v.mem.Reset()
+	return v.wb.Rollback()
+}
+
+func (v *BatchView) Close() {
+	v.mem.Reset()
+	v.wb.Close()
+}
+
+func (v *BatchView) NewIterator() *Iterator {
+	v.db.st.IterNum.Add(1)
+
+	it := new(Iterator)
+	it.it = &batchViewIterator{base: v.db.db.NewIterator(), mem: v.mem.NewIterator(nil)}
+	it.st = v.db.st
+
+	return it
+}
+
+func (v *BatchView) RangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (v *BatchView) RevRangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (v *BatchView) RangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+func (v *BatchView) RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+type batchViewReplay struct {
+	v *BatchView
+}
+
+func (r batchViewReplay) Put(key, value []byte) {
+	r.v.Put(key, value)
+}
+
+func (r batchViewReplay) Delete(key []byte) {
+	r.v.Delete(key)
+}
+
+// batchViewIterator merges the db iterator and the uncommitted data iterator,
+// the uncommitted data wins for the same key and the deleted keys are skipped.
+type batchViewIterator struct {
+	base driver.IIterator
+	mem  iterator.Iterator
+
+	reverse bool
+
+	// the current item is from mem or base
+	curMem bool
+	valid  bool
+}
+
+// pick chooses the current item from the base and mem iterators.
+func (it *batchViewIterator) pick() {
+	bv, mv := it.base.Valid(), it.mem.Valid()
+	switch {
+	case !bv && !mv:
+		it.valid = false
+		return
+	case !bv:
+		it.curMem = true
+	case !mv:
+		it.curMem = false
+	default:
+		c := bytes.Compare(it.mem.Key(), it.base.Key())
+		if it.reverse {
+			c = -c
+		}
+		it.curMem = c <= 0
+	}
+	it.valid = true
+}
+
+// find picks the current item and skips the deleted keys.
+func (it *batchViewIterator) find() {
+	for {
+		it.pick()
+		if !it.valid || !it.curMem || it.mem.Value()[0] == batchViewPut {
+			return
+		}
+		it.step()
+	}
+}
+
+// step moves over the current key in the current direction.
+func (it *batchViewIterator) step() {
+	if it.curMem {
+		if it.base.Valid() && bytes.Equal(it.base.Key(), it.mem.Key()) {
+			it.moveBase()
+		}
+		it.moveMem()
+	} else {
+		it.moveBase()
+	}
+}
+
+func (it *batchViewIterator) moveBase() {
+	if it.reverse {
+		it.base.Prev()
+	} else {
+		it.base.Next()
+	}
+}
+
+func (it *batchViewIterator) moveMem() {
+	if it.reverse {
+		it.mem.Prev()
+	} else {
+		it.mem.Next()
+	}
+}
+
+// seekLE moves both iterators to the last key less than or equal to key.
+func (it *batchViewIterator) seekLE(key []byte) {
+	it.base.Seek(key)
+	if !it.base.Valid() {
+		it.base.Last()
+	} else if bytes.Compare(it.base.Key(), key) > 0 {
+		it.base.Prev()
+	}
+
+	if !it.mem.Seek(key) {
+		it.mem.Last()
+	} else if bytes.Compare(it.mem.Key(), key) > 0 {
+		it.mem.Prev()
+	}
+}
+
+func (it *batchViewIterator) Close() error {
+	it.mem.Release()
+	return it.base.Close()
+}
+
+func (it *batchViewIterator) First() {
+	it.reverse = false
+	it.base.First()
+	it.mem.First()
+	it.find()
+}
+
+func (it *batchViewIterator) Last() {
+	it.reverse = true
+	it.base.Last()
+	it.mem.Last()
+	it.find()
+}
+
+func (it *batchViewIterator) Seek(key []byte) {
+	it.reverse = false
+	it.base.Seek(key)
+	it.mem.Seek(key)
+	it.find()
+}
+
+func (it *batchViewIterator) Next() {
+	if !it.valid {
+		return
+	}
+
+	if it.reverse {
+		key := append([]byte{}, it.Key()...)
+		it.reverse = false
+		it.base.Seek(key)
+		it.mem.Seek(key)
+		it.pick()
+	}
+
+	it.step()
+	it.find()
+}
+
+func (it *batchViewIterator) Prev() {
+	if !it.valid {
+		return
+	}
+
+	if !it.reverse {
+		key := append([]byte{}, it.Key()...)
+		it.reverse = true
+		it.seekLE(key)
+		it.pick()
+	}
+
+	it.step()
+	it.find()
+}
+
+func (it *batchViewIterator) Valid() bool {
+	return it.valid
+}
+
+func (it *batchViewIterator) Key() []byte {
+	if !it.valid {
+		return nil
+	} else if it.curMem {
+		return it.mem.Key()
+	}
+	return it.base.Key()
+}
+
+func (it *batchViewIterator) Value() []byte {
+	if !it.valid {
+		return nil
+	} else if it.curMem {
+		return it.mem.Value()[1:]
+	}
+	return it.base.Value()
+}
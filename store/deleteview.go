@@ -0,0 +1,113 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
+)
+
+// DeleteView reads the db as if the hidden keys were deleted, so a writer can
+// stage the deletions in its write batch and see them deleted before
+// committing them with its other writes.
+//
+// DeleteView is safe for concurrent use, the hidden keys are seen as deleted
+// by all the readers of the view.
+type DeleteView struct {
+	*DB
+
+	m sync.RWMutex
+
+	// mem saves the hidden keys with the batchViewDelete value
+	mem *memdb.DB
+}
+
+// NewDeleteView creates a DeleteView with no hidden keys.
+func (db *DB) NewDeleteView() *DeleteView {
+	v := new(DeleteView)
+	v.DB = db
+	v.mem = memdb.New(comparer.DefaultComparer, 0)
+	return v
+}
+
+// Hide makes the key seen as deleted until Unhide.
+func (v *DeleteView) Hide(key []byte) {
+	v.m.Lock()
+	v.mem.Put(key, []byte{batchViewDelete})
+	v.m.Unlock()
+}
+
+// Unhide makes the keys seen as the data of the db again, e.g. after the
+// deletions are committed or rolled back.
+func (v *DeleteView) Unhide(keys [][]byte) {
+	v.m.Lock()
+	for _, key := range keys {
+		v.mem.Delete(key)
+	}
+
+	// the deleted data is kept in memdb until reset, use a new one and
+	// the opened iterators still work with the old one
+	if v.mem.Len() == 0 && v.mem.Size() > 0 {
+		v.mem = memdb.New(comparer.DefaultComparer, 0)
+	}
+	v.m.Unlock()
+}
+
+// hidden returns the memdb of the hidden keys, nil if no key is hidden.
+func (v *DeleteView) hidden() *memdb.DB {
+	v.m.RLock()
+	mem := v.mem
+	v.m.RUnlock()
+
+	if mem.Len() == 0 {
+		return nil
+	}
+	return mem
+}
+
+func (v *DeleteView) Get(key []byte) ([]byte, error) {
+	if mem := v.hidden(); mem != nil && mem.Contains(key) {
+		return nil, nil
+	}
+
+	return v.DB.Get(key)
+}
+
+func (v *DeleteView) GetSlice(key []byte) (Slice, error) {
+	if mem := v.hidden(); mem != nil && mem.Contains(key) {
+		return nil, nil
+	}
+
+	return v.DB.GetSlice(key)
+}
+
+func (v *DeleteView) NewIterator() *Iterator {
+	mem := v.hidden()
+	if mem == nil {
+		return v.DB.NewIterator()
+	}
+
+	v.DB.st.IterNum.Add(1)
+
+	it := new(Iterator)
+	it.it = &batchViewIterator{base: v.DB.db.NewIterator(), mem: mem.NewIterator(nil)}
+	it.st = v.DB.st
+
+	return it
+}
+
+func (v *DeleteView) RangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (v *DeleteView) RevRangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (v *DeleteView) RangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+func (v *DeleteView) RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(v.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
@@ -11,7 +11,11 @@ type Snapshot struct {
 }
 
 func (s *Snapshot) Get(key []byte) ([]byte, error) {
-	return s.snp.Get(key, s.db.iteratorOpts)
+	v, err := s.snp.Get(key, s.db.iteratorOpts)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return v, err
 }
 
 func (s *Snapshot) NewIterator() driver.IIterator {
@@ -27,7 +27,7 @@ func (s *Snapshot) GetSlice(key []byte) (driver.ISlice, error) {
 
 func (s *Snapshot) NewIterator() driver.IIterator {
 	it := new(Iterator)
-	it.it = C.leveldb_create_iterator(s.db.db, s.db.iteratorOpts.Opt)
+	it.it = C.leveldb_create_iterator(s.db.db, s.iteratorOpts.Opt)
 	return it
 
 }
@@ -32,6 +32,16 @@ func (st *Stat) statGet(v interface{}, err error) {
 	}
 }
 
+// Reset resets the statistics, it can be called when the store is used.
 func (st *Stat) Reset() {
-	*st = Stat{}
+	for _, n := range []*sync2.AtomicInt64{&st.GetNum, &st.GetMissingNum, &st.PutNum,
+		&st.DeleteNum, &st.IterNum, &st.IterSeekNum, &st.IterCloseNum, &st.SnapshotNum,
+		&st.SnapshotCloseNum, &st.BatchNum, &st.BatchCommitNum, &st.TxNum, &st.TxCommitNum,
+		&st.TxCloseNum, &st.CompactNum} {
+		n.Set(0)
+	}
+
+	st.GetTotalTime.Set(0)
+	st.BatchCommitTotalTime.Set(0)
+	st.CompactTotalTime.Set(0)
 }
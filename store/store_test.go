@@ -41,6 +41,8 @@ func testStore(db *DB, t *testing.T) {
 	testIterator(db, t)
 	testSnapshot(db, t)
 	testBatchData(db, t)
+	testBatchView(db, t)
+	testDeleteView(db, t)
 }
 
 func testClear(db *DB, t *testing.T) {
@@ -324,6 +326,14 @@ func testSnapshot(db *DB, t *testing.T) {
 		t.Fatal(string(v))
 	}
 
+	db.Put([]byte("snap_new"), v1)
+
+	if v, err := snap.Get([]byte("snap_new")); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal("must nil")
+	}
+
 	if v, err := db.Get(foo); err != nil {
 		t.Fatal(err)
 	} else if string(v) != "v2" {
@@ -367,3 +377,117 @@ func testBatchData(db *DB, t *testing.T) {
 		t.Fatalf("%v != %v", kvs, expected)
 	}
 }
+
+func testBatchView(db *DB, t *testing.T) {
+	testClear(db, t)
+
+	k := func(i int) []byte {
+		return []byte(fmt.Sprintf("key_%d", i))
+	}
+
+	for i := 0; i < 6; i++ {
+		db.Put(k(i), []byte("value"))
+	}
+
+	v := db.NewBatchView()
+	defer v.Close()
+
+	v.Delete(k(1))
+	v.Delete(k(4))
+	v.Put(k(3), []byte("value"))
+	v.Put(k(7), []byte("value"))
+
+	if value, err := v.Get(k(1)); err != nil {
+		t.Fatal(err)
+	} else if value != nil {
+		t.Fatal("must nil")
+	}
+
+	if value, err := v.Get(k(7)); err != nil {
+		t.Fatal(err)
+	} else if string(value) != "value" {
+		t.Fatal(string(value))
+	}
+
+	if value, err := db.Get(k(7)); err != nil {
+		t.Fatal(err)
+	} else if value != nil {
+		t.Fatal("must nil before commit")
+	}
+
+	if err := checkIterator(v.RangeLimitIterator(k(0), k(9), RangeClose, 0, -1), 0, 2, 3, 5, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkIterator(v.RevRangeLimitIterator(k(0), k(9), RangeClose, 0, -1), 7, 5, 3, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkIterator(v.RangeLimitIterator(k(1), k(5), RangeOpen, 1, 2), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// change the direction in the middle
+	it := v.NewIterator()
+	it.Seek(k(3))
+	it.Next()
+	it.Prev()
+	it.Prev()
+	if !it.Valid() || string(it.Key()) != "key_2" {
+		t.Fatal(string(it.Key()))
+	}
+	it.Next()
+	it.Next()
+	if !it.Valid() || string(it.Key()) != "key_5" {
+		t.Fatal(string(it.Key()))
+	}
+	it.Close()
+
+	if err := v.WriteBatch().Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkIterator(db.RangeLimitIterator(k(0), k(9), RangeClose, 0, -1), 0, 2, 3, 5, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	testClear(db, t)
+}
+
+func testDeleteView(db *DB, t *testing.T) {
+	testClear(db, t)
+
+	k := func(i int) []byte {
+		return []byte(fmt.Sprintf("key_%d", i))
+	}
+
+	for i := 0; i < 4; i++ {
+		db.Put(k(i), []byte("value"))
+	}
+
+	v := db.NewDeleteView()
+	v.Hide(k(1))
+	v.Hide(k(3))
+
+	if value, err := v.Get(k(1)); err != nil {
+		t.Fatal(err)
+	} else if value != nil {
+		t.Fatal("must nil")
+	} else if value, _ = db.Get(k(1)); value == nil {
+		t.Fatal("must not be deleted in db")
+	}
+
+	if err := checkIterator(v.RangeLimitIterator(k(0), k(9), RangeClose, 0, -1), 0, 2); err != nil {
+		t.Fatal(err)
+	} else if err := checkIterator(v.RevRangeLimitIterator(k(0), k(9), RangeClose, 0, -1), 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	v.Unhide([][]byte{k(1), k(3)})
+
+	if err := checkIterator(v.RangeLimitIterator(k(0), k(9), RangeClose, 0, -1), 0, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	testClear(db, t)
+}
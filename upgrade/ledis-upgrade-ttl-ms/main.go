@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"github.com/siddontang/ledisdb/store"
+)
+
+var configPath = flag.String("config", "", "ledisdb config file")
+var dataDir = flag.String("data_dir", "", "ledisdb base data dir")
+var dbName = flag.String("db_name", "", "select a db to use, it will overwrite the config's db name")
+
+func main() {
+	flag.Parse()
+
+	if len(*configPath) == 0 {
+		println("need ledis config file")
+		return
+	}
+
+	cfg, err := config.NewConfigWithFile(*configPath)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+
+	if len(*dataDir) > 0 {
+		cfg.DataDir = *dataDir
+	}
+
+	if len(*dbName) > 0 {
+		cfg.DBName = *dbName
+	}
+
+	db, err := store.Open(cfg)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	defer db.Close()
+
+	// upgrade: ttl meta key 102 and time key 103 in seconds
+	// to ttl meta key 104 and time key 105 in milliseconds
+
+	wb := db.NewWriteBatch()
+
+	for i := 0; i < cfg.Databases; i++ {
+		index := encodeIndex(i)
+
+		minK, maxK := keyPair(index, ledis.ObsoleteSecExpMetaType)
+
+		it := db.RangeIterator(minK, maxK, store.RangeROpen)
+		num := 0
+		for ; it.Valid(); it.Next() {
+			dt, k, err := decodeOldMetaKey(index, it.RawKey())
+			if err != nil {
+				continue
+			}
+
+			when, err := ledis.Int64(it.Value(), nil)
+			if err != nil {
+				continue
+			}
+
+			mk := encodeMetaKey(index, ledis.ExpMetaType, dt, k)
+			tk := encodeTimeKey(index, ledis.ExpTimeType, dt, k, when*1000)
+
+			wb.Put(tk, mk)
+			wb.Put(mk, ledis.PutInt64(when*1000))
+			wb.Delete(it.RawKey())
+			wb.Delete(encodeTimeKey(index, ledis.ObsoleteSecExpTimeType, dt, k, when))
+			num++
+			if num%1024 == 0 {
+				if err := wb.Commit(); err != nil {
+					fmt.Printf("commit error :%s\n", err.Error())
+				}
+			}
+		}
+		it.Close()
+
+		if err := wb.Commit(); err != nil {
+			fmt.Printf("commit error :%s\n", err.Error())
+		}
+
+		// remove the old time keys which have no meta key
+		minK, maxK = keyPair(index, ledis.ObsoleteSecExpTimeType)
+
+		it = db.RangeIterator(minK, maxK, store.RangeROpen)
+		for ; it.Valid(); it.Next() {
+			wb.Delete(it.RawKey())
+		}
+		it.Close()
+
+		if err := wb.Commit(); err != nil {
+			fmt.Printf("commit error :%s\n", err.Error())
+		}
+
+		if num > 0 {
+			fmt.Printf("db %d upgrade %d ttl keys\n", i, num)
+		}
+	}
+}
+
+func encodeIndex(index int) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(index))
+	return buf[0:n]
+}
+
+func keyPair(index []byte, tp byte) ([]byte, []byte) {
+	minB := make([]byte, len(index)+1)
+	pos := copy(minB, index)
+	minB[pos] = tp
+
+	maxB := make([]byte, len(index)+1)
+	pos = copy(maxB, index)
+	maxB[pos] = tp + 1
+
+	return minB, maxB
+}
+
+func decodeOldMetaKey(index []byte, mk []byte) (byte, []byte, error) {
+	pos := len(index)
+	if len(mk) < pos+2 || string(mk[0:pos]) != string(index) || mk[pos] != ledis.ObsoleteSecExpMetaType {
+		return 0, nil, fmt.Errorf("invalid exp meta key")
+	}
+
+	return mk[pos+1], mk[pos+2:], nil
+}
+
+func encodeMetaKey(index []byte, tp byte, dataType byte, key []byte) []byte {
+	buf := make([]byte, len(key)+2+len(index))
+
+	pos := copy(buf, index)
+	buf[pos] = tp
+	pos++
+	buf[pos] = dataType
+	pos++
+
+	copy(buf[pos:], key)
+
+	return buf
+}
+
+func encodeTimeKey(index []byte, tp byte, dataType byte, key []byte, when int64) []byte {
+	buf := make([]byte, len(key)+10+len(index))
+
+	pos := copy(buf, index)
+	buf[pos] = tp
+	pos++
+
+	binary.BigEndian.PutUint64(buf[pos:], uint64(when))
+	pos += 8
+
+	buf[pos] = dataType
+	pos++
+
+	copy(buf[pos:], key)
+
+	return buf
+}
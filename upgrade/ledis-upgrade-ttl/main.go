@@ -102,7 +102,7 @@ func encodeNewKey(index uint8, dataType byte, key []byte, when int64) []byte {
 	buf := make([]byte, len(key)+11)
 
 	buf[0] = index
-	buf[1] = ledis.ExpTimeType
+	buf[1] = ledis.ObsoleteSecExpTimeType
 	pos := 2
 
 	binary.BigEndian.PutUint64(buf[pos:], uint64(when))
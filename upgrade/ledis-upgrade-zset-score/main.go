@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"github.com/siddontang/ledisdb/store"
+)
+
+var configPath = flag.String("config", "", "ledisdb config file")
+var dataDir = flag.String("data_dir", "", "ledisdb base data dir")
+var dbName = flag.String("db_name", "", "select a db to use, it will overwrite the config's db name")
+
+func main() {
+	flag.Parse()
+
+	if len(*configPath) == 0 {
+		println("need ledis config file")
+		return
+	}
+
+	cfg, err := config.NewConfigWithFile(*configPath)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+
+	if len(*dataDir) > 0 {
+		cfg.DataDir = *dataDir
+	}
+
+	if len(*dbName) > 0 {
+		cfg.DBName = *dbName
+	}
+
+	db, err := store.Open(cfg)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	defer db.Close()
+
+	// upgrade: zset int64 score key 8 to float64 score key 13,
+	// and the int64 score value of the zset member key to float64
+
+	wb := db.NewWriteBatch()
+
+	for i := 0; i < cfg.Databases; i++ {
+		index := encodeIndex(i)
+
+		minK, maxK := keyPair(index, ledis.ObsoleteZScoreType)
+
+		it := db.RangeIterator(minK, maxK, store.RangeROpen)
+		num := 0
+		for ; it.Valid(); it.Next() {
+			key, member, score, err := decodeOldScoreKey(index, it.RawKey())
+			if err != nil {
+				continue
+			}
+
+			wb.Put(encodeScoreKey(index, key, member, float64(score)), []byte{})
+			wb.Put(encodeSetKey(index, key, member), ledis.PutFloat64(float64(score)))
+			wb.Delete(it.RawKey())
+			num++
+			if num%1024 == 0 {
+				if err := wb.Commit(); err != nil {
+					fmt.Printf("commit error :%s\n", err.Error())
+				}
+			}
+		}
+		it.Close()
+
+		if err := wb.Commit(); err != nil {
+			fmt.Printf("commit error :%s\n", err.Error())
+		}
+
+		if num > 0 {
+			fmt.Printf("db %d upgrade %d zset members\n", i, num)
+		}
+	}
+}
+
+func encodeIndex(index int) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(index))
+	return buf[0:n]
+}
+
+func keyPair(index []byte, tp byte) ([]byte, []byte) {
+	minB := make([]byte, len(index)+1)
+	pos := copy(minB, index)
+	minB[pos] = tp
+
+	maxB := make([]byte, len(index)+1)
+	pos = copy(maxB, index)
+	maxB[pos] = tp + 1
+
+	return minB, maxB
+}
+
+// decodeOldScoreKey decodes the old score key with format:
+// index, 8, key len(2 bytes), key, '<' or '=', int64 score(8 bytes big endian), ':', member
+func decodeOldScoreKey(index []byte, ek []byte) ([]byte, []byte, int64, error) {
+	pos := len(index)
+	if len(ek) < pos+3 || string(ek[0:pos]) != string(index) || ek[pos] != ledis.ObsoleteZScoreType {
+		return nil, nil, 0, fmt.Errorf("invalid zscore key")
+	}
+	pos++
+
+	keyLen := int(binary.BigEndian.Uint16(ek[pos:]))
+	pos += 2
+
+	if pos+keyLen+10 > len(ek) {
+		return nil, nil, 0, fmt.Errorf("invalid zscore key")
+	}
+
+	key := ek[pos : pos+keyLen]
+	pos += keyLen
+
+	if ek[pos] != '<' && ek[pos] != '=' {
+		return nil, nil, 0, fmt.Errorf("invalid zscore key")
+	}
+	pos++
+
+	score := int64(binary.BigEndian.Uint64(ek[pos:]))
+	pos += 8
+
+	if ek[pos] != ':' {
+		return nil, nil, 0, fmt.Errorf("invalid zscore key")
+	}
+	pos++
+
+	return key, ek[pos:], score, nil
+}
+
+func encodeSetKey(index []byte, key []byte, member []byte) []byte {
+	buf := make([]byte, len(key)+len(member)+4+len(index))
+
+	pos := copy(buf, index)
+	buf[pos] = ledis.ZSetType
+	pos++
+
+	binary.BigEndian.PutUint16(buf[pos:], uint16(len(key)))
+	pos += 2
+
+	pos += copy(buf[pos:], key)
+
+	buf[pos] = ':'
+	pos++
+
+	copy(buf[pos:], member)
+
+	return buf
+}
+
+// encodeScoreKey must be the same as the zEncodeScoreKey in ledis
+func encodeScoreKey(index []byte, key []byte, member []byte, score float64) []byte {
+	buf := make([]byte, len(key)+len(member)+13+len(index))
+
+	pos := copy(buf, index)
+	buf[pos] = ledis.ZScoreType
+	pos++
+
+	binary.BigEndian.PutUint16(buf[pos:], uint16(len(key)))
+	pos += 2
+
+	pos += copy(buf[pos:], key)
+
+	buf[pos] = '<'
+	pos++
+
+	u := math.Float64bits(score)
+	if u&(1<<63) == 0 {
+		u |= 1 << 63
+	} else {
+		u = ^u
+	}
+
+	binary.BigEndian.PutUint64(buf[pos:], u)
+	pos += 8
+
+	buf[pos] = ':'
+	pos++
+
+	copy(buf[pos:], member)
+
+	return buf
+}